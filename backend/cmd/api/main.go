@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"keepsy-backend/internal/accounts"
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/categories"
+	"keepsy-backend/internal/config"
+	"keepsy-backend/internal/db"
+	"keepsy-backend/internal/files"
+	"keepsy-backend/internal/locations"
+	"keepsy-backend/internal/photos"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/search"
+	"keepsy-backend/internal/services"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/mail"
+	"keepsy-backend/internal/services/sms"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/tags"
+	"keepsy-backend/internal/users"
+	"keepsy-backend/internal/warranty"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Connect to database
+	database, err := db.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	// Mailer (SMTP in production, local .eml files in dev)
+	var mailer mail.Mailer
+	switch cfg.MailDriver {
+	case "smtp":
+		mailer = mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "file":
+		mailer, err = mail.NewFileMailer(cfg.MailDir)
+		if err != nil {
+			log.Fatalf("Failed to initialize mailer: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q", cfg.MailDriver)
+	}
+
+	// SMS Sender
+	// No gateway integrated yet, codes are logged to stdout
+	smsSender := sms.NewFakeSender()
+
+	// Initialize repositories and handlers
+	userRepo := users.NewMySQLRepository(database.Conn)
+
+	authRepo := auth.NewMySQLRepository(database.Conn)
+	tokenManager := auth.NewTokenManager(cfg.AuthSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	sessionRepo := auth.NewMySQLSessionRepository(database.Conn)
+	passwordPolicy, err := auth.LoadPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordDenylistFile)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+
+	otpRepo := auth.NewMySQLOTPRepository(database.Conn)
+	securityRepo := auth.NewMySQLSecurityRepository(database.Conn)
+	twoFactorRepo := auth.NewMySQLTwoFactorRepository(database.Conn)
+	identityRepo := auth.NewMySQLIdentityRepository(database.Conn)
+	accessTokenRepo := auth.NewMySQLAccessTokenRepository(database.Conn)
+
+	oidcProviders := make(map[string]*auth.OIDCProvider)
+	for _, p := range cfg.OIDCProviders {
+		oidcProviders[p.Name] = auth.NewOIDCProvider(auth.OIDCConfig{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+
+	authService := auth.NewService(authRepo, userRepo, sessionRepo, otpRepo, securityRepo, twoFactorRepo, identityRepo, accessTokenRepo, tokenManager, mailer, smsSender, auth.Options{
+		BcryptCost:       cfg.BcryptCost,
+		PasswordPolicy:   passwordPolicy,
+		PasswordResetURL: cfg.PasswordResetURL,
+		PasswordResetTTL: cfg.PasswordResetTTL,
+		OTPTTL:           cfg.OTPTTL,
+		OTPMaxAttempts:   cfg.OTPMaxAttempts,
+		LoginCodeLimit:   cfg.LoginCodeLimit,
+		LoginCodeWindow:  cfg.LoginCodeWindow,
+		VerifyCodeLimit:  cfg.VerifyCodeLimit,
+		VerifyCodeWindow: cfg.VerifyCodeWindow,
+
+		LoginMaxFailures:      cfg.LoginMaxFailures,
+		LoginMaxFailuresPerIP: cfg.LoginMaxFailuresPerIP,
+		LoginBackoffBase:      cfg.LoginBackoffBase,
+		LoginLockout:          cfg.LoginLockout,
+
+		TOTPIssuer:      cfg.TOTPIssuer,
+		SecondFactorTTL: cfg.SecondFactorTTL,
+
+		OIDCProviders: oidcProviders,
+	})
+	authHandler := auth.NewHandler(authService, cfg.TrustedProxies)
+	authMiddleware := auth.NewMiddleware(authService)
+
+	categoryRepo := categories.NewMySQLRepository(database.Conn)
+	categoryService := categories.NewService(categoryRepo)
+	categoryHandler := categories.NewHandler(categoryService)
+
+	// Storage Service (Local FS)
+	// Save to "uploads" directory in current working dir
+	// Serve via "/uploads/" path, to the files' owners only
+	uploadsURL := "http://localhost:8080/uploads"
+	storageService, err := storage.NewLocalStorage("./uploads", uploadsURL)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	productRepo := products.NewMySQLRepository(database.Conn)
+	warrantyService := warranty.NewService(warranty.NewMySQLRepository(database.Conn), nil)
+	warrantyHandler := warranty.NewHandler(warrantyService)
+
+	locationRepo := locations.NewMySQLRepository(database.Conn)
+	locationService := locations.NewService(locationRepo)
+	locationHandler := locations.NewHandler(locationService)
+
+	tagService := tags.NewService(tags.NewMySQLRepository(database.Conn))
+	tagHandler := tags.NewHandler(tagService)
+
+	productService := products.NewService(productRepo, storageService, warrantyService, locationRepo, categoryRepo)
+	productHandler := products.NewHandler(productService)
+
+	billsRepo := bills.NewMySQLRepository(database.Conn)
+	billsService := bills.NewService(billsRepo, userRepo, productRepo, storageService)
+	billsHandler := bills.NewHandler(billsService)
+
+	serviceRecords := services.NewService(services.NewMySQLRepository(database.Conn), productRepo, billsRepo)
+	servicesHandler := services.NewHandler(serviceRecords)
+
+	photosRepo := photos.NewMySQLRepository(database.Conn)
+	photosService := photos.NewService(photosRepo, productRepo, userRepo, storageService)
+	photosHandler := photos.NewHandler(photosService)
+
+	searchService := search.NewService(search.NewMySQLIndex(database.Conn))
+	searchHandler := search.NewHandler(searchService)
+
+	userService := users.NewService(userRepo, storageService, authService)
+	userHandler := users.NewHandler(userService, auth.UserIDFromContext)
+
+	// Deleted accounts are purged by cmd/purge-accounts once the grace period ends
+	accountService := accounts.NewService(accounts.NewMySQLRepository(database.Conn), userRepo, billsService, storageService)
+	accountHandler := accounts.NewHandler(accountService)
+
+	filesService := files.NewService(billsRepo, photosRepo, productRepo, userRepo, storageService, uploadsURL)
+	filesHandler := files.NewHandler(filesService)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+
+	// Auth Routes
+	mux.HandleFunc("POST /auth/register", authHandler.Register)
+	mux.HandleFunc("POST /auth/login", authHandler.Login)
+	mux.HandleFunc("POST /auth/login/code", authHandler.RequestLoginCode)
+	mux.HandleFunc("POST /auth/login/code/verify", authHandler.LoginWithCode)
+	mux.HandleFunc("POST /auth/login/2fa", authHandler.LoginSecondFactor) // Finishes a login that returned "second_factor"
+	mux.HandleFunc("GET /auth/oidc/{provider}/start", authHandler.StartOIDCLogin)
+	mux.HandleFunc("POST /auth/oidc/{provider}/callback", authHandler.LoginWithOIDC) // {code, state} from the provider redirect
+	mux.HandleFunc("POST /auth/refresh", authHandler.Refresh)
+	mux.HandleFunc("POST /auth/password/forgot", authHandler.ForgotPassword)
+	mux.HandleFunc("POST /auth/password/reset", authHandler.ResetPassword)
+	mux.HandleFunc("POST /auth/verify/email", authHandler.VerifyEmail) // Without "code" (re)sends one
+	mux.HandleFunc("POST /auth/verify/phone", authHandler.VerifyPhone)
+
+	// Everything below requires "Authorization: Bearer <access_token>".
+	// Personal access tokens are only accepted on routes registered with
+	// requireScope, and only if they carry that scope.
+	requireAuth := authMiddleware.RequireAuth
+	requireScope := authMiddleware.RequireScope
+
+	// Session Routes
+	mux.HandleFunc("POST /auth/password/change", requireAuth(authHandler.ChangePassword))
+	mux.HandleFunc("POST /auth/logout", requireAuth(authHandler.Logout))
+	mux.HandleFunc("POST /auth/logout/all", requireAuth(authHandler.LogoutAll))
+	mux.HandleFunc("GET /auth/sessions", requireAuth(authHandler.ListSessions))
+	mux.HandleFunc("DELETE /auth/sessions/{id}", requireAuth(authHandler.RevokeSession))
+	mux.HandleFunc("GET /auth/identities", requireAuth(authHandler.ListIdentities))
+
+	// Personal Access Token Routes
+	mux.HandleFunc("POST /auth/tokens", requireAuth(authHandler.CreateAccessToken))
+	mux.HandleFunc("GET /auth/tokens", requireAuth(authHandler.ListAccessTokens))
+	mux.HandleFunc("DELETE /auth/tokens/{id}", requireAuth(authHandler.RevokeAccessToken))
+
+	// Two-factor Routes
+	mux.HandleFunc("GET /auth/2fa", requireAuth(authHandler.TwoFactorStatus))
+	mux.HandleFunc("POST /auth/2fa/totp", requireAuth(authHandler.EnrollTOTP))
+	mux.HandleFunc("POST /auth/2fa/totp/confirm", requireAuth(authHandler.ConfirmTOTP))
+	mux.HandleFunc("POST /auth/2fa/totp/disable", requireAuth(authHandler.DisableTOTP))
+	mux.HandleFunc("POST /auth/2fa/recovery-codes", requireAuth(authHandler.RegenerateRecoveryCodes))
+
+	// Profile Routes
+	mux.HandleFunc("GET /users/me", requireAuth(userHandler.GetMe))
+	mux.HandleFunc("PATCH /users/me", requireAuth(userHandler.UpdateMe)) // Changed email/phone must be verified again
+	mux.HandleFunc("POST /users/me/avatar", requireAuth(userHandler.UploadAvatar))
+
+	// Account Routes
+	mux.HandleFunc("DELETE /account", requireAuth(accountHandler.DeleteAccount)) // Cancellable for 14 days
+	mux.HandleFunc("GET /account/deletion", requireAuth(accountHandler.GetDeletion))
+	mux.HandleFunc("DELETE /account/deletion", requireAuth(accountHandler.CancelDeletion))
+
+	// Category Routes
+	mux.HandleFunc("GET /categories", requireScope("categories:read", categoryHandler.ListCategories))
+	mux.HandleFunc("GET /categories/{id}/attributes", requireScope("categories:read", categoryHandler.ListAttributes))
+
+	// Category Admin Routes
+	requireAdmin := authMiddleware.RequireAdmin
+	mux.HandleFunc("GET /categories/all", requireAdmin(categoryHandler.ListAllCategories)) // Including inactive ones
+	mux.HandleFunc("POST /categories", requireAdmin(categoryHandler.CreateCategory))
+	mux.HandleFunc("PATCH /categories/{id}", requireAdmin(categoryHandler.UpdateCategory)) // name, slug, is_active
+	mux.HandleFunc("PUT /categories/{id}/parent", requireAdmin(categoryHandler.ReparentCategory))
+	mux.HandleFunc("POST /categories/{id}/attributes", requireAdmin(categoryHandler.CreateAttribute))
+	mux.HandleFunc("PATCH /categories/{id}/attributes/{attributeID}", requireAdmin(categoryHandler.UpdateAttribute)) // label, required, options
+	mux.HandleFunc("DELETE /categories/{id}/attributes/{attributeID}", requireAdmin(categoryHandler.DeleteAttribute))
+
+	// Location Routes
+	mux.HandleFunc("GET /locations", requireScope("locations:read", locationHandler.ListLocations))
+	mux.HandleFunc("POST /locations", requireScope("locations:write", locationHandler.CreateLocation))
+	mux.HandleFunc("PATCH /locations/{id}", requireScope("locations:write", locationHandler.UpdateLocation)) // name, kind
+	mux.HandleFunc("PUT /locations/{id}/parent", requireScope("locations:write", locationHandler.ReparentLocation))
+	mux.HandleFunc("DELETE /locations/{id}", requireScope("locations:write", locationHandler.DeleteLocation))
+	mux.HandleFunc("GET /locations/stats", requireScope("locations:read", locationHandler.ListStats)) // Product count and value
+	mux.HandleFunc("GET /locations/{id}/stats", requireScope("locations:read", locationHandler.GetStats))
+
+	// Tag Routes
+	mux.HandleFunc("GET /tags", requireScope("tags:read", tagHandler.ListTags))
+	mux.HandleFunc("POST /tags", requireScope("tags:write", tagHandler.CreateTag))
+	mux.HandleFunc("PATCH /tags/{id}", requireScope("tags:write", tagHandler.RenameTag))
+	mux.HandleFunc("DELETE /tags/{id}", requireScope("tags:write", tagHandler.DeleteTag))
+	mux.HandleFunc("POST /tags/{id}/merge", requireScope("tags:write", tagHandler.MergeTags)) // Moves source_ids' products here
+	mux.HandleFunc("POST /tags/attach", requireScope("tags:write", tagHandler.AttachTags))    // tag_ids x product_ids
+	mux.HandleFunc("POST /tags/detach", requireScope("tags:write", tagHandler.DetachTags))
+
+	// Product Routes
+	mux.HandleFunc("POST /products", requireScope("products:write", productHandler.CreateProduct))
+	mux.HandleFunc("GET /products", requireScope("products:read", productHandler.GetProduct))                    // ?id=...
+	mux.HandleFunc("GET /products/list", requireScope("products:read", productHandler.ListProducts))             // Current user's products
+	mux.HandleFunc("GET /products/expiring", requireScope("products:read", productHandler.ListExpiringProducts)) // ?within=30d
+	mux.HandleFunc("PATCH /products/{id}", requireScope("products:write", productHandler.UpdateProduct))
+	mux.HandleFunc("DELETE /products/{id}", requireScope("products:write", productHandler.DeleteProduct)) // Also deletes its bill files
+
+	// Warranty Routes (a product can have several, e.g. manufacturer and extended)
+	mux.HandleFunc("GET /products/{id}/warranties", requireScope("products:read", warrantyHandler.ListRecords))
+	mux.HandleFunc("POST /products/{id}/warranties", requireScope("products:write", warrantyHandler.CreateRecord))
+	mux.HandleFunc("PUT /products/{id}/warranties/{warrantyID}", requireScope("products:write", warrantyHandler.UpdateRecord))
+	mux.HandleFunc("DELETE /products/{id}/warranties/{warrantyID}", requireScope("products:write", warrantyHandler.DeleteRecord))
+
+	// Service History Routes
+	mux.HandleFunc("GET /products/{id}/services", requireScope("products:read", servicesHandler.ListRecords))
+	mux.HandleFunc("POST /products/{id}/services", requireScope("products:write", servicesHandler.CreateRecord))
+	mux.HandleFunc("PUT /products/{id}/services/{serviceID}", requireScope("products:write", servicesHandler.UpdateRecord))
+	mux.HandleFunc("DELETE /products/{id}/services/{serviceID}", requireScope("products:write", servicesHandler.DeleteRecord))
+	mux.HandleFunc("GET /products/{id}/cost", requireScope("products:read", servicesHandler.CostOfOwnership))
+
+	// Photo Routes (files under <uuid>/products/<id>/)
+	mux.HandleFunc("GET /products/{id}/photos", requireScope("products:read", photosHandler.ListPhotos))
+	mux.HandleFunc("POST /products/{id}/photos", requireScope("products:write", photosHandler.UploadPhoto))
+	mux.HandleFunc("PUT /products/{id}/photos/order", requireScope("products:write", photosHandler.ReorderPhotos))
+	mux.HandleFunc("PUT /products/{id}/photos/{photoID}/primary", requireScope("products:write", photosHandler.SetPrimaryPhoto))
+	mux.HandleFunc("DELETE /products/{id}/photos/{photoID}", requireScope("products:write", photosHandler.DeletePhoto))
+
+	// Bills Routes
+	mux.HandleFunc("POST /bills/upload", requireScope("bills:write", billsHandler.UploadBill))
+	mux.HandleFunc("GET /bills", requireScope("bills:read", billsHandler.ListBills))
+	mux.HandleFunc("GET /bills/download", requireScope("bills:read", billsHandler.DownloadBill))
+	mux.HandleFunc("PUT /bills/{id}/text", requireScope("bills:write", billsHandler.UpdateBillText)) // Text read from the bill, for search
+
+	// Uploaded Files Routes
+	// Bills, photos and avatars are only served to their owner
+	mux.HandleFunc("GET /uploads/{key...}", requireAuth(filesHandler.ServeFile))
+
+	// Search Routes
+	mux.HandleFunc("GET /search", requireAuth(searchHandler.Search)) // ?q=... over products, purchases and bills
+
+	// CORS Middleware
+	corsMiddleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*") // For dev only
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+
+			if r.Method == "OPTIONS" {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	addr := fmt.Sprintf(":%s", cfg.Port)
+	log.Printf("Server starting on %s", addr)
+	if err := http.ListenAndServe(addr, corsMiddleware(mux)); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+// Command fakeidp runs a local OpenID Connect provider for trying out provider
+// sign-in without a Google/Apple developer account. Point the API at it with
+//
+//	OIDC_PROVIDERS=local
+//	OIDC_LOCAL_ISSUER=http://localhost:9000
+//	OIDC_LOCAL_CLIENT_ID=keepsy-local
+//	OIDC_LOCAL_REDIRECT_URL=keepsy://oidc/callback
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"keepsy-backend/internal/services/auth/fakeidp"
+)
+
+func main() {
+	addr := envOr("FAKEIDP_ADDR", ":9000")
+	issuer := envOr("FAKEIDP_ISSUER", "http://localhost:9000")
+
+	provider, err := fakeidp.New(issuer, envOr("FAKEIDP_CLIENT_ID", "keepsy-local"))
+	if err != nil {
+		log.Fatalf("Failed to start fake identity provider: %v", err)
+	}
+	provider.SetUser(fakeidp.User{
+		Subject:       envOr("FAKEIDP_SUBJECT", "local-user-1"),
+		Email:         envOr("FAKEIDP_EMAIL", "local@keepsy.local"),
+		EmailVerified: true,
+		Name:          envOr("FAKEIDP_NAME", "Local User"),
+	})
+
+	log.Printf("Fake identity provider %s listening on %s", issuer, addr)
+	if err := http.ListenAndServe(addr, provider); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
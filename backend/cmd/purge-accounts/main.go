@@ -0,0 +1,51 @@
+// Command purge-accounts deletes the accounts whose deletion grace period has
+// ended, including all their stored files. Run it periodically (e.g. hourly
+// from cron); accounts that fail are retried on the next run.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"keepsy-backend/internal/accounts"
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/config"
+	"keepsy-backend/internal/db"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/users"
+)
+
+func main() {
+	uploadsDir := flag.String("uploads-dir", "./uploads", "Local storage directory")
+	uploadsURL := flag.String("uploads-url", "http://localhost:8080/uploads", "Public URL of the local storage directory")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	database, err := db.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	storageService, err := storage.NewLocalStorage(*uploadsDir, *uploadsURL)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	userRepo := users.NewMySQLRepository(database.Conn)
+	billsService := bills.NewService(bills.NewMySQLRepository(database.Conn), userRepo, products.NewMySQLRepository(database.Conn), storageService)
+	accountService := accounts.NewService(accounts.NewMySQLRepository(database.Conn), userRepo, billsService, storageService)
+
+	purged, err := accountService.PurgeDue(context.Background(), time.Now())
+	log.Printf("Purged %d accounts", purged)
+	if err != nil {
+		log.Fatalf("Some accounts could not be purged: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+// Command rekey-users gives accounts that still have a name-based (v5) UUID a
+// random one, and moves their stored files (bills, avatar, product photos
+// and their thumbnails) under the new UUID prefix, updating their URLs.
+//
+// It is safe to run more than once: users are re-keyed before their files
+// move, and files already under the user's current prefix are left alone, so
+// an interrupted run picks up where it stopped.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/config"
+	"keepsy-backend/internal/db"
+	"keepsy-backend/internal/photos"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/users"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Only report which users would be re-keyed")
+	batchSize := flag.Int("batch", 500, "Users loaded per query")
+	uploadsDir := flag.String("uploads-dir", "./uploads", "Local storage directory")
+	uploadsURL := flag.String("uploads-url", "http://localhost:8080/uploads", "Public URL of the local storage directory")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	database, err := db.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	storageService, err := storage.NewLocalStorage(*uploadsDir, *uploadsURL)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	userRepo := users.NewMySQLRepository(database.Conn)
+	productRepo := products.NewMySQLRepository(database.Conn)
+	billsService := bills.NewService(bills.NewMySQLRepository(database.Conn), userRepo, productRepo, storageService)
+	// Relocating never sends verification codes
+	usersService := users.NewService(userRepo, storageService, nil)
+	photosService := photos.NewService(photos.NewMySQLRepository(database.Conn), productRepo, userRepo, storageService)
+
+	ctx := context.Background()
+	var rekeyed, moved int
+
+	for afterID := 0; ; {
+		batch, err := userRepo.ListAfter(ctx, afterID, *batchSize)
+		if err != nil {
+			log.Fatalf("Failed to list users: %v", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, user := range batch {
+			afterID = user.ID
+
+			if users.IsLegacyUUID(user.UUID) {
+				newUUID, err := users.NewUUID()
+				if err != nil {
+					log.Fatal(err)
+				}
+				log.Printf("user %d: %s -> %s", user.ID, user.UUID, newUUID)
+				rekeyed++
+
+				if *dryRun {
+					continue
+				}
+				if err := userRepo.UpdateUUID(ctx, user.ID, newUUID); err != nil {
+					log.Fatalf("Failed to re-key user %d: %v", user.ID, err)
+				}
+				user.UUID = newUUID
+			}
+
+			if *dryRun {
+				continue
+			}
+			relocations := []func(context.Context, *users.User) (int, error){
+				billsService.RelocateUserBills,
+				usersService.RelocateAvatar,
+				photosService.RelocateUserPhotos,
+			}
+			for _, relocate := range relocations {
+				n, err := relocate(ctx, user)
+				moved += n
+				if err != nil {
+					log.Fatalf("Failed to move files of user %d: %v", user.ID, err)
+				}
+			}
+		}
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d users would be re-keyed", rekeyed)
+		return
+	}
+	log.Printf("Re-keyed %d users, moved %d files", rekeyed, moved)
+}
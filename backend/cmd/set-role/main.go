@@ -0,0 +1,50 @@
+// Command set-role changes a user's role, e.g. to make someone an admin:
+//
+//	go run ./cmd/set-role -email admin@example.com -role admin
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"keepsy-backend/internal/config"
+	"keepsy-backend/internal/db"
+	"keepsy-backend/internal/users"
+)
+
+func main() {
+	email := flag.String("email", "", "Email of the user")
+	role := flag.String("role", users.RoleAdmin, "New role: user or admin")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+	if *role != users.RoleUser && *role != users.RoleAdmin {
+		log.Fatalf("Unknown role %q", *role)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	database, err := db.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	userRepo := users.NewMySQLRepository(database.Conn)
+
+	user, err := userRepo.GetByEmail(ctx, *email)
+	if err != nil {
+		log.Fatalf("Failed to find user: %v", err)
+	}
+	if err := userRepo.UpdateRole(ctx, user.ID, *role); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("User %d (%s) is now %s", user.ID, user.Email, *role)
+}
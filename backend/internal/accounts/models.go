@@ -0,0 +1,15 @@
+package accounts
+
+import (
+	"time"
+)
+
+// GracePeriod is how long a deletion request can be cancelled before the
+// account and everything in it is purged.
+const GracePeriod = 14 * 24 * time.Hour
+
+type DeletionRequest struct {
+	UserID      int       `json:"-"`
+	RequestedAt time.Time `json:"requested_at"`
+	PurgeAfter  time.Time `json:"purge_after"`
+}
@@ -0,0 +1,107 @@
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrDeletionNotFound = errors.New("deletion request not found")
+
+type Repository interface {
+	CreateDeletion(ctx context.Context, req *DeletionRequest) error
+	GetDeletion(ctx context.Context, userID int) (*DeletionRequest, error)
+	DeleteDeletion(ctx context.Context, userID int) error
+	// ListDue returns the requests whose grace period ended before now.
+	ListDue(ctx context.Context, now time.Time) ([]*DeletionRequest, error)
+	// PurgeUser deletes the user's rows. Everything else the user owns goes
+	// with them through ON DELETE CASCADE (including the deletion request).
+	PurgeUser(ctx context.Context, userID int) error
+}
+
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLRepository(db *sql.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+func (r *MySQLRepository) CreateDeletion(ctx context.Context, req *DeletionRequest) error {
+	query := `INSERT INTO keepsy_account_deletions (user_id, requested_at, purge_after) VALUES (?, ?, ?)`
+	if _, err := r.db.ExecContext(ctx, query, req.UserID, req.RequestedAt, req.PurgeAfter); err != nil {
+		return fmt.Errorf("failed to create deletion request: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) GetDeletion(ctx context.Context, userID int) (*DeletionRequest, error) {
+	query := `SELECT user_id, requested_at, purge_after FROM keepsy_account_deletions WHERE user_id = ?`
+	var req DeletionRequest
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&req.UserID, &req.RequestedAt, &req.PurgeAfter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeletionNotFound
+		}
+		return nil, fmt.Errorf("failed to get deletion request: %w", err)
+	}
+	return &req, nil
+}
+
+func (r *MySQLRepository) DeleteDeletion(ctx context.Context, userID int) error {
+	query := `DELETE FROM keepsy_account_deletions WHERE user_id = ?`
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete deletion request: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) ListDue(ctx context.Context, now time.Time) ([]*DeletionRequest, error) {
+	query := `
+		SELECT user_id, requested_at, purge_after FROM keepsy_account_deletions
+		WHERE purge_after <= ? ORDER BY purge_after
+	`
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deletion requests: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*DeletionRequest
+	for rows.Next() {
+		var req DeletionRequest
+		if err := rows.Scan(&req.UserID, &req.RequestedAt, &req.PurgeAfter); err != nil {
+			return nil, fmt.Errorf("failed to scan deletion request: %w", err)
+		}
+		list = append(list, &req)
+	}
+	return list, rows.Err()
+}
+
+func (r *MySQLRepository) PurgeUser(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Reminders reference products too, so they go first. Purchase details
+	// and bills cascade from their product.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_reminders WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete reminders: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_products WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete products: %w", err)
+	}
+	// Credentials, sessions, codes, 2FA and linked identities cascade from the user
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_users WHERE id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/users"
+	"time"
+)
+
+var (
+	ErrNoDeletionPending = errors.New("no account deletion is pending")
+	ErrGracePeriodOver   = errors.New("the account is already being deleted")
+)
+
+type Service interface {
+	// RequestDeletion schedules the account for deletion after GracePeriod.
+	// Asking again returns the pending request unchanged.
+	RequestDeletion(ctx context.Context, userID int) (*DeletionRequest, error)
+	GetDeletion(ctx context.Context, userID int) (*DeletionRequest, error)
+	CancelDeletion(ctx context.Context, userID int) error
+	// PurgeDue deletes every account whose grace period has ended and returns
+	// how many were purged. A failed purge doesn't stop the others; it is
+	// retried on the next run.
+	PurgeDue(ctx context.Context, now time.Time) (int, error)
+}
+
+type service struct {
+	repo         Repository
+	userRepo     users.Repository
+	billsService bills.Service
+	storage      storage.Service
+}
+
+func NewService(repo Repository, userRepo users.Repository, billsService bills.Service, storage storage.Service) Service {
+	return &service{
+		repo:         repo,
+		userRepo:     userRepo,
+		billsService: billsService,
+		storage:      storage,
+	}
+}
+
+func (s *service) RequestDeletion(ctx context.Context, userID int) (*DeletionRequest, error) {
+	existing, err := s.repo.GetDeletion(ctx, userID)
+	if err == nil {
+		return existing, nil
+	}
+	if err != ErrDeletionNotFound {
+		return nil, err
+	}
+
+	now := time.Now()
+	req := &DeletionRequest{
+		UserID:      userID,
+		RequestedAt: now,
+		PurgeAfter:  now.Add(GracePeriod),
+	}
+	if err := s.repo.CreateDeletion(ctx, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (s *service) GetDeletion(ctx context.Context, userID int) (*DeletionRequest, error) {
+	req, err := s.repo.GetDeletion(ctx, userID)
+	if err == ErrDeletionNotFound {
+		return nil, ErrNoDeletionPending
+	}
+	return req, err
+}
+
+func (s *service) CancelDeletion(ctx context.Context, userID int) error {
+	req, err := s.GetDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// Past this point the purge may have started deleting files
+	if !time.Now().Before(req.PurgeAfter) {
+		return ErrGracePeriodOver
+	}
+	return s.repo.DeleteDeletion(ctx, userID)
+}
+
+func (s *service) PurgeDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.repo.ListDue(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var errs []error
+	for _, req := range due {
+		if err := s.purge(ctx, req.UserID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to purge user %d: %w", req.UserID, err))
+			continue
+		}
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// purge removes the user's files, then their rows. Every step can run again
+// after a partial failure: deleting a missing file succeeds, and the rows are
+// deleted in a single transaction.
+func (s *service) purge(ctx context.Context, userID int) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if err == users.ErrNotFound {
+			// Purged already, only the request was left over
+			return s.repo.DeleteDeletion(ctx, userID)
+		}
+		return err
+	}
+
+	// Bills uploaded before files were kept under <uuid>/ live elsewhere
+	userBills, err := s.billsService.ListUserBills(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, bill := range userBills {
+		if err := s.storage.Delete(ctx, bill.FileURL); err != nil {
+			return err
+		}
+	}
+
+	files, err := s.storage.List(ctx, user.UUID+"/")
+	if err != nil {
+		return err
+	}
+	for _, url := range files {
+		if err := s.storage.Delete(ctx, url); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.PurgeUser(ctx, user.ID)
+}
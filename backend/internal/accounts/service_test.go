@@ -0,0 +1,353 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepo
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) CreateDeletion(ctx context.Context, req *DeletionRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetDeletion(ctx context.Context, userID int) (*DeletionRequest, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*DeletionRequest), args.Error(1)
+}
+
+func (m *MockRepo) DeleteDeletion(ctx context.Context, userID int) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ListDue(ctx context.Context, now time.Time) ([]*DeletionRequest, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*DeletionRequest), args.Error(1)
+}
+
+func (m *MockRepo) PurgeUser(ctx context.Context, userID int) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// MockUserRepo
+type MockUserRepo struct {
+	mock.Mock
+}
+
+func (m *MockUserRepo) Create(ctx context.Context, user *users.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) GetByID(ctx context.Context, id int) (*users.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserRepo) GetByUUID(ctx context.Context, uuid string) (*users.User, error) {
+	args := m.Called(ctx, uuid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserRepo) GetByEmail(ctx context.Context, email string) (*users.User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserRepo) GetByPhone(ctx context.Context, phone string) (*users.User, error) {
+	args := m.Called(ctx, phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserRepo) MarkEmailVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) MarkPhoneVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateProfile(ctx context.Context, user *users.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateAvatar(ctx context.Context, id int, avatarURL string) error {
+	args := m.Called(ctx, id, avatarURL)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateRole(ctx context.Context, id int, role string) error {
+	args := m.Called(ctx, id, role)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateUUID(ctx context.Context, id int, uuid string) error {
+	args := m.Called(ctx, id, uuid)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) ListAfter(ctx context.Context, afterID, limit int) ([]*users.User, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*users.User), args.Error(1)
+}
+
+// MockBillsService
+type MockBillsService struct {
+	mock.Mock
+}
+
+func (m *MockBillsService) UploadBill(ctx context.Context, file io.Reader, filename, fileType string, req bills.CreateBillRequest) (*bills.Bill, error) {
+	args := m.Called(ctx, file, filename, fileType, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*bills.Bill), args.Error(1)
+}
+
+func (m *MockBillsService) ListUserBills(ctx context.Context, userID int) ([]*bills.Bill, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*bills.Bill), args.Error(1)
+}
+
+func (m *MockBillsService) GetBillDownloadURL(ctx context.Context, id, userID int) (string, error) {
+	args := m.Called(ctx, id, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockBillsService) UpdateBillText(ctx context.Context, id, userID int, text string) error {
+	args := m.Called(ctx, id, userID, text)
+	return args.Error(0)
+}
+
+func (m *MockBillsService) RelocateUserBills(ctx context.Context, user *users.User) (int, error) {
+	args := m.Called(ctx, user)
+	return args.Int(0), args.Error(1)
+}
+
+// MockStorage
+type MockStorage struct {
+	mock.Mock
+}
+
+func (m *MockStorage) Upload(ctx context.Context, file io.Reader, filename string) (string, error) {
+	args := m.Called(ctx, file, filename)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) Delete(ctx context.Context, url string) error {
+	args := m.Called(ctx, url)
+	return args.Error(0)
+}
+
+func (m *MockStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorage) Move(ctx context.Context, url, filename string) (string, error) {
+	args := m.Called(ctx, url, filename)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) Open(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadSeekCloser), args.Error(1)
+}
+
+func (m *MockStorage) GetDownloadURL(ctx context.Context, url string) (string, error) {
+	args := m.Called(ctx, url)
+	return args.String(0), args.Error(1)
+}
+
+type fixture struct {
+	repo     *MockRepo
+	userRepo *MockUserRepo
+	bills    *MockBillsService
+	storage  *MockStorage
+	service  Service
+}
+
+func newFixture() *fixture {
+	f := &fixture{
+		repo:     new(MockRepo),
+		userRepo: new(MockUserRepo),
+		bills:    new(MockBillsService),
+		storage:  new(MockStorage),
+	}
+	f.service = NewService(f.repo, f.userRepo, f.bills, f.storage)
+	return f
+}
+
+func TestRequestDeletion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Schedules After Grace Period", func(t *testing.T) {
+		f := newFixture()
+		f.repo.On("GetDeletion", ctx, 1).Return(nil, ErrDeletionNotFound)
+		f.repo.On("CreateDeletion", ctx, mock.MatchedBy(func(req *DeletionRequest) bool {
+			return req.UserID == 1 && req.PurgeAfter.Sub(req.RequestedAt) == GracePeriod
+		})).Return(nil)
+
+		req, err := f.service.RequestDeletion(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 14*24*time.Hour, req.PurgeAfter.Sub(req.RequestedAt))
+		f.repo.AssertExpectations(t)
+	})
+
+	t.Run("Pending Request Is Kept", func(t *testing.T) {
+		f := newFixture()
+		existing := &DeletionRequest{UserID: 1, RequestedAt: time.Now().Add(-time.Hour), PurgeAfter: time.Now().Add(GracePeriod - time.Hour)}
+		f.repo.On("GetDeletion", ctx, 1).Return(existing, nil)
+
+		req, err := f.service.RequestDeletion(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, existing, req)
+		f.repo.AssertNotCalled(t, "CreateDeletion", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCancelDeletion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Within Grace Period", func(t *testing.T) {
+		f := newFixture()
+		f.repo.On("GetDeletion", ctx, 1).Return(&DeletionRequest{UserID: 1, PurgeAfter: time.Now().Add(time.Hour)}, nil)
+		f.repo.On("DeleteDeletion", ctx, 1).Return(nil)
+
+		err := f.service.CancelDeletion(ctx, 1)
+
+		assert.NoError(t, err)
+		f.repo.AssertExpectations(t)
+	})
+
+	t.Run("Grace Period Over", func(t *testing.T) {
+		f := newFixture()
+		f.repo.On("GetDeletion", ctx, 1).Return(&DeletionRequest{UserID: 1, PurgeAfter: time.Now().Add(-time.Minute)}, nil)
+
+		err := f.service.CancelDeletion(ctx, 1)
+
+		assert.Equal(t, ErrGracePeriodOver, err)
+		f.repo.AssertNotCalled(t, "DeleteDeletion", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Nothing Pending", func(t *testing.T) {
+		f := newFixture()
+		f.repo.On("GetDeletion", ctx, 1).Return(nil, ErrDeletionNotFound)
+
+		err := f.service.CancelDeletion(ctx, 1)
+
+		assert.Equal(t, ErrNoDeletionPending, err)
+	})
+}
+
+func TestPurgeDue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	user := &users.User{ID: 1, UUID: "0190f6e4-7b1c-7cc3-9a5e-2f4c8d1e6b3a"}
+
+	t.Run("Deletes Files Then Rows", func(t *testing.T) {
+		f := newFixture()
+		f.repo.On("ListDue", ctx, now).Return([]*DeletionRequest{{UserID: 1}}, nil)
+		f.userRepo.On("GetByID", ctx, 1).Return(user, nil)
+		f.bills.On("ListUserBills", ctx, 1).Return([]*bills.Bill{
+			{ID: 7, FileURL: "http://localhost/uploads/123_old.pdf"},
+		}, nil)
+		f.storage.On("Delete", ctx, "http://localhost/uploads/123_old.pdf").Return(nil)
+		f.storage.On("List", ctx, user.UUID+"/").Return([]string{
+			"http://localhost/uploads/" + user.UUID + "/bills/1_a.pdf",
+			"http://localhost/uploads/" + user.UUID + "/avatar/2_me.jpg",
+		}, nil)
+		f.storage.On("Delete", ctx, "http://localhost/uploads/"+user.UUID+"/bills/1_a.pdf").Return(nil)
+		f.storage.On("Delete", ctx, "http://localhost/uploads/"+user.UUID+"/avatar/2_me.jpg").Return(nil)
+		f.repo.On("PurgeUser", ctx, 1).Return(nil)
+
+		purged, err := f.service.PurgeDue(ctx, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		f.storage.AssertExpectations(t)
+		f.repo.AssertExpectations(t)
+	})
+
+	t.Run("Already Purged User", func(t *testing.T) {
+		f := newFixture()
+		f.repo.On("ListDue", ctx, now).Return([]*DeletionRequest{{UserID: 1}}, nil)
+		f.userRepo.On("GetByID", ctx, 1).Return(nil, users.ErrNotFound)
+		f.repo.On("DeleteDeletion", ctx, 1).Return(nil)
+
+		purged, err := f.service.PurgeDue(ctx, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		f.repo.AssertNotCalled(t, "PurgeUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Failure Keeps Rows And Continues", func(t *testing.T) {
+		f := newFixture()
+		other := &users.User{ID: 2, UUID: "0190f6e4-7b1c-7cc3-9a5e-000000000002"}
+		f.repo.On("ListDue", ctx, now).Return([]*DeletionRequest{{UserID: 1}, {UserID: 2}}, nil)
+		f.userRepo.On("GetByID", ctx, 1).Return(user, nil)
+		f.userRepo.On("GetByID", ctx, 2).Return(other, nil)
+		f.bills.On("ListUserBills", ctx, 1).Return([]*bills.Bill{}, nil)
+		f.bills.On("ListUserBills", ctx, 2).Return([]*bills.Bill{}, nil)
+		f.storage.On("List", ctx, user.UUID+"/").Return(nil, errors.New("disk error"))
+		f.storage.On("List", ctx, other.UUID+"/").Return([]string{}, nil)
+		f.repo.On("PurgeUser", ctx, 2).Return(nil)
+
+		purged, err := f.service.PurgeDue(ctx, now)
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, purged)
+		f.repo.AssertNotCalled(t, "PurgeUser", mock.Anything, 1)
+	})
+}
@@ -2,6 +2,10 @@ package bills
 
 import (
 	"encoding/json"
+	"errors"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/authz"
 	"net/http"
 	"strconv"
 )
@@ -30,14 +34,9 @@ func (h *Handler) UploadBill(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	// 2. Parse Other Fields
-	userIDStr := r.FormValue("user_id")
-	if userIDStr == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
-		return
-	}
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -60,7 +59,7 @@ func (h *Handler) UploadBill(w http.ResponseWriter, r *http.Request) {
 	// 3. Call Service
 	bill, err := h.service.UploadBill(r.Context(), file, header.Filename, header.Header.Get("Content-Type"), req)
 	if err != nil {
-		http.Error(w, "Failed to upload bill: "+err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err, "Failed to upload bill: "+err.Error())
 		return
 	}
 
@@ -69,15 +68,9 @@ func (h *Handler) UploadBill(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListBills(w http.ResponseWriter, r *http.Request) {
-	userIdStr := r.URL.Query().Get("user_id")
-	if userIdStr == "" {
-		http.Error(w, "Missing user_id", http.StatusBadRequest)
-		return
-	}
-
-	userID, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -94,15 +87,9 @@ func (h *Handler) ListBills(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) DownloadBill(w http.ResponseWriter, r *http.Request) {
-	userIdStr := r.URL.Query().Get("user_id")
-	if userIdStr == "" {
-		http.Error(w, "Missing user_id", http.StatusBadRequest)
-		return
-	}
-
-	userID, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -120,13 +107,53 @@ func (h *Handler) DownloadBill(w http.ResponseWriter, r *http.Request) {
 
 	url, err := h.service.GetBillDownloadURL(r.Context(), id, userID)
 	if err != nil {
-		if err.Error() == "unauthorized access to bill" {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		} else {
-			http.Error(w, "Failed to get download URL", http.StatusInternalServerError)
-		}
+		writeServiceError(w, err, "Failed to get download URL")
 		return
 	}
 
 	http.Redirect(w, r, url, http.StatusFound)
 }
+
+// UpdateBillText stores the text read from a bill, e.g. after scanning it
+// on the device.
+func (h *Handler) UpdateBillText(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid bill id", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateBillTextRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.UpdateBillText(r.Context(), id, userID, req.Text); err != nil {
+		writeServiceError(w, err, "Failed to update bill text")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrBillNotFound):
+		http.Error(w, "Bill not found", http.StatusNotFound)
+	case errors.Is(err, products.ErrProductNotFound):
+		http.Error(w, "Product not found", http.StatusNotFound)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, ErrTextTooLong):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
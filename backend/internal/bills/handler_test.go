@@ -0,0 +1,70 @@
+package bills
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"keepsy-backend/internal/services/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandlerRejectsOtherUsers(t *testing.T) {
+	withUser := func(req *http.Request, userID int) *http.Request {
+		return req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: userID}))
+	}
+
+	t.Run("Upload", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		handler := NewHandler(NewService(mockRepo, new(MockUserRepo), productsOwnedBy(1), mockStorage))
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("product_id", "100")
+		part, _ := form.CreateFormFile("file", "bill.pdf")
+		part.Write([]byte("content"))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/bills/upload", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		handler.UploadBill(rec, withUser(req, 2))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		mockStorage.AssertNotCalled(t, "Upload", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		handler := NewHandler(NewService(mockRepo, new(MockUserRepo), new(MockProductRepo), mockStorage))
+
+		mockRepo.On("GetByID", mock.Anything, 1).Return(&Bill{ID: 1, UserID: 1, FileURL: "http://url/file.pdf"}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/bills/download?id=1", nil)
+		rec := httptest.NewRecorder()
+		handler.DownloadBill(rec, withUser(req, 2))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		mockStorage.AssertNotCalled(t, "GetDownloadURL", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Download Missing Bill", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		handler := NewHandler(NewService(mockRepo, new(MockUserRepo), new(MockProductRepo), new(MockStorage)))
+
+		mockRepo.On("GetByID", mock.Anything, 7).Return(nil, ErrBillNotFound)
+
+		req := httptest.NewRequest(http.MethodGet, "/bills/download?id=7", nil)
+		rec := httptest.NewRecorder()
+		handler.DownloadBill(rec, withUser(req, 1))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -14,7 +14,16 @@ type Bill struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// OwnerID implements authz.Owned.
+func (b *Bill) OwnerID() int { return b.UserID }
+
 type CreateBillRequest struct {
 	UserID    int `json:"-"` // From Context/Auth
 	ProductID int `json:"product_id"`
 }
+
+// UpdateBillTextRequest carries the text read from the bill's file (e.g. by
+// OCR on the device). It's what search matches bills against.
+type UpdateBillTextRequest struct {
+	Text string `json:"text"`
+}
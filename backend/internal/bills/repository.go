@@ -3,13 +3,23 @@ package bills
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 )
 
+var ErrBillNotFound = errors.New("bill not found")
+
 type Repository interface {
 	Create(ctx context.Context, bill *Bill) error
 	ListByUserID(ctx context.Context, userID int) ([]*Bill, error)
 	GetByID(ctx context.Context, id int) (*Bill, error)
+	// GetByFileURL returns the bill stored at fileURL.
+	GetByFileURL(ctx context.Context, fileURL string) (*Bill, error)
+	UpdateFileURL(ctx context.Context, id int, fileURL string) error
+	// UpdateExtractedText stores the bill's text for search. Empty text
+	// clears it.
+	UpdateExtractedText(ctx context.Context, id int, text string) error
 }
 
 type mysqlRepository struct {
@@ -75,10 +85,45 @@ func (r *mysqlRepository) GetByID(ctx context.Context, id int) (*Bill, error) {
 
 	if err := row.Scan(&b.ID, &b.UserID, &b.ProductID, &b.FileURL, &b.FileType, &b.CreatedAt, &b.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("bill not found")
+			return nil, ErrBillNotFound
+		}
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (r *mysqlRepository) GetByFileURL(ctx context.Context, fileURL string) (*Bill, error) {
+	query := `SELECT b.id, p.user_id, b.product_id, b.file_url, b.file_type, b.created_at, b.updated_at 
+              FROM keepsy_bills b
+              JOIN keepsy_products p ON b.product_id = p.id
+              WHERE b.file_url = ?`
+
+	row := r.db.QueryRowContext(ctx, query, fileURL)
+	b := &Bill{}
+
+	if err := row.Scan(&b.ID, &b.UserID, &b.ProductID, &b.FileURL, &b.FileType, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrBillNotFound
 		}
 		return nil, err
 	}
 
 	return b, nil
 }
+
+func (r *mysqlRepository) UpdateFileURL(ctx context.Context, id int, fileURL string) error {
+	query := `UPDATE keepsy_bills SET file_url = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, fileURL, id); err != nil {
+		return fmt.Errorf("failed to update bill file url: %w", err)
+	}
+	return nil
+}
+
+func (r *mysqlRepository) UpdateExtractedText(ctx context.Context, id int, text string) error {
+	query := `UPDATE keepsy_bills SET extracted_text = NULLIF(?, ''), updated_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, text, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update bill text: %w", err)
+	}
+	return nil
+}
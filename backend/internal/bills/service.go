@@ -5,40 +5,66 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
 	"keepsy-backend/internal/services/storage"
 	"keepsy-backend/internal/users"
+	"path"
+	"strings"
 	"time"
 )
 
+// MaxTextLength caps the text stored for a bill, in bytes.
+const MaxTextLength = 1 << 20
+
+var ErrTextTooLong = errors.New("bill text is too long")
+
 type Service interface {
 	UploadBill(ctx context.Context, file io.Reader, filename, fileType string, req CreateBillRequest) (*Bill, error)
 	ListUserBills(ctx context.Context, userID int) ([]*Bill, error)
 	GetBillDownloadURL(ctx context.Context, id, userID int) (string, error)
+	// UpdateBillText stores the text read from the bill's file, so the bill
+	// can be found by it through search.
+	UpdateBillText(ctx context.Context, id, userID int, text string) error
+	// RelocateUserBills moves bill files that aren't stored under the user's
+	// current UUID prefix (e.g. after re-keying) and returns how many moved.
+	RelocateUserBills(ctx context.Context, user *users.User) (int, error)
 }
 
 type service struct {
-	repo     Repository
-	userRepo users.Repository
-	storage  storage.Service
+	repo        Repository
+	userRepo    users.Repository
+	productRepo products.Repository
+	storage     storage.Service
 }
 
-func NewService(repo Repository, userRepo users.Repository, storage storage.Service) Service {
+func NewService(repo Repository, userRepo users.Repository, productRepo products.Repository, storage storage.Service) Service {
 	return &service{
-		repo:     repo,
-		userRepo: userRepo,
-		storage:  storage,
+		repo:        repo,
+		userRepo:    userRepo,
+		productRepo: productRepo,
+		storage:     storage,
 	}
 }
 
 func (s *service) UploadBill(ctx context.Context, file io.Reader, filename, fileType string, req CreateBillRequest) (*Bill, error) {
-	// 0. Get User UUID
+	// 0. The bill can only be attached to one of the user's own products
+	product, err := s.productRepo.GetByID(ctx, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(req.UserID, product); err != nil {
+		return nil, err
+	}
+
+	// Get User UUID
 	user, err := s.userRepo.GetByID(ctx, req.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	// 1. Upload to Storage (Path: <uuid>/bills/<filename>)
-	storagePath := fmt.Sprintf("%s/bills/%s", user.UUID, filename)
+	storagePath := billPath(user.UUID, filename)
 	url, err := s.storage.Upload(ctx, file, storagePath)
 	if err != nil {
 		return nil, fmt.Errorf("storage upload failed: %w", err)
@@ -73,9 +99,55 @@ func (s *service) GetBillDownloadURL(ctx context.Context, id, userID int) (strin
 		return "", err
 	}
 
-	if bill.UserID != userID {
-		return "", errors.New("unauthorized access to bill")
+	if err := authz.Authorize(userID, bill); err != nil {
+		return "", err
 	}
 
 	return s.storage.GetDownloadURL(ctx, bill.FileURL)
 }
+
+func (s *service) UpdateBillText(ctx context.Context, id, userID int, text string) error {
+	text = strings.TrimSpace(text)
+	if len(text) > MaxTextLength {
+		return ErrTextTooLong
+	}
+
+	bill, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := authz.Authorize(userID, bill); err != nil {
+		return err
+	}
+
+	return s.repo.UpdateExtractedText(ctx, bill.ID, text)
+}
+
+func (s *service) RelocateUserBills(ctx context.Context, user *users.User) (int, error) {
+	bills, err := s.repo.ListByUserID(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, bill := range bills {
+		if strings.Contains(bill.FileURL, "/"+billPath(user.UUID, "")) {
+			continue
+		}
+
+		url, err := s.storage.Move(ctx, bill.FileURL, billPath(user.UUID, path.Base(bill.FileURL)))
+		if err != nil {
+			return moved, fmt.Errorf("failed to move bill %d: %w", bill.ID, err)
+		}
+		if err := s.repo.UpdateFileURL(ctx, bill.ID, url); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// billPath is where a user's bill is stored: <uuid>/bills/<filename>
+func billPath(userUUID, filename string) string {
+	return fmt.Sprintf("%s/bills/%s", userUUID, filename)
+}
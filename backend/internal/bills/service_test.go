@@ -6,7 +6,10 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
 	"keepsy-backend/internal/users"
 
 	"github.com/stretchr/testify/assert"
@@ -43,6 +46,24 @@ func (m *MockRepo) GetByID(ctx context.Context, id int) (*Bill, error) {
 	return args.Get(0).(*Bill), args.Error(1)
 }
 
+func (m *MockRepo) GetByFileURL(ctx context.Context, fileURL string) (*Bill, error) {
+	args := m.Called(ctx, fileURL)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Bill), args.Error(1)
+}
+
+func (m *MockRepo) UpdateFileURL(ctx context.Context, id int, fileURL string) error {
+	args := m.Called(ctx, id, fileURL)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateExtractedText(ctx context.Context, id int, text string) error {
+	args := m.Called(ctx, id, text)
+	return args.Error(0)
+}
+
 // MockUserRepo
 type MockUserRepo struct {
 	mock.Mock
@@ -85,6 +106,44 @@ func (m *MockUserRepo) GetByUUID(ctx context.Context, uuid string) (*users.User,
 	return args.Get(0).(*users.User), args.Error(1)
 }
 
+func (m *MockUserRepo) MarkEmailVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) MarkPhoneVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateProfile(ctx context.Context, user *users.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateAvatar(ctx context.Context, id int, avatarURL string) error {
+	args := m.Called(ctx, id, avatarURL)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateRole(ctx context.Context, id int, role string) error {
+	args := m.Called(ctx, id, role)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateUUID(ctx context.Context, id int, uuid string) error {
+	args := m.Called(ctx, id, uuid)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) ListAfter(ctx context.Context, afterID, limit int) ([]*users.User, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*users.User), args.Error(1)
+}
+
 // MockStorage
 type MockStorage struct {
 	mock.Mock
@@ -100,17 +159,92 @@ func (m *MockStorage) Delete(ctx context.Context, url string) error {
 	return args.Error(0)
 }
 
+func (m *MockStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorage) Move(ctx context.Context, url, filename string) (string, error) {
+	args := m.Called(ctx, url, filename)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) Open(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadSeekCloser), args.Error(1)
+}
+
 func (m *MockStorage) GetDownloadURL(ctx context.Context, url string) (string, error) {
 	args := m.Called(ctx, url)
 	return args.String(0), args.Error(1)
 }
 
+// MockProductRepo
+type MockProductRepo struct {
+	mock.Mock
+}
+
+func (m *MockProductRepo) Create(ctx context.Context, product *products.Product) error {
+	args := m.Called(ctx, product)
+	return args.Error(0)
+}
+
+func (m *MockProductRepo) GetByID(ctx context.Context, id int) (*products.Product, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*products.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) List(ctx context.Context, query products.ListQuery) ([]*products.Product, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*products.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) ListExpiring(ctx context.Context, userID int, from, to time.Time) ([]*products.Product, error) {
+	args := m.Called(ctx, userID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*products.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) Update(ctx context.Context, product *products.Product) error {
+	args := m.Called(ctx, product)
+	return args.Error(0)
+}
+
+func (m *MockProductRepo) Delete(ctx context.Context, id int) ([]string, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// productsOwnedBy returns a product repo where every product belongs to userID.
+func productsOwnedBy(userID int) *MockProductRepo {
+	repo := new(MockProductRepo)
+	repo.On("GetByID", mock.Anything, mock.Anything).Return(&products.Product{ID: 100, UserID: userID}, nil)
+	return repo
+}
+
 func TestUploadBill(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		mockRepo := new(MockRepo)
 		mockUserRepo := new(MockUserRepo)
 		mockStorage := new(MockStorage)
-		service := NewService(mockRepo, mockUserRepo, mockStorage)
+		service := NewService(mockRepo, mockUserRepo, productsOwnedBy(1), mockStorage)
 
 		fileContent := "dummy content"
 		file := strings.NewReader(fileContent)
@@ -145,7 +279,7 @@ func TestUploadBill(t *testing.T) {
 		mockRepo := new(MockRepo)
 		mockUserRepo := new(MockUserRepo)
 		mockStorage := new(MockStorage)
-		service := NewService(mockRepo, mockUserRepo, mockStorage)
+		service := NewService(mockRepo, mockUserRepo, productsOwnedBy(1), mockStorage)
 
 		file := strings.NewReader("content")
 
@@ -166,7 +300,7 @@ func TestUploadBill(t *testing.T) {
 		mockRepo := new(MockRepo)
 		mockUserRepo := new(MockUserRepo)
 		mockStorage := new(MockStorage)
-		service := NewService(mockRepo, mockUserRepo, mockStorage)
+		service := NewService(mockRepo, mockUserRepo, productsOwnedBy(1), mockStorage)
 
 		file := strings.NewReader("content")
 
@@ -184,6 +318,32 @@ func TestUploadBill(t *testing.T) {
 		assert.Contains(t, err.Error(), "db failed")
 		mockStorage.AssertCalled(t, "Delete", mock.Anything, "http://url")
 	})
+
+	t.Run("OtherUsersProduct", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, mockUserRepo, productsOwnedBy(2), mockStorage)
+
+		_, err := service.UploadBill(context.Background(), strings.NewReader("content"), "test.pdf", "application/pdf", CreateBillRequest{UserID: 1, ProductID: 100})
+
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		mockStorage.AssertNotCalled(t, "Upload", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("UnknownProduct", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(new(MockRepo), new(MockUserRepo), mockProductRepo, mockStorage)
+
+		mockProductRepo.On("GetByID", mock.Anything, 100).Return(nil, products.ErrProductNotFound)
+
+		_, err := service.UploadBill(context.Background(), strings.NewReader("content"), "test.pdf", "application/pdf", CreateBillRequest{UserID: 1, ProductID: 100})
+
+		assert.ErrorIs(t, err, products.ErrProductNotFound)
+		mockStorage.AssertNotCalled(t, "Upload", mock.Anything, mock.Anything, mock.Anything)
+	})
 }
 
 func TestGetBillDownloadURL(t *testing.T) {
@@ -191,7 +351,7 @@ func TestGetBillDownloadURL(t *testing.T) {
 		mockRepo := new(MockRepo)
 		mockUserRepo := new(MockUserRepo)
 		mockStorage := new(MockStorage)
-		service := NewService(mockRepo, mockUserRepo, mockStorage)
+		service := NewService(mockRepo, mockUserRepo, new(MockProductRepo), mockStorage)
 
 		bill := &Bill{ID: 1, UserID: 1, FileURL: "http://url/file.pdf"}
 		mockRepo.On("GetByID", mock.Anything, 1).Return(bill, nil)
@@ -207,22 +367,22 @@ func TestGetBillDownloadURL(t *testing.T) {
 		mockRepo := new(MockRepo)
 		mockUserRepo := new(MockUserRepo)
 		mockStorage := new(MockStorage)
-		service := NewService(mockRepo, mockUserRepo, mockStorage)
+		service := NewService(mockRepo, mockUserRepo, new(MockProductRepo), mockStorage)
 
 		bill := &Bill{ID: 1, UserID: 1, FileURL: "http://url/file.pdf"}
 		mockRepo.On("GetByID", mock.Anything, 1).Return(bill, nil)
 
 		_, err := service.GetBillDownloadURL(context.Background(), 1, 999) // Different user
 
-		assert.Error(t, err)
-		assert.Equal(t, "unauthorized access to bill", err.Error())
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		mockStorage.AssertNotCalled(t, "GetDownloadURL", mock.Anything, mock.Anything)
 	})
 
 	t.Run("NotFound", func(t *testing.T) {
 		mockRepo := new(MockRepo)
 		mockUserRepo := new(MockUserRepo)
 		mockStorage := new(MockStorage)
-		service := NewService(mockRepo, mockUserRepo, mockStorage)
+		service := NewService(mockRepo, mockUserRepo, new(MockProductRepo), mockStorage)
 
 		mockRepo.On("GetByID", mock.Anything, 1).Return(nil, errors.New("not found"))
 
@@ -232,3 +392,78 @@ func TestGetBillDownloadURL(t *testing.T) {
 		assert.Equal(t, "not found", err.Error())
 	})
 }
+
+func TestUpdateBillText(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, new(MockUserRepo), new(MockProductRepo), new(MockStorage))
+
+		mockRepo.On("GetByID", mock.Anything, 1).Return(&Bill{ID: 1, UserID: 1}, nil)
+		mockRepo.On("UpdateExtractedText", mock.Anything, 1, "TAX INVOICE\nCroma Retail").Return(nil)
+
+		err := service.UpdateBillText(context.Background(), 1, 1, "  TAX INVOICE\nCroma Retail\n")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, new(MockUserRepo), new(MockProductRepo), new(MockStorage))
+
+		mockRepo.On("GetByID", mock.Anything, 1).Return(&Bill{ID: 1, UserID: 1}, nil)
+
+		err := service.UpdateBillText(context.Background(), 1, 999, "TAX INVOICE")
+
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		mockRepo.AssertNotCalled(t, "UpdateExtractedText", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Too Long", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, new(MockUserRepo), new(MockProductRepo), new(MockStorage))
+
+		err := service.UpdateBillText(context.Background(), 1, 1, strings.Repeat("a", MaxTextLength+1))
+
+		assert.Equal(t, ErrTextTooLong, err)
+		mockRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRelocateUserBills(t *testing.T) {
+	t.Run("MovesFilesOutsidePrefix", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, new(MockUserRepo), new(MockProductRepo), mockStorage)
+
+		user := &users.User{ID: 1, UUID: "new-uuid"}
+		mockRepo.On("ListByUserID", mock.Anything, 1).Return([]*Bill{
+			{ID: 1, FileURL: "http://storage/old-uuid/bills/1_a.pdf"},
+			{ID: 2, FileURL: "http://storage/new-uuid/bills/2_b.pdf"},
+		}, nil)
+		mockStorage.On("Move", mock.Anything, "http://storage/old-uuid/bills/1_a.pdf", "new-uuid/bills/1_a.pdf").
+			Return("http://storage/new-uuid/bills/1_a.pdf", nil)
+		mockRepo.On("UpdateFileURL", mock.Anything, 1, "http://storage/new-uuid/bills/1_a.pdf").Return(nil)
+
+		moved, err := service.RelocateUserBills(context.Background(), user)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, moved)
+		mockRepo.AssertExpectations(t)
+		mockStorage.AssertNumberOfCalls(t, "Move", 1)
+	})
+
+	t.Run("MoveFailure", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, new(MockUserRepo), new(MockProductRepo), mockStorage)
+
+		mockRepo.On("ListByUserID", mock.Anything, 1).Return([]*Bill{{ID: 1, FileURL: "http://storage/old-uuid/bills/a.pdf"}}, nil)
+		mockStorage.On("Move", mock.Anything, mock.Anything, mock.Anything).Return("", errors.New("disk full"))
+
+		_, err := service.RelocateUserBills(context.Background(), &users.User{ID: 1, UUID: "new-uuid"})
+
+		assert.ErrorContains(t, err, "disk full")
+		mockRepo.AssertNotCalled(t, "UpdateFileURL", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -3,6 +3,7 @@ package categories
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 )
 
 type Handler struct {
@@ -13,14 +14,6 @@ func NewHandler(service Service) *Handler {
 	return &Handler{service: service}
 }
 
-// CreateCategory is disabled/not part of service yet strictly,
-// but we can just comment it out or leave it if we add it to service.
-// For now, let's just implement List.
-// The original code had CreateCategory but we are refactoring.
-// If we want to keep CreateCategory we should add it to Service.
-// Looking at original code: "Disabled per requirements" in main.go
-// So we can probably just ignore it or remove it from handler.
-
 func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
 	categories, err := h.service.ListCategories(r.Context())
 	if err != nil {
@@ -30,3 +23,185 @@ func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(categories)
 }
+
+// ListAllCategories includes inactive categories (admin only).
+func (h *Handler) ListAllCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.service.ListAllCategories(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch categories", http.StatusInternalServerError)
+		return
+	}
+
+	if categories == nil {
+		categories = []*Category{}
+	}
+	json.NewEncoder(w).Encode(categories)
+}
+
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req CreateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.service.CreateCategory(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create category")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategory renames, changes the slug or (de)activates a category.
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.service.UpdateCategory(r.Context(), id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to update category")
+		return
+	}
+
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *Handler) ReparentCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	var req ReparentCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.service.ReparentCategory(r.Context(), id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to move category")
+		return
+	}
+
+	json.NewEncoder(w).Encode(category)
+}
+
+// ListAttributes returns the custom attributes of the category's products.
+func (h *Handler) ListAttributes(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	attributes, err := h.service.ListAttributes(r.Context(), categoryID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to fetch attributes")
+		return
+	}
+
+	json.NewEncoder(w).Encode(attributes)
+}
+
+func (h *Handler) CreateAttribute(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateAttributeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	attribute, err := h.service.CreateAttribute(r.Context(), categoryID, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create attribute")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attribute)
+}
+
+// UpdateAttribute changes an attribute's label, required flag or options.
+func (h *Handler) UpdateAttribute(w http.ResponseWriter, r *http.Request) {
+	categoryID, id, ok := attributeParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateAttributeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	attribute, err := h.service.UpdateAttribute(r.Context(), categoryID, id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to update attribute")
+		return
+	}
+
+	json.NewEncoder(w).Encode(attribute)
+}
+
+func (h *Handler) DeleteAttribute(w http.ResponseWriter, r *http.Request) {
+	categoryID, id, ok := attributeParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteAttribute(r.Context(), categoryID, id); err != nil {
+		writeServiceError(w, err, "Failed to delete attribute")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attributeParams parses the category and attribute IDs from the path,
+// writing the error response if they're invalid.
+func attributeParams(w http.ResponseWriter, r *http.Request) (categoryID, id int, ok bool) {
+	categoryID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	id, err = strconv.Atoi(r.PathValue("attributeID"))
+	if err != nil {
+		http.Error(w, "Invalid attribute id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return categoryID, id, true
+}
+
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch err {
+	case ErrCategoryNotFound, ErrAttributeNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrSlugTaken, ErrAttributeKeyTaken, ErrAttributeConflict:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case ErrNameRequired, ErrInvalidSlug, ErrParentNotFound, ErrCategoryCycle, ErrInvalidPeriod,
+		ErrInvalidAttributeKey, ErrLabelRequired, ErrInvalidAttributeType, ErrInvalidOptions:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
@@ -6,21 +6,96 @@ import (
 )
 
 type Category struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Slug      string    `json:"slug"`
-	ParentID  *int      `json:"parent_id,omitempty"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                    int       `json:"id"`
+	Name                  string    `json:"name"`
+	Slug                  string    `json:"slug"`
+	ParentID              *int      `json:"parent_id,omitempty"`
+	IsActive              bool      `json:"is_active"`
+	DefaultWarrantyMonths *int      `json:"default_warranty_months,omitempty"` // Infers warranty end dates from purchase dates
+	CreatedAt             time.Time `json:"created_at"`
 }
 
 type CreateCategoryRequest struct {
-	Name     string `json:"name"`
-	Slug     string `json:"slug"`
-	ParentID *int   `json:"parent_id,omitempty"`
+	Name                  string `json:"name"`
+	Slug                  string `json:"slug"`
+	ParentID              *int   `json:"parent_id,omitempty"`
+	DefaultWarrantyMonths *int   `json:"default_warranty_months,omitempty"`
+}
+
+// UpdateCategoryRequest is a partial update: fields left out stay unchanged.
+type UpdateCategoryRequest struct {
+	Name                  *string `json:"name"`
+	Slug                  *string `json:"slug"`
+	IsActive              *bool   `json:"is_active"`
+	DefaultWarrantyMonths *int    `json:"default_warranty_months"` // 0 removes the default
+}
+
+type ReparentCategoryRequest struct {
+	ParentID *int `json:"parent_id"` // Null moves the category to the top level
+}
+
+// Attribute types.
+const (
+	AttributeString = "string"
+	AttributeNumber = "number"
+	AttributeDate   = "date" // YYYY-MM-DD
+	AttributeEnum   = "enum" // One of Options
+)
+
+// Attribute is a custom field the category's products have, like a phone's
+// IMEI. Products store their values by Key.
+type Attribute struct {
+	ID         int       `json:"id"`
+	CategoryID int       `json:"category_id"`
+	Key        string    `json:"key"`
+	Label      string    `json:"label"`
+	Type       string    `json:"type"`
+	Required   bool      `json:"required"`
+	Options    []string  `json:"options,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type CreateAttributeRequest struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// UpdateAttributeRequest is a partial update. Key and type can't change,
+// as products already have values for them, and an attribute can only
+// become required, or lose options, once no product conflicts with that.
+type UpdateAttributeRequest struct {
+	Label    *string   `json:"label"`
+	Required *bool     `json:"required"`
+	Options  *[]string `json:"options"` // Enums only; values products already have must stay allowed
 }
 
 type Repository interface {
 	Create(ctx context.Context, category *Category) error
+	// List returns the active categories.
 	List(ctx context.Context) ([]*Category, error)
+	// ListAll returns all categories, including inactive ones.
+	ListAll(ctx context.Context) ([]*Category, error)
+	GetByID(ctx context.Context, id int) (*Category, error)
+	GetBySlug(ctx context.Context, slug string) (*Category, error)
+	// Update saves name, slug, parent, active state and default warranty. It returns
+	// ErrSlugTaken if another category has the slug.
+	Update(ctx context.Context, category *Category) error
+
+	// CreateAttribute returns ErrAttributeKeyTaken if the category already
+	// has the key, and ErrAttributeConflict if it is required but the
+	// category already has products.
+	CreateAttribute(ctx context.Context, attribute *Attribute) error
+	GetAttribute(ctx context.Context, id int) (*Attribute, error)
+	// ListAttributes returns the category's attributes in the order they
+	// were added.
+	ListAttributes(ctx context.Context, categoryID int) ([]*Attribute, error)
+	// UpdateAttribute saves label, required and options. It returns
+	// ErrAttributeConflict if a product of the category has no value for a
+	// required attribute, or one that isn't among the options.
+	UpdateAttribute(ctx context.Context, attribute *Attribute) error
+	// DeleteAttribute removes the attribute with the products' values for it.
+	DeleteAttribute(ctx context.Context, id int) error
 }
@@ -3,8 +3,18 @@ package categories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrCategoryNotFound  = errors.New("category not found")
+	ErrAttributeNotFound = errors.New("attribute not found")
 )
 
 type MySQLRepository struct {
@@ -15,16 +25,23 @@ func NewMySQLRepository(db *sql.DB) *MySQLRepository {
 	return &MySQLRepository{db: db}
 }
 
+const categoryColumns = `id, name, slug, parent_id, is_active, default_warranty_months, created_at`
+
 func (r *MySQLRepository) Create(ctx context.Context, category *Category) error {
 	query := `
-		INSERT INTO keepsy_categories (name, slug, parent_id, is_active, created_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO keepsy_categories (name, slug, parent_id, is_active, default_warranty_months, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
 	category.CreatedAt = time.Now()
 	category.IsActive = true
 
-	result, err := r.db.ExecContext(ctx, query, category.Name, category.Slug, category.ParentID, category.IsActive, category.CreatedAt)
+	result, err := r.db.ExecContext(ctx, query,
+		category.Name, category.Slug, category.ParentID, category.IsActive, category.DefaultWarrantyMonths, category.CreatedAt,
+	)
 	if err != nil {
+		if isDuplicate(err) {
+			return ErrSlugTaken
+		}
 		return fmt.Errorf("failed to create category: %w", err)
 	}
 
@@ -37,7 +54,43 @@ func (r *MySQLRepository) Create(ctx context.Context, category *Category) error
 }
 
 func (r *MySQLRepository) List(ctx context.Context) ([]*Category, error) {
-	query := `SELECT id, name, slug, parent_id, is_active, created_at FROM keepsy_categories WHERE is_active = true ORDER BY name ASC`
+	query := `SELECT ` + categoryColumns + ` FROM keepsy_categories WHERE is_active = true ORDER BY name ASC`
+	return r.list(ctx, query)
+}
+
+func (r *MySQLRepository) ListAll(ctx context.Context) ([]*Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM keepsy_categories ORDER BY name ASC`
+	return r.list(ctx, query)
+}
+
+func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM keepsy_categories WHERE id = ?`
+	return r.getOne(ctx, query, id)
+}
+
+func (r *MySQLRepository) GetBySlug(ctx context.Context, slug string) (*Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM keepsy_categories WHERE slug = ?`
+	return r.getOne(ctx, query, slug)
+}
+
+func (r *MySQLRepository) Update(ctx context.Context, category *Category) error {
+	query := `
+		UPDATE keepsy_categories SET name = ?, slug = ?, parent_id = ?, is_active = ?, default_warranty_months = ?
+		WHERE id = ?
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		category.Name, category.Slug, category.ParentID, category.IsActive, category.DefaultWarrantyMonths, category.ID,
+	)
+	if err != nil {
+		if isDuplicate(err) {
+			return ErrSlugTaken
+		}
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) list(ctx context.Context, query string) ([]*Category, error) {
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list categories: %w", err)
@@ -47,7 +100,7 @@ func (r *MySQLRepository) List(ctx context.Context) ([]*Category, error) {
 	var categories []*Category
 	for rows.Next() {
 		var c Category
-		if err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.ParentID, &c.IsActive, &c.CreatedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.ParentID, &c.IsActive, &c.DefaultWarrantyMonths, &c.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan category: %w", err)
 		}
 		categories = append(categories, &c)
@@ -55,3 +108,219 @@ func (r *MySQLRepository) List(ctx context.Context) ([]*Category, error) {
 
 	return categories, nil
 }
+
+func (r *MySQLRepository) getOne(ctx context.Context, query string, arg any) (*Category, error) {
+	var c Category
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(&c.ID, &c.Name, &c.Slug, &c.ParentID, &c.IsActive, &c.DefaultWarrantyMonths, &c.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+	return &c, nil
+}
+
+const attributeColumns = `id, category_id, attribute_key, label, type, required, options, created_at`
+
+func (r *MySQLRepository) CreateAttribute(ctx context.Context, attribute *Attribute) error {
+	options, err := marshalOptions(attribute.Options)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO keepsy_category_attributes (category_id, attribute_key, label, type, required, options, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	attribute.CreatedAt = time.Now()
+
+	result, err := tx.ExecContext(ctx, query,
+		attribute.CategoryID, attribute.Key, attribute.Label, attribute.Type, attribute.Required, options, attribute.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicate(err) {
+			return ErrAttributeKeyTaken
+		}
+		return fmt.Errorf("failed to create attribute: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	attribute.ID = int(id)
+
+	// Existing products have no value for it yet, so it can only start out
+	// required in an empty category
+	conflicts, err := attributeConflicts(ctx, tx, attribute)
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		attribute.ID = 0
+		return ErrAttributeConflict
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) GetAttribute(ctx context.Context, id int) (*Attribute, error) {
+	query := `SELECT ` + attributeColumns + ` FROM keepsy_category_attributes WHERE id = ?`
+	attribute, err := scanAttribute(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttributeNotFound
+		}
+		return nil, fmt.Errorf("failed to get attribute: %w", err)
+	}
+	return attribute, nil
+}
+
+func (r *MySQLRepository) ListAttributes(ctx context.Context, categoryID int) ([]*Attribute, error) {
+	query := `SELECT ` + attributeColumns + ` FROM keepsy_category_attributes WHERE category_id = ? ORDER BY id ASC`
+	rows, err := r.db.QueryContext(ctx, query, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attributes: %w", err)
+	}
+	defer rows.Close()
+
+	var attributes []*Attribute
+	for rows.Next() {
+		attribute, err := scanAttribute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attribute: %w", err)
+		}
+		attributes = append(attributes, attribute)
+	}
+	return attributes, rows.Err()
+}
+
+func (r *MySQLRepository) UpdateAttribute(ctx context.Context, attribute *Attribute) error {
+	options, err := marshalOptions(attribute.Options)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the attribute, then make sure every product of the category
+	// already satisfies it
+	var id int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM keepsy_category_attributes WHERE id = ? FOR UPDATE`, attribute.ID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrAttributeNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock attribute: %w", err)
+	}
+	conflicts, err := attributeConflicts(ctx, tx, attribute)
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return ErrAttributeConflict
+	}
+
+	query := `UPDATE keepsy_category_attributes SET label = ?, required = ?, options = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, attribute.Label, attribute.Required, options, attribute.ID); err != nil {
+		return fmt.Errorf("failed to update attribute: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// attributeConflicts counts the products of the attribute's category that
+// lack a value for it although it is required, or have a value that isn't
+// one of its options.
+func attributeConflicts(ctx context.Context, tx *sql.Tx, attribute *Attribute) (int, error) {
+	var missing, invalid int
+	if attribute.Required {
+		query := `
+			SELECT COUNT(*) FROM keepsy_products p
+			WHERE p.category_id = ? AND NOT EXISTS (
+				SELECT 1 FROM keepsy_product_attributes pa WHERE pa.product_id = p.id AND pa.attribute_id = ?)`
+		if err := tx.QueryRowContext(ctx, query, attribute.CategoryID, attribute.ID).Scan(&missing); err != nil {
+			return 0, fmt.Errorf("failed to count products without a value: %w", err)
+		}
+	}
+	if attribute.Type == AttributeEnum {
+		// Compared as bytes, since options are matched exactly
+		in := "(?" + strings.Repeat(", ?", len(attribute.Options)-1) + ")"
+		query := `SELECT COUNT(*) FROM keepsy_product_attributes WHERE attribute_id = ? AND CAST(value AS BINARY) NOT IN ` + in
+		args := []any{attribute.ID}
+		for _, option := range attribute.Options {
+			args = append(args, option)
+		}
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&invalid); err != nil {
+			return 0, fmt.Errorf("failed to count values outside the options: %w", err)
+		}
+	}
+	return missing + invalid, nil
+}
+
+func (r *MySQLRepository) DeleteAttribute(ctx context.Context, id int) error {
+	// Product values go with it (ON DELETE CASCADE)
+	result, err := r.db.ExecContext(ctx, `DELETE FROM keepsy_category_attributes WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attribute: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAttributeNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttribute(row scanner) (*Attribute, error) {
+	var a Attribute
+	var options []byte
+	if err := row.Scan(&a.ID, &a.CategoryID, &a.Key, &a.Label, &a.Type, &a.Required, &options, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	if options != nil {
+		if err := json.Unmarshal(options, &a.Options); err != nil {
+			return nil, fmt.Errorf("failed to decode attribute options: %w", err)
+		}
+	}
+	return &a, nil
+}
+
+// marshalOptions encodes enum options for the JSON column, NULL when there
+// are none.
+func marshalOptions(options []string) (any, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode attribute options: %w", err)
+	}
+	return string(data), nil
+}
+
+// isDuplicate reports a unique key violation: a category's slug or an
+// attribute's key.
+func isDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
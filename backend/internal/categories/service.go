@@ -2,10 +2,57 @@ package categories
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrNameRequired   = errors.New("name is required")
+	ErrInvalidSlug    = errors.New("slug may only contain lowercase letters, digits and single dashes")
+	ErrSlugTaken      = errors.New("slug is already in use")
+	ErrParentNotFound = errors.New("parent category not found or inactive")
+	ErrCategoryCycle  = errors.New("a category can't be moved below itself")
+	ErrInvalidPeriod  = errors.New("default warranty period must be between 1 and 120 months")
+
+	ErrInvalidAttributeKey  = errors.New("attribute key must be up to 50 lowercase letters, digits and underscores, starting with a letter")
+	ErrAttributeKeyTaken    = errors.New("attribute key is already in use in this category")
+	ErrLabelRequired        = errors.New("label is required")
+	ErrInvalidAttributeType = errors.New("attribute type must be string, number, date or enum")
+	ErrInvalidOptions       = errors.New("enum attributes need distinct, non-empty options; other types take none")
+	ErrAttributeConflict    = errors.New("products of this category are missing the attribute or have a value outside its options; update them first")
+)
+
+const (
+	maxWarrantyMonths = 120
+
+	maxAttributeKey = 50
+	maxOptions      = 100
+	maxOptionLength = 255 // Values are stored in a VARCHAR(255)
+)
+
+var (
+	slugPattern         = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	nonSlugChars        = regexp.MustCompile(`[^a-z0-9]+`)
+	attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
 )
 
 type Service interface {
 	ListCategories(ctx context.Context) ([]*Category, error)
+
+	// Admin only, enforced by the routes
+	ListAllCategories(ctx context.Context) ([]*Category, error)
+	CreateCategory(ctx context.Context, req CreateCategoryRequest) (*Category, error)
+	UpdateCategory(ctx context.Context, id int, req UpdateCategoryRequest) (*Category, error)
+	ReparentCategory(ctx context.Context, id int, req ReparentCategoryRequest) (*Category, error)
+
+	// ListAttributes returns the attribute schema of the category's products.
+	ListAttributes(ctx context.Context, categoryID int) ([]*Attribute, error)
+
+	// Admin only, enforced by the routes
+	CreateAttribute(ctx context.Context, categoryID int, req CreateAttributeRequest) (*Attribute, error)
+	UpdateAttribute(ctx context.Context, categoryID, id int, req UpdateAttributeRequest) (*Attribute, error)
+	DeleteAttribute(ctx context.Context, categoryID, id int) error
 }
 
 type service struct {
@@ -19,3 +66,289 @@ func NewService(repo Repository) Service {
 func (s *service) ListCategories(ctx context.Context) ([]*Category, error) {
 	return s.repo.List(ctx)
 }
+
+func (s *service) ListAllCategories(ctx context.Context) ([]*Category, error) {
+	return s.repo.ListAll(ctx)
+}
+
+func (s *service) CreateCategory(ctx context.Context, req CreateCategoryRequest) (*Category, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrNameRequired
+	}
+
+	// Without a slug, derive one from the name
+	slug := strings.TrimSpace(req.Slug)
+	if slug == "" {
+		slug = slugify(name)
+	}
+	if err := s.checkSlug(ctx, slug, 0); err != nil {
+		return nil, err
+	}
+
+	if req.ParentID != nil {
+		if err := s.checkParent(ctx, *req.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.DefaultWarrantyMonths != nil && (*req.DefaultWarrantyMonths < 1 || *req.DefaultWarrantyMonths > maxWarrantyMonths) {
+		return nil, ErrInvalidPeriod
+	}
+
+	category := &Category{Name: name, Slug: slug, ParentID: req.ParentID, DefaultWarrantyMonths: req.DefaultWarrantyMonths}
+	if err := s.repo.Create(ctx, category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *service) UpdateCategory(ctx context.Context, id int, req UpdateCategoryRequest) (*Category, error) {
+	category, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, ErrNameRequired
+		}
+		category.Name = name
+	}
+	if req.Slug != nil {
+		slug := strings.TrimSpace(*req.Slug)
+		if slug != category.Slug {
+			if err := s.checkSlug(ctx, slug, id); err != nil {
+				return nil, err
+			}
+			category.Slug = slug
+		}
+	}
+	if req.IsActive != nil {
+		category.IsActive = *req.IsActive
+	}
+	if months := req.DefaultWarrantyMonths; months != nil {
+		switch {
+		case *months == 0:
+			category.DefaultWarrantyMonths = nil
+		case *months < 0 || *months > maxWarrantyMonths:
+			return nil, ErrInvalidPeriod
+		default:
+			category.DefaultWarrantyMonths = months
+		}
+	}
+
+	if err := s.repo.Update(ctx, category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *service) ReparentCategory(ctx context.Context, id int, req ReparentCategoryRequest) (*Category, error) {
+	category, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ParentID != nil {
+		if err := s.checkParent(ctx, *req.ParentID); err != nil {
+			return nil, err
+		}
+		if err := s.checkCycle(ctx, id, *req.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	category.ParentID = req.ParentID
+	if err := s.repo.Update(ctx, category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *service) ListAttributes(ctx context.Context, categoryID int) ([]*Attribute, error) {
+	if _, err := s.repo.GetByID(ctx, categoryID); err != nil {
+		return nil, err
+	}
+	attributes, err := s.repo.ListAttributes(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if attributes == nil {
+		attributes = []*Attribute{}
+	}
+	return attributes, nil
+}
+
+func (s *service) CreateAttribute(ctx context.Context, categoryID int, req CreateAttributeRequest) (*Attribute, error) {
+	if _, err := s.repo.GetByID(ctx, categoryID); err != nil {
+		return nil, err
+	}
+
+	key := strings.TrimSpace(req.Key)
+	if len(key) > maxAttributeKey || !attributeKeyPattern.MatchString(key) {
+		return nil, ErrInvalidAttributeKey
+	}
+	label := strings.TrimSpace(req.Label)
+	if label == "" {
+		return nil, ErrLabelRequired
+	}
+	switch req.Type {
+	case AttributeString, AttributeNumber, AttributeDate, AttributeEnum:
+	default:
+		return nil, ErrInvalidAttributeType
+	}
+	options, err := checkOptions(req.Type, req.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	attribute := &Attribute{
+		CategoryID: categoryID,
+		Key:        key,
+		Label:      label,
+		Type:       req.Type,
+		Required:   req.Required,
+		Options:    options,
+	}
+	if err := s.repo.CreateAttribute(ctx, attribute); err != nil {
+		return nil, err
+	}
+	return attribute, nil
+}
+
+func (s *service) UpdateAttribute(ctx context.Context, categoryID, id int, req UpdateAttributeRequest) (*Attribute, error) {
+	attribute, err := s.categoryAttribute(ctx, categoryID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Label != nil {
+		label := strings.TrimSpace(*req.Label)
+		if label == "" {
+			return nil, ErrLabelRequired
+		}
+		attribute.Label = label
+	}
+	if req.Required != nil {
+		attribute.Required = *req.Required
+	}
+	if req.Options != nil {
+		options, err := checkOptions(attribute.Type, *req.Options)
+		if err != nil {
+			return nil, err
+		}
+		attribute.Options = options
+	}
+
+	if err := s.repo.UpdateAttribute(ctx, attribute); err != nil {
+		return nil, err
+	}
+	return attribute, nil
+}
+
+func (s *service) DeleteAttribute(ctx context.Context, categoryID, id int) error {
+	if _, err := s.categoryAttribute(ctx, categoryID, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteAttribute(ctx, id)
+}
+
+// categoryAttribute loads an attribute of the category; one of another
+// category is reported as missing.
+func (s *service) categoryAttribute(ctx context.Context, categoryID, id int) (*Attribute, error) {
+	attribute, err := s.repo.GetAttribute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if attribute.CategoryID != categoryID {
+		return nil, ErrAttributeNotFound
+	}
+	return attribute, nil
+}
+
+// checkOptions validates the options of an attribute of type typ and
+// returns them trimmed. Only enums have options.
+func checkOptions(typ string, options []string) ([]string, error) {
+	if typ != AttributeEnum {
+		if len(options) > 0 {
+			return nil, ErrInvalidOptions
+		}
+		return nil, nil
+	}
+	if len(options) == 0 || len(options) > maxOptions {
+		return nil, ErrInvalidOptions
+	}
+
+	seen := make(map[string]bool, len(options))
+	trimmed := make([]string, len(options))
+	for i, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > maxOptionLength || seen[option] {
+			return nil, ErrInvalidOptions
+		}
+		seen[option] = true
+		trimmed[i] = option
+	}
+	return trimmed, nil
+}
+
+// checkSlug validates the slug's format and that no other category uses it.
+func (s *service) checkSlug(ctx context.Context, slug string, id int) error {
+	if !slugPattern.MatchString(slug) {
+		return ErrInvalidSlug
+	}
+
+	other, err := s.repo.GetBySlug(ctx, slug)
+	switch {
+	case err == ErrCategoryNotFound:
+		return nil
+	case err != nil:
+		return err
+	case other.ID != id:
+		return ErrSlugTaken
+	}
+	return nil
+}
+
+func (s *service) checkParent(ctx context.Context, parentID int) error {
+	parent, err := s.repo.GetByID(ctx, parentID)
+	if err != nil {
+		if err == ErrCategoryNotFound {
+			return ErrParentNotFound
+		}
+		return err
+	}
+	if !parent.IsActive {
+		return ErrParentNotFound
+	}
+	return nil
+}
+
+// checkCycle walks up from the new parent and fails if it reaches the
+// category itself, i.e. the parent is the category or one of its descendants.
+func (s *service) checkCycle(ctx context.Context, id, parentID int) error {
+	all, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	parents := make(map[int]*int, len(all))
+	for _, c := range all {
+		parents[c.ID] = c.ParentID
+	}
+
+	seen := make(map[int]bool)
+	for current := &parentID; current != nil; current = parents[*current] {
+		if *current == id || seen[*current] {
+			return ErrCategoryCycle
+		}
+		seen[*current] = true
+	}
+	return nil
+}
+
+// slugify turns a name like "Home & Kitchen" into "home-kitchen".
+func slugify(name string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
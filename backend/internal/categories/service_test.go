@@ -25,6 +25,71 @@ func (m *MockRepo) List(ctx context.Context) ([]*Category, error) {
 	return args.Get(0).([]*Category), args.Error(1)
 }
 
+func (m *MockRepo) ListAll(ctx context.Context) ([]*Category, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Category), args.Error(1)
+}
+
+func (m *MockRepo) GetByID(ctx context.Context, id int) (*Category, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	// Copy, the service modifies the category it gets
+	c := *args.Get(0).(*Category)
+	return &c, args.Error(1)
+}
+
+func (m *MockRepo) GetBySlug(ctx context.Context, slug string) (*Category, error) {
+	args := m.Called(ctx, slug)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Category), args.Error(1)
+}
+
+func (m *MockRepo) Update(ctx context.Context, category *Category) error {
+	args := m.Called(ctx, category)
+	return args.Error(0)
+}
+
+func (m *MockRepo) CreateAttribute(ctx context.Context, attribute *Attribute) error {
+	args := m.Called(ctx, attribute)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetAttribute(ctx context.Context, id int) (*Attribute, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	a := *args.Get(0).(*Attribute)
+	return &a, args.Error(1)
+}
+
+func (m *MockRepo) ListAttributes(ctx context.Context, categoryID int) ([]*Attribute, error) {
+	args := m.Called(ctx, categoryID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Attribute), args.Error(1)
+}
+
+func (m *MockRepo) UpdateAttribute(ctx context.Context, attribute *Attribute) error {
+	args := m.Called(ctx, attribute)
+	return args.Error(0)
+}
+
+func (m *MockRepo) DeleteAttribute(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func intPtr(i int) *int { return &i }
+
 func TestListCategories(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		mockRepo := new(MockRepo)
@@ -48,3 +113,320 @@ func TestListCategories(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestCreateCategory(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Slug From Name", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetBySlug", ctx, "home-kitchen").Return(nil, ErrCategoryNotFound)
+		mockRepo.On("GetByID", ctx, 1).Return(&Category{ID: 1, IsActive: true}, nil)
+		mockRepo.On("Create", ctx, mock.MatchedBy(func(c *Category) bool {
+			return c.Name == "Home & Kitchen" && c.Slug == "home-kitchen" && *c.ParentID == 1
+		})).Return(nil)
+
+		category, err := service.CreateCategory(ctx, CreateCategoryRequest{Name: " Home & Kitchen ", ParentID: intPtr(1)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "home-kitchen", category.Slug)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Slug Taken", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetBySlug", ctx, "phones").Return(&Category{ID: 4, Slug: "phones"}, nil)
+
+		_, err := service.CreateCategory(ctx, CreateCategoryRequest{Name: "Phones"})
+
+		assert.Equal(t, ErrSlugTaken, err)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid Slug", func(t *testing.T) {
+		service := NewService(new(MockRepo))
+
+		_, err := service.CreateCategory(ctx, CreateCategoryRequest{Name: "Phones", Slug: "Phones & Tablets"})
+
+		assert.Equal(t, ErrInvalidSlug, err)
+	})
+
+	t.Run("Inactive Parent", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetBySlug", ctx, "phones").Return(nil, ErrCategoryNotFound)
+		mockRepo.On("GetByID", ctx, 2).Return(&Category{ID: 2, IsActive: false}, nil)
+
+		_, err := service.CreateCategory(ctx, CreateCategoryRequest{Name: "Phones", ParentID: intPtr(2)})
+
+		assert.Equal(t, ErrParentNotFound, err)
+	})
+}
+
+func TestUpdateCategory(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deactivate", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetByID", ctx, 3).Return(&Category{ID: 3, Name: "TVs", Slug: "tvs", IsActive: true}, nil)
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(c *Category) bool {
+			return c.ID == 3 && !c.IsActive && c.Slug == "tvs"
+		})).Return(nil)
+
+		inactive := false
+		category, err := service.UpdateCategory(ctx, 3, UpdateCategoryRequest{IsActive: &inactive})
+
+		assert.NoError(t, err)
+		assert.False(t, category.IsActive)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Slug Of Another Category", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetByID", ctx, 3).Return(&Category{ID: 3, Slug: "tvs"}, nil)
+		mockRepo.On("GetBySlug", ctx, "phones").Return(&Category{ID: 4, Slug: "phones"}, nil)
+
+		slug := "phones"
+		_, err := service.UpdateCategory(ctx, 3, UpdateCategoryRequest{Slug: &slug})
+
+		assert.Equal(t, ErrSlugTaken, err)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetByID", ctx, 9).Return(nil, ErrCategoryNotFound)
+
+		_, err := service.UpdateCategory(ctx, 9, UpdateCategoryRequest{})
+
+		assert.Equal(t, ErrCategoryNotFound, err)
+	})
+}
+
+func TestReparentCategory(t *testing.T) {
+	ctx := context.Background()
+
+	// electronics(1) > phones(2) > cases(3); kitchen(4)
+	tree := []*Category{
+		{ID: 1, Slug: "electronics", IsActive: true},
+		{ID: 2, Slug: "phones", ParentID: intPtr(1), IsActive: true},
+		{ID: 3, Slug: "cases", ParentID: intPtr(2), IsActive: true},
+		{ID: 4, Slug: "kitchen", IsActive: true},
+	}
+	setup := func() (*MockRepo, Service) {
+		mockRepo := new(MockRepo)
+		for _, c := range tree {
+			mockRepo.On("GetByID", ctx, c.ID).Return(c, nil)
+		}
+		mockRepo.On("ListAll", ctx).Return(tree, nil)
+		return mockRepo, NewService(mockRepo)
+	}
+
+	t.Run("Move Under Sibling Tree", func(t *testing.T) {
+		mockRepo, service := setup()
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(c *Category) bool {
+			return c.ID == 2 && *c.ParentID == 4
+		})).Return(nil)
+
+		category, err := service.ReparentCategory(ctx, 2, ReparentCategoryRequest{ParentID: intPtr(4)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, *category.ParentID)
+	})
+
+	t.Run("Move To Top Level", func(t *testing.T) {
+		mockRepo, service := setup()
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(c *Category) bool {
+			return c.ID == 3 && c.ParentID == nil
+		})).Return(nil)
+
+		_, err := service.ReparentCategory(ctx, 3, ReparentCategoryRequest{})
+
+		assert.NoError(t, err)
+	})
+
+	for name, parentID := range map[string]int{"Itself": 1, "Child": 2, "Grandchild": 3} {
+		t.Run("Cycle Via "+name, func(t *testing.T) {
+			mockRepo, service := setup()
+
+			_, err := service.ReparentCategory(ctx, 1, ReparentCategoryRequest{ParentID: intPtr(parentID)})
+
+			assert.Equal(t, ErrCategoryCycle, err)
+			mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestDefaultWarrantyPeriod(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Create With Period", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetBySlug", ctx, "laptops").Return(nil, ErrCategoryNotFound)
+		mockRepo.On("Create", ctx, mock.MatchedBy(func(c *Category) bool {
+			return *c.DefaultWarrantyMonths == 12
+		})).Return(nil)
+
+		_, err := service.CreateCategory(ctx, CreateCategoryRequest{Name: "Laptops", DefaultWarrantyMonths: intPtr(12)})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Create With Invalid Period", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetBySlug", ctx, "laptops").Return(nil, ErrCategoryNotFound)
+
+		_, err := service.CreateCategory(ctx, CreateCategoryRequest{Name: "Laptops", DefaultWarrantyMonths: intPtr(0)})
+
+		assert.Equal(t, ErrInvalidPeriod, err)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Update Clears Period", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetByID", ctx, 3).Return(&Category{ID: 3, Slug: "laptops", DefaultWarrantyMonths: intPtr(12)}, nil)
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(c *Category) bool {
+			return c.DefaultWarrantyMonths == nil
+		})).Return(nil)
+
+		_, err := service.UpdateCategory(ctx, 3, UpdateCategoryRequest{DefaultWarrantyMonths: intPtr(0)})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Update With Invalid Period", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+
+		mockRepo.On("GetByID", ctx, 3).Return(&Category{ID: 3, Slug: "laptops"}, nil)
+
+		_, err := service.UpdateCategory(ctx, 3, UpdateCategoryRequest{DefaultWarrantyMonths: intPtr(121)})
+
+		assert.Equal(t, ErrInvalidPeriod, err)
+	})
+}
+
+func TestCreateAttribute(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Enum", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+		mockRepo.On("GetByID", ctx, 1).Return(&Category{ID: 1, Name: "Phones"}, nil)
+		mockRepo.On("CreateAttribute", ctx, mock.MatchedBy(func(a *Attribute) bool {
+			return a.CategoryID == 1 && a.Key == "storage" && a.Label == "Storage" &&
+				assert.ObjectsAreEqual([]string{"128 GB", "256 GB"}, a.Options)
+		})).Return(nil)
+
+		attribute, err := service.CreateAttribute(ctx, 1, CreateAttributeRequest{
+			Key: " storage ", Label: "Storage", Type: AttributeEnum, Options: []string{"128 GB", " 256 GB"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, AttributeEnum, attribute.Type)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+		mockRepo.On("GetByID", ctx, 1).Return(&Category{ID: 1, Name: "Phones"}, nil)
+
+		tests := []struct {
+			req  CreateAttributeRequest
+			want error
+		}{
+			{CreateAttributeRequest{Key: "IMEI", Label: "IMEI", Type: AttributeString}, ErrInvalidAttributeKey},
+			{CreateAttributeRequest{Key: "2nd_sim", Label: "Second SIM", Type: AttributeString}, ErrInvalidAttributeKey},
+			{CreateAttributeRequest{Key: "imei", Label: " ", Type: AttributeString}, ErrLabelRequired},
+			{CreateAttributeRequest{Key: "imei", Label: "IMEI", Type: "text"}, ErrInvalidAttributeType},
+			{CreateAttributeRequest{Key: "color", Label: "Color", Type: AttributeEnum}, ErrInvalidOptions},
+			{CreateAttributeRequest{Key: "color", Label: "Color", Type: AttributeEnum, Options: []string{"Red", "Red "}}, ErrInvalidOptions},
+			{CreateAttributeRequest{Key: "ram_gb", Label: "RAM", Type: AttributeNumber, Options: []string{"8"}}, ErrInvalidOptions},
+		}
+		for _, tt := range tests {
+			_, err := service.CreateAttribute(ctx, 1, tt.req)
+			assert.Equal(t, tt.want, err, tt.req.Key)
+		}
+		mockRepo.AssertNotCalled(t, "CreateAttribute", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown Category", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+		mockRepo.On("GetByID", ctx, 9).Return(nil, ErrCategoryNotFound)
+
+		_, err := service.CreateAttribute(ctx, 9, CreateAttributeRequest{Key: "vin", Label: "VIN", Type: AttributeString})
+
+		assert.Equal(t, ErrCategoryNotFound, err)
+	})
+
+	t.Run("Required With Existing Products", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+		mockRepo.On("GetByID", ctx, 1).Return(&Category{ID: 1, Name: "Phones"}, nil)
+		mockRepo.On("CreateAttribute", ctx, mock.MatchedBy(func(a *Attribute) bool { return a.Required })).Return(ErrAttributeConflict)
+
+		_, err := service.CreateAttribute(ctx, 1, CreateAttributeRequest{Key: "imei", Label: "IMEI", Type: AttributeString, Required: true})
+
+		assert.Equal(t, ErrAttributeConflict, err)
+	})
+}
+
+func TestUpdateAttribute(t *testing.T) {
+	ctx := context.Background()
+	color := &Attribute{ID: 5, CategoryID: 1, Key: "color", Label: "Color", Type: AttributeEnum, Options: []string{"Red"}}
+
+	t.Run("Options", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+		mockRepo.On("GetAttribute", ctx, 5).Return(color, nil)
+		mockRepo.On("UpdateAttribute", ctx, mock.Anything).Return(nil)
+
+		options := []string{"Red", "Blue"}
+		attribute, err := service.UpdateAttribute(ctx, 1, 5, UpdateAttributeRequest{Options: &options})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Red", "Blue"}, attribute.Options)
+	})
+
+	t.Run("Conflicting Products", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+		mockRepo.On("GetAttribute", ctx, 6).Return(&Attribute{ID: 6, CategoryID: 1, Key: "imei", Label: "IMEI", Type: AttributeString}, nil)
+		mockRepo.On("UpdateAttribute", ctx, mock.MatchedBy(func(a *Attribute) bool { return a.Required })).Return(ErrAttributeConflict)
+
+		required := true
+		_, err := service.UpdateAttribute(ctx, 1, 6, UpdateAttributeRequest{Required: &required})
+
+		assert.Equal(t, ErrAttributeConflict, err)
+	})
+
+	t.Run("Other Category", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo)
+		mockRepo.On("GetAttribute", ctx, 5).Return(color, nil)
+
+		err := service.DeleteAttribute(ctx, 2, 5)
+
+		assert.Equal(t, ErrAttributeNotFound, err)
+		mockRepo.AssertNotCalled(t, "DeleteAttribute", mock.Anything, mock.Anything)
+	})
+}
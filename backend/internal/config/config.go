@@ -1,14 +1,85 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// minAuthSecretLength is the shortest AUTH_SECRET accepted: 256 bits, the
+// size of an HS256 key.
+const minAuthSecretLength = 32
+
 type Config struct {
 	Port        string
 	DatabaseURL string
+
+	// Proxies whose X-Forwarded-For is believed, like the load balancer
+	TrustedProxies []netip.Prefix
+
+	// Auth
+	AuthSecret      string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Passwords
+	BcryptCost           int
+	PasswordMinLength    int
+	PasswordDenylistFile string
+
+	// Password reset
+	PasswordResetURL string
+	PasswordResetTTL time.Duration
+
+	// One-time codes (email/phone verification)
+	OTPTTL         time.Duration
+	OTPMaxAttempts int
+
+	// Passwordless phone login
+	LoginCodeLimit  int
+	LoginCodeWindow time.Duration
+
+	// Email and phone verification code resends
+	VerifyCodeLimit  int
+	VerifyCodeWindow time.Duration
+
+	// Brute-force protection for password logins
+	LoginMaxFailures      int
+	LoginMaxFailuresPerIP int
+	LoginBackoffBase      time.Duration
+	LoginLockout          time.Duration
+
+	// Two-factor authentication
+	TOTPIssuer      string
+	SecondFactorTTL time.Duration
+
+	// OpenID Connect sign-in providers
+	OIDCProviders []OIDCProvider
+
+	// Mail
+	MailDriver   string // "smtp" or "file"
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// OIDCProvider is read from OIDC_<NAME>_* variables for every name in OIDC_PROVIDERS.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 func Load() (*Config, error) {
@@ -26,8 +97,218 @@ func Load() (*Config, error) {
 		dbURL = "root:root@tcp(localhost:3306)/keepsy?parseTime=true"
 	}
 
+	// Anyone who knows the secret can sign tokens for any user, so there is
+	// no default
+	authSecret := os.Getenv("AUTH_SECRET")
+	if len(authSecret) < minAuthSecretLength {
+		return nil, fmt.Errorf("AUTH_SECRET must be set to at least %d bytes (e.g. openssl rand -hex 32)", minAuthSecretLength)
+	}
+
+	trustedProxies, err := prefixesEnv("TRUSTED_PROXIES")
+	if err != nil {
+		return nil, err
+	}
+
+	accessTTL, err := durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTTL, err := durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	resetTTL, err := durationEnv("PASSWORD_RESET_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	otpTTL, err := durationEnv("OTP_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	otpMaxAttempts, err := intEnv("OTP_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	loginCodeLimit, err := intEnv("LOGIN_CODE_LIMIT", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	loginCodeWindow, err := durationEnv("LOGIN_CODE_WINDOW", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyCodeLimit, err := intEnv("VERIFY_CODE_LIMIT", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyCodeWindow, err := durationEnv("VERIFY_CODE_WINDOW", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	loginMaxFailures, err := intEnv("LOGIN_MAX_FAILURES", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	loginMaxFailuresPerIP, err := intEnv("LOGIN_MAX_FAILURES_PER_IP", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	loginBackoffBase, err := durationEnv("LOGIN_BACKOFF_BASE", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	loginLockout, err := durationEnv("LOGIN_LOCKOUT", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	bcryptCost, err := intEnv("BCRYPT_COST", bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordMinLength, err := intEnv("PASSWORD_MIN_LENGTH", 8)
+	if err != nil {
+		return nil, err
+	}
+
+	secondFactorTTL, err := durationEnv("SECOND_FACTOR_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProviders, err := oidcProvidersEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		Port:        port,
-		DatabaseURL: dbURL,
+		Port:                  port,
+		DatabaseURL:           dbURL,
+		TrustedProxies:        trustedProxies,
+		AuthSecret:            authSecret,
+		AccessTokenTTL:        accessTTL,
+		RefreshTokenTTL:       refreshTTL,
+		BcryptCost:            bcryptCost,
+		PasswordMinLength:     passwordMinLength,
+		PasswordDenylistFile:  stringEnv("PASSWORD_DENYLIST_FILE", "config/common-passwords.txt"),
+		PasswordResetURL:      stringEnv("PASSWORD_RESET_URL", "keepsy://reset-password"),
+		PasswordResetTTL:      resetTTL,
+		OTPTTL:                otpTTL,
+		OTPMaxAttempts:        otpMaxAttempts,
+		LoginCodeLimit:        loginCodeLimit,
+		LoginCodeWindow:       loginCodeWindow,
+		VerifyCodeLimit:       verifyCodeLimit,
+		VerifyCodeWindow:      verifyCodeWindow,
+		LoginMaxFailures:      loginMaxFailures,
+		LoginMaxFailuresPerIP: loginMaxFailuresPerIP,
+		LoginBackoffBase:      loginBackoffBase,
+		LoginLockout:          loginLockout,
+		TOTPIssuer:            stringEnv("TOTP_ISSUER", "Keepsy"),
+		SecondFactorTTL:       secondFactorTTL,
+		OIDCProviders:         oidcProviders,
+		MailDriver:            stringEnv("MAIL_DRIVER", "file"),
+		MailFrom:              stringEnv("MAIL_FROM", "Keepsy <no-reply@keepsy.local>"),
+		MailDir:               stringEnv("MAIL_DIR", "./mail"),
+		SMTPHost:              os.Getenv("SMTP_HOST"),
+		SMTPPort:              stringEnv("SMTP_PORT", "587"),
+		SMTPUsername:          os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:          os.Getenv("SMTP_PASSWORD"),
 	}, nil
 }
+
+// oidcProvidersEnv reads the providers listed in OIDC_PROVIDERS (e.g. "google,apple").
+func oidcProvidersEnv() ([]OIDCProvider, error) {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		p := OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			return nil, fmt.Errorf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// prefixesEnv reads a comma-separated list of IP ranges like
+// "10.0.0.0/8,192.168.1.4" from the environment; a single address is a range
+// of one.
+func prefixesEnv(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// intEnv reads an integer from the environment, falling back to def when not set.
+func intEnv(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// stringEnv reads a string from the environment, falling back to def when not set.
+func stringEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// durationEnv reads a duration (e.g. "15m", "720h") from the environment,
+// falling back to def when the variable is not set.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
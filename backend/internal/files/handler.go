@@ -0,0 +1,52 @@
+package files
+
+import (
+	"errors"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/authz"
+	"mime"
+	"net/http"
+	"time"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ServeFile handles GET /uploads/{key...}, streaming a stored file to the
+// user it belongs to. There is no directory listing.
+func (h *Handler) ServeFile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	file, err := h.service.Open(r.Context(), userID, r.PathValue("key"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrFileNotFound):
+			http.Error(w, "File not found", http.StatusNotFound)
+		case errors.Is(err, authz.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to open file", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Cache-Control", "private")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if file.ContentType != "" {
+		w.Header().Set("Content-Type", file.ContentType)
+	}
+	if file.Attachment {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	}
+	http.ServeContent(w, r, file.Name, time.Time{}, file)
+}
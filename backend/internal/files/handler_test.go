@@ -0,0 +1,59 @@
+package files
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"keepsy-backend/internal/services/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeFile(t *testing.T) {
+	f := newFixture(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /uploads/{key...}", NewHandler(f.service).ServeFile)
+
+	get := func(url string, userID int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: userID}))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Bill Is Downloaded", func(t *testing.T) {
+		rec := get(f.billURL, 1)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<script>alert(1)</script>", rec.Body.String())
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	})
+
+	t.Run("Photo", func(t *testing.T) {
+		rec := get(f.photoURL, 1)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+		assert.Empty(t, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("Other User", func(t *testing.T) {
+		for _, url := range []string{f.billURL, f.photoURL, f.avatarURL} {
+			rec := get(url, 2)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code, url)
+			assert.NotContains(t, rec.Body.String(), "alert")
+		}
+	})
+
+	t.Run("No Directory Listing", func(t *testing.T) {
+		for _, url := range []string{"/uploads/", "/uploads/uuid-1/", "/uploads/uuid-1/bills/"} {
+			rec := get(url, 1)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, url)
+		}
+	})
+}
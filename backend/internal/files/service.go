@@ -0,0 +1,129 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io"
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/photos"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/users"
+	"path"
+	"strings"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// File is an open stored file with what's needed to serve it.
+type File struct {
+	io.ReadSeekCloser
+	Name        string
+	ContentType string // Empty to go by the name's extension
+	Attachment  bool   // Downloaded rather than shown inline
+}
+
+type Service interface {
+	// Open returns the file stored at key (its URL below the uploads URL)
+	// if it belongs to a bill, photo or avatar of the user. Files nothing
+	// references are never served.
+	Open(ctx context.Context, userID int, key string) (*File, error)
+}
+
+type service struct {
+	billRepo    bills.Repository
+	photoRepo   photos.Repository
+	productRepo products.Repository
+	userRepo    users.Repository
+	storage     storage.Service
+	baseURL     string
+}
+
+// NewService serves the files of storage, whose URLs start with baseURL
+// (e.g. "http://localhost:8080/uploads").
+func NewService(billRepo bills.Repository, photoRepo photos.Repository, productRepo products.Repository, userRepo users.Repository, storage storage.Service, baseURL string) Service {
+	return &service{
+		billRepo:    billRepo,
+		photoRepo:   photoRepo,
+		productRepo: productRepo,
+		userRepo:    userRepo,
+		storage:     storage,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (s *service) Open(ctx context.Context, userID int, key string) (*File, error) {
+	url := s.baseURL + "/" + key
+
+	file, err := s.authorize(ctx, userID, key, url)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.storage.Open(ctx, url)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidPath) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+	file.ReadSeekCloser = f
+	return file, nil
+}
+
+// authorize loads the row referencing url and checks that it's the user's.
+func (s *service) authorize(ctx context.Context, userID int, key, url string) (*File, error) {
+	name := path.Base(key)
+
+	// Avatars live under their owner's UUID
+	if userUUID, _, ok := strings.Cut(key, "/avatar/"); ok && !strings.Contains(userUUID, "/") {
+		user, err := s.userRepo.GetByUUID(ctx, userUUID)
+		if err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				return nil, ErrFileNotFound
+			}
+			return nil, err
+		}
+		if user.AvatarURL != url {
+			return nil, ErrFileNotFound
+		}
+		if err := authz.Authorize(userID, authz.OwnedBy(user.ID)); err != nil {
+			return nil, err
+		}
+		return &File{Name: name}, nil
+	}
+
+	bill, err := s.billRepo.GetByFileURL(ctx, url)
+	switch {
+	case err == nil:
+		if err := authz.Authorize(userID, bill); err != nil {
+			return nil, err
+		}
+		// The type and name come from the uploader, so never render it inline
+		return &File{Name: name, Attachment: true}, nil
+	case !errors.Is(err, bills.ErrBillNotFound):
+		return nil, err
+	}
+
+	photo, err := s.photoRepo.GetByURL(ctx, url)
+	if err != nil {
+		if errors.Is(err, photos.ErrPhotoNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+	product, err := s.productRepo.GetByID(ctx, photo.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(userID, product); err != nil {
+		return nil, err
+	}
+	// The original has the type it was decoded as, thumbnails are JPEGs
+	contentType := "image/jpeg"
+	if url == photo.URL {
+		contentType = photo.ContentType
+	}
+	return &File{Name: name, ContentType: contentType}, nil
+}
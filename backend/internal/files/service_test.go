@@ -0,0 +1,158 @@
+package files
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/photos"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/users"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const baseURL = "http://localhost:8080/uploads"
+
+// Repository fakes serve fixed rows; other methods are unused.
+type billRepo struct {
+	bills.Repository
+	byURL map[string]*bills.Bill
+}
+
+func (r billRepo) GetByFileURL(ctx context.Context, fileURL string) (*bills.Bill, error) {
+	if b, ok := r.byURL[fileURL]; ok {
+		return b, nil
+	}
+	return nil, bills.ErrBillNotFound
+}
+
+type photoRepo struct {
+	photos.Repository
+	photos []*photos.Photo
+}
+
+func (r photoRepo) GetByURL(ctx context.Context, url string) (*photos.Photo, error) {
+	for _, p := range r.photos {
+		if url == p.URL || url == p.Thumbnails.Small || url == p.Thumbnails.Medium || url == p.Thumbnails.Large {
+			return p, nil
+		}
+	}
+	return nil, photos.ErrPhotoNotFound
+}
+
+type productRepo struct {
+	products.Repository
+	byID map[int]*products.Product
+}
+
+func (r productRepo) GetByID(ctx context.Context, id int) (*products.Product, error) {
+	if p, ok := r.byID[id]; ok {
+		return p, nil
+	}
+	return nil, products.ErrProductNotFound
+}
+
+type userRepo struct {
+	users.Repository
+	byUUID map[string]*users.User
+}
+
+func (r userRepo) GetByUUID(ctx context.Context, uuid string) (*users.User, error) {
+	if u, ok := r.byUUID[uuid]; ok {
+		return u, nil
+	}
+	return nil, users.ErrNotFound
+}
+
+// fixture is user 1 (uuid-1) with a bill, a photo and an avatar on local
+// storage, next to a file nothing references.
+type fixture struct {
+	service                        Service
+	billURL, photoURL, thumbURL    string
+	avatarURL, oldAvatarURL, stray string
+}
+
+func newFixture(t *testing.T) *fixture {
+	ctx := context.Background()
+	store, _ := storage.NewLocalStorage(t.TempDir(), baseURL)
+	upload := func(name, content string) string {
+		url, err := store.Upload(ctx, strings.NewReader(content), name)
+		assert.NoError(t, err)
+		return url
+	}
+
+	f := &fixture{
+		billURL:      upload("uuid-1/bills/invoice.html", "<script>alert(1)</script>"),
+		photoURL:     upload("uuid-1/products/10/fridge.png", "png"),
+		thumbURL:     upload("uuid-1/products/10/thumbnails/small.jpg", "jpeg"),
+		avatarURL:    upload("uuid-1/avatar/me.png", "avatar"),
+		oldAvatarURL: upload("uuid-1/avatar/old.png", "old avatar"),
+		stray:        upload("uuid-1/notes.txt", "stray"),
+	}
+	f.service = NewService(
+		billRepo{byURL: map[string]*bills.Bill{f.billURL: {ID: 5, UserID: 1, ProductID: 10, FileURL: f.billURL}}},
+		photoRepo{photos: []*photos.Photo{{ID: 7, ProductID: 10, URL: f.photoURL, ContentType: "image/png", Thumbnails: photos.Thumbnails{Small: f.thumbURL}}}},
+		productRepo{byID: map[int]*products.Product{10: {ID: 10, UserID: 1}}},
+		userRepo{byUUID: map[string]*users.User{"uuid-1": {ID: 1, UUID: "uuid-1", AvatarURL: f.avatarURL}}},
+		store,
+		baseURL,
+	)
+	return f
+}
+
+func key(url string) string {
+	return strings.TrimPrefix(url, baseURL+"/")
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Owner", func(t *testing.T) {
+		f := newFixture(t)
+		cases := map[string]struct {
+			url, content, contentType string
+			attachment                bool
+		}{
+			"Bill":      {f.billURL, "<script>alert(1)</script>", "", true},
+			"Photo":     {f.photoURL, "png", "image/png", false},
+			"Thumbnail": {f.thumbURL, "jpeg", "image/jpeg", false},
+			"Avatar":    {f.avatarURL, "avatar", "", false},
+		}
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				file, err := f.service.Open(ctx, 1, key(c.url))
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer file.Close()
+
+				content, _ := io.ReadAll(file)
+				assert.Equal(t, c.content, string(content))
+				assert.Equal(t, c.contentType, file.ContentType)
+				assert.Equal(t, c.attachment, file.Attachment)
+			})
+		}
+	})
+
+	t.Run("Other User", func(t *testing.T) {
+		f := newFixture(t)
+		for _, url := range []string{f.billURL, f.photoURL, f.thumbURL, f.avatarURL} {
+			_, err := f.service.Open(ctx, 2, key(url))
+			assert.ErrorIs(t, err, authz.ErrForbidden, url)
+		}
+	})
+
+	t.Run("Not Referenced", func(t *testing.T) {
+		f := newFixture(t)
+		// Stored, but no row points at them (any more)
+		for _, k := range []string{key(f.stray), key(f.oldAvatarURL), "uuid-1/bills", "uuid-1/", "", "../secret"} {
+			_, err := f.service.Open(ctx, 1, k)
+			assert.ErrorIs(t, err, ErrFileNotFound, k)
+		}
+	})
+}
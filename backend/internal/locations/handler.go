@@ -0,0 +1,182 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/authz"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) ListLocations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	locations, err := h.service.ListLocations(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to fetch locations")
+		return
+	}
+
+	json.NewEncoder(w).Encode(locations)
+}
+
+func (h *Handler) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	location, err := h.service.CreateLocation(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create location")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(location)
+}
+
+// UpdateLocation renames a location or changes its kind.
+func (h *Handler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := locationParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	location, err := h.service.UpdateLocation(r.Context(), id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to update location")
+		return
+	}
+
+	json.NewEncoder(w).Encode(location)
+}
+
+func (h *Handler) ReparentLocation(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := locationParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReparentLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	location, err := h.service.ReparentLocation(r.Context(), id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to move location")
+		return
+	}
+
+	json.NewEncoder(w).Encode(location)
+}
+
+func (h *Handler) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := locationParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteLocation(r.Context(), id, userID); err != nil {
+		writeServiceError(w, err, "Failed to delete location")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListStats handles GET /locations/stats.
+func (h *Handler) ListStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	stats, err := h.service.ListStats(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to fetch location stats")
+		return
+	}
+
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetStats handles GET /locations/{id}/stats.
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := locationParams(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.GetStats(r.Context(), id, userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to fetch location stats")
+		return
+	}
+
+	json.NewEncoder(w).Encode(stats)
+}
+
+// locationParams reads the caller and the {id} path value, writing the error
+// response if either is missing.
+func locationParams(w http.ResponseWriter, r *http.Request) (userID, id int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid location ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrLocationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, ErrNameTaken), errors.Is(err, ErrLocationNotEmpty):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrNameRequired), errors.Is(err, ErrInvalidKind), errors.Is(err, ErrParentNotFound),
+		errors.Is(err, ErrLocationCycle), errors.Is(err, ErrInvalidNesting):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,80 @@
+package locations
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of location, from the outermost. A location can only be inside one
+// of an outer kind, except that containers nest (a box in a cupboard).
+const (
+	KindProperty  = "property"
+	KindFloor     = "floor"
+	KindRoom      = "room"
+	KindContainer = "container"
+)
+
+var kindRanks = map[string]int{
+	KindProperty:  0,
+	KindFloor:     1,
+	KindRoom:      2,
+	KindContainer: 3,
+}
+
+type Location struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	ParentID  *int      `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OwnerID implements authz.Owned.
+func (l *Location) OwnerID() int { return l.UserID }
+
+type CreateLocationRequest struct {
+	UserID   int    `json:"-"` // From Context/Auth
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	ParentID *int   `json:"parent_id,omitempty"`
+}
+
+// UpdateLocationRequest is a partial update: fields left out stay unchanged.
+type UpdateLocationRequest struct {
+	UserID int     `json:"-"` // From Context/Auth
+	Name   *string `json:"name"`
+	Kind   *string `json:"kind"`
+}
+
+type ReparentLocationRequest struct {
+	UserID   int  `json:"-"`         // From Context/Auth
+	ParentID *int `json:"parent_id"` // Null moves the location to the top level
+}
+
+// Stats counts the products in a location, including those in the
+// locations inside it, and sums their prices.
+type Stats struct {
+	LocationID   int     `json:"location_id"`
+	ProductCount int     `json:"product_count"`
+	TotalValue   float64 `json:"total_value"`
+}
+
+type Repository interface {
+	Create(ctx context.Context, location *Location) error
+	GetByID(ctx context.Context, id int) (*Location, error)
+	// GetByName finds the user's location with the name under parentID,
+	// ignoring case. It returns ErrLocationNotFound if there is none.
+	GetByName(ctx context.Context, userID int, parentID *int, name string) (*Location, error)
+	// ListByUserID returns the user's locations by name.
+	ListByUserID(ctx context.Context, userID int) ([]*Location, error)
+	// Update saves name, kind and parent, and the name on the location's
+	// products.
+	Update(ctx context.Context, location *Location) error
+	// Delete removes the location; its products are left without one.
+	Delete(ctx context.Context, id int) error
+	// ProductStats returns the count and value of the products directly in
+	// each of the user's locations that has any.
+	ProductStats(ctx context.Context, userID int) ([]*Stats, error)
+}
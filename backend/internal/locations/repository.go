@@ -0,0 +1,164 @@
+package locations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrLocationNotFound = errors.New("location not found")
+
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLRepository(db *sql.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+const locationColumns = `id, user_id, parent_id, name, kind, created_at, updated_at`
+
+func scanLocation(row interface{ Scan(...any) error }) (*Location, error) {
+	var l Location
+	if err := row.Scan(&l.ID, &l.UserID, &l.ParentID, &l.Name, &l.Kind, &l.CreatedAt, &l.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *MySQLRepository) Create(ctx context.Context, location *Location) error {
+	query := `
+		INSERT INTO keepsy_locations (user_id, parent_id, name, kind, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	location.CreatedAt = time.Now()
+	location.UpdatedAt = location.CreatedAt
+
+	result, err := r.db.ExecContext(ctx, query,
+		location.UserID, location.ParentID, location.Name, location.Kind, location.CreatedAt, location.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create location: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	location.ID = int(id)
+	return nil
+}
+
+func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM keepsy_locations WHERE id = ?`
+	return r.getOne(ctx, query, id)
+}
+
+func (r *MySQLRepository) GetByName(ctx context.Context, userID int, parentID *int, name string) (*Location, error) {
+	// <=> matches NULL parents too; the column's collation ignores case
+	query := `SELECT ` + locationColumns + ` FROM keepsy_locations WHERE user_id = ? AND parent_id <=> ? AND name = ? LIMIT 1`
+	return r.getOne(ctx, query, userID, parentID, name)
+}
+
+func (r *MySQLRepository) ListByUserID(ctx context.Context, userID int) ([]*Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM keepsy_locations WHERE user_id = ? ORDER BY name ASC, id ASC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []*Location
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan location: %w", err)
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
+
+func (r *MySQLRepository) Update(ctx context.Context, location *Location) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE keepsy_locations SET parent_id = ?, name = ?, kind = ?, updated_at = ? WHERE id = ?`
+	location.UpdatedAt = time.Now()
+
+	if _, err := tx.ExecContext(ctx, query,
+		location.ParentID, location.Name, location.Kind, location.UpdatedAt, location.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update location: %w", err)
+	}
+
+	// Products keep a copy of the name for search
+	if _, err := tx.ExecContext(ctx, `UPDATE keepsy_products SET location = ? WHERE location_id = ?`, location.Name, location.ID); err != nil {
+		return fmt.Errorf("failed to rename product locations: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE keepsy_products SET location_id = NULL, location = '' WHERE location_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to clear product locations: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_locations WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete location: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) ProductStats(ctx context.Context, userID int) ([]*Stats, error) {
+	query := `
+		SELECT location_id, COUNT(*), COALESCE(SUM(price), 0)
+		FROM keepsy_products
+		WHERE user_id = ? AND location_id IS NOT NULL
+		GROUP BY location_id
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count products by location: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []*Stats
+	for rows.Next() {
+		var s Stats
+		if err := rows.Scan(&s.LocationID, &s.ProductCount, &s.TotalValue); err != nil {
+			return nil, fmt.Errorf("failed to scan location stats: %w", err)
+		}
+		stats = append(stats, &s)
+	}
+	return stats, rows.Err()
+}
+
+func (r *MySQLRepository) getOne(ctx context.Context, query string, args ...any) (*Location, error) {
+	location, err := scanLocation(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLocationNotFound
+		}
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+	return location, nil
+}
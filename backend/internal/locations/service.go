@@ -0,0 +1,306 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/services/authz"
+	"strings"
+)
+
+var (
+	ErrNameRequired     = errors.New("name is required")
+	ErrInvalidKind      = errors.New("kind must be property, floor, room or container")
+	ErrNameTaken        = errors.New("a location with this name already exists here")
+	ErrParentNotFound   = errors.New("parent location not found")
+	ErrLocationCycle    = errors.New("a location can't be moved inside itself")
+	ErrInvalidNesting   = errors.New("invalid nesting")
+	ErrLocationNotEmpty = errors.New("location has locations inside it")
+)
+
+type Service interface {
+	ListLocations(ctx context.Context, userID int) ([]*Location, error)
+	CreateLocation(ctx context.Context, req CreateLocationRequest) (*Location, error)
+	UpdateLocation(ctx context.Context, id int, req UpdateLocationRequest) (*Location, error)
+	ReparentLocation(ctx context.Context, id int, req ReparentLocationRequest) (*Location, error)
+	// DeleteLocation removes an empty location; its products are left
+	// without one.
+	DeleteLocation(ctx context.Context, id, userID int) error
+	// ListStats returns the stats of each of the user's locations.
+	ListStats(ctx context.Context, userID int) ([]*Stats, error)
+	GetStats(ctx context.Context, id, userID int) (*Stats, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) ListLocations(ctx context.Context, userID int) ([]*Location, error) {
+	locations, err := s.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if locations == nil {
+		locations = []*Location{}
+	}
+	return locations, nil
+}
+
+func (s *service) CreateLocation(ctx context.Context, req CreateLocationRequest) (*Location, error) {
+	location := &Location{UserID: req.UserID, ParentID: req.ParentID, Name: NormalizeName(req.Name), Kind: req.Kind}
+	if location.Name == "" {
+		return nil, ErrNameRequired
+	}
+	if _, ok := kindRanks[location.Kind]; !ok {
+		return nil, ErrInvalidKind
+	}
+
+	if req.ParentID != nil {
+		parent, err := s.parent(ctx, *req.ParentID, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkNesting(parent, location); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkName(ctx, location); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+func (s *service) UpdateLocation(ctx context.Context, id int, req UpdateLocationRequest) (*Location, error) {
+	location, err := s.ownedLocation(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := NormalizeName(*req.Name)
+		if name == "" {
+			return nil, ErrNameRequired
+		}
+		if name != location.Name {
+			location.Name = name
+			if err := s.checkName(ctx, location); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if req.Kind != nil && *req.Kind != location.Kind {
+		if _, ok := kindRanks[*req.Kind]; !ok {
+			return nil, ErrInvalidKind
+		}
+		location.Kind = *req.Kind
+
+		// The new kind has to fit between the parent and the children
+		all, err := s.repo.ListByUserID(ctx, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range all {
+			if location.ParentID != nil && other.ID == *location.ParentID {
+				if err := checkNesting(other, location); err != nil {
+					return nil, err
+				}
+			}
+			if other.ParentID != nil && *other.ParentID == id {
+				if err := checkNesting(location, other); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := s.repo.Update(ctx, location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+func (s *service) ReparentLocation(ctx context.Context, id int, req ReparentLocationRequest) (*Location, error) {
+	location, err := s.ownedLocation(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ParentID != nil {
+		parent, err := s.parent(ctx, *req.ParentID, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkCycle(ctx, req.UserID, id, *req.ParentID); err != nil {
+			return nil, err
+		}
+		if err := checkNesting(parent, location); err != nil {
+			return nil, err
+		}
+	}
+
+	location.ParentID = req.ParentID
+	if err := s.checkName(ctx, location); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+func (s *service) DeleteLocation(ctx context.Context, id, userID int) error {
+	if _, err := s.ownedLocation(ctx, id, userID); err != nil {
+		return err
+	}
+
+	all, err := s.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, other := range all {
+		if other.ParentID != nil && *other.ParentID == id {
+			return ErrLocationNotEmpty
+		}
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *service) ListStats(ctx context.Context, userID int) ([]*Stats, error) {
+	all, err := s.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	direct, err := s.repo.ProductStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*Stats, len(all))
+	byID := make(map[int]*Stats, len(all))
+	parents := make(map[int]*int, len(all))
+	for i, l := range all {
+		stats[i] = &Stats{LocationID: l.ID}
+		byID[l.ID] = stats[i]
+		parents[l.ID] = l.ParentID
+	}
+
+	// Add each location's own products to it and all locations around it
+	for _, d := range direct {
+		seen := make(map[int]bool)
+		for current := &d.LocationID; current != nil && !seen[*current]; current = parents[*current] {
+			seen[*current] = true
+			if total, ok := byID[*current]; ok {
+				total.ProductCount += d.ProductCount
+				total.TotalValue += d.TotalValue
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (s *service) GetStats(ctx context.Context, id, userID int) (*Stats, error) {
+	if _, err := s.ownedLocation(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.ListStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range stats {
+		if st.LocationID == id {
+			return st, nil
+		}
+	}
+	return &Stats{LocationID: id}, nil
+}
+
+// ownedLocation loads a location and authorizes userID to access it.
+func (s *service) ownedLocation(ctx context.Context, id, userID int) (*Location, error) {
+	location, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(userID, location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// parent loads the user's location parentID. Another user's location is
+// reported as missing, like one that doesn't exist.
+func (s *service) parent(ctx context.Context, parentID, userID int) (*Location, error) {
+	parent, err := s.repo.GetByID(ctx, parentID)
+	if err != nil {
+		if errors.Is(err, ErrLocationNotFound) {
+			return nil, ErrParentNotFound
+		}
+		return nil, err
+	}
+	if authz.Authorize(userID, parent) != nil {
+		return nil, ErrParentNotFound
+	}
+	return parent, nil
+}
+
+// checkName fails if another location under the same parent has the
+// location's name.
+func (s *service) checkName(ctx context.Context, location *Location) error {
+	other, err := s.repo.GetByName(ctx, location.UserID, location.ParentID, location.Name)
+	switch {
+	case errors.Is(err, ErrLocationNotFound):
+		return nil
+	case err != nil:
+		return err
+	case other.ID != location.ID:
+		return ErrNameTaken
+	}
+	return nil
+}
+
+// checkCycle walks up from the new parent and fails if it reaches the
+// location itself, i.e. the parent is the location or one inside it.
+func (s *service) checkCycle(ctx context.Context, userID, id, parentID int) error {
+	all, err := s.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	parents := make(map[int]*int, len(all))
+	for _, l := range all {
+		parents[l.ID] = l.ParentID
+	}
+
+	seen := make(map[int]bool)
+	for current := &parentID; current != nil; current = parents[*current] {
+		if *current == id || seen[*current] {
+			return ErrLocationCycle
+		}
+		seen[*current] = true
+	}
+	return nil
+}
+
+// checkNesting fails unless a location of child's kind can be inside one of
+// parent's kind.
+func checkNesting(parent, child *Location) error {
+	outer, inner := kindRanks[parent.Kind], kindRanks[child.Kind]
+	if inner > outer || (child.Kind == KindContainer && parent.Kind == KindContainer) {
+		return nil
+	}
+	return fmt.Errorf("%w: a %s can't be inside a %s", ErrInvalidNesting, child.Kind, parent.Kind)
+}
+
+// NormalizeName trims the name and collapses runs of whitespace, so
+// "Kitchen " and "Kitchen" are the same place.
+func NormalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
@@ -0,0 +1,253 @@
+package locations
+
+import (
+	"context"
+	"testing"
+
+	"keepsy-backend/internal/services/authz"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Create(ctx context.Context, location *Location) error {
+	args := m.Called(ctx, location)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetByID(ctx context.Context, id int) (*Location, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Location), args.Error(1)
+}
+
+func (m *MockRepo) GetByName(ctx context.Context, userID int, parentID *int, name string) (*Location, error) {
+	args := m.Called(ctx, userID, parentID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Location), args.Error(1)
+}
+
+func (m *MockRepo) ListByUserID(ctx context.Context, userID int) ([]*Location, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Location), args.Error(1)
+}
+
+func (m *MockRepo) Update(ctx context.Context, location *Location) error {
+	args := m.Called(ctx, location)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ProductStats(ctx context.Context, userID int) ([]*Stats, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Stats), args.Error(1)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// home returns user 1's Home (1) > Ground Floor (2) > Kitchen (3) >
+// Cupboard (4), and the Garage (5) of Home.
+func home() []*Location {
+	return []*Location{
+		{ID: 1, UserID: 1, Name: "Home", Kind: KindProperty},
+		{ID: 2, UserID: 1, ParentID: intPtr(1), Name: "Ground Floor", Kind: KindFloor},
+		{ID: 3, UserID: 1, ParentID: intPtr(2), Name: "Kitchen", Kind: KindRoom},
+		{ID: 4, UserID: 1, ParentID: intPtr(3), Name: "Cupboard", Kind: KindContainer},
+		{ID: 5, UserID: 1, ParentID: intPtr(1), Name: "Garage", Kind: KindRoom},
+	}
+}
+
+// mockHome sets up GetByID and ListByUserID for home().
+func mockHome(repo *MockRepo) []*Location {
+	all := home()
+	for _, l := range all {
+		repo.On("GetByID", mock.Anything, l.ID).Return(l, nil).Maybe()
+	}
+	repo.On("ListByUserID", mock.Anything, 1).Return(all, nil).Maybe()
+	return all
+}
+
+func TestCreateLocation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+		repo.On("GetByName", ctx, 1, intPtr(3), "Spice Rack").Return(nil, ErrLocationNotFound)
+		repo.On("Create", ctx, mock.AnythingOfType("*locations.Location")).Return(nil)
+
+		location, err := service.CreateLocation(ctx, CreateLocationRequest{
+			UserID: 1, Name: "  Spice   Rack ", Kind: KindContainer, ParentID: intPtr(3),
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Spice Rack", location.Name)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Name Taken", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		repo.On("GetByName", ctx, 1, (*int)(nil), "kitchen").Return(&Location{ID: 9, UserID: 1, Name: "Kitchen"}, nil)
+
+		_, err := service.CreateLocation(ctx, CreateLocationRequest{UserID: 1, Name: "kitchen ", Kind: KindRoom})
+
+		assert.Equal(t, ErrNameTaken, err)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid Nesting", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+
+		_, err := service.CreateLocation(ctx, CreateLocationRequest{UserID: 1, Name: "Attic", Kind: KindFloor, ParentID: intPtr(3)})
+
+		assert.ErrorIs(t, err, ErrInvalidNesting)
+		assert.EqualError(t, err, "invalid nesting: a floor can't be inside a room")
+	})
+
+	t.Run("Invalid Kind", func(t *testing.T) {
+		service := NewService(new(MockRepo))
+
+		_, err := service.CreateLocation(ctx, CreateLocationRequest{UserID: 1, Name: "Shed", Kind: "building"})
+
+		assert.Equal(t, ErrInvalidKind, err)
+	})
+
+	t.Run("Other Users Parent", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+
+		_, err := service.CreateLocation(ctx, CreateLocationRequest{UserID: 2, Name: "Shelf", Kind: KindContainer, ParentID: intPtr(3)})
+
+		assert.Equal(t, ErrParentNotFound, err)
+	})
+}
+
+func TestUpdateLocation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Kind Must Fit Children", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+
+		// The Ground Floor has the Kitchen inside it
+		kind := KindContainer
+		_, err := service.UpdateLocation(ctx, 2, UpdateLocationRequest{UserID: 1, Kind: &kind})
+
+		assert.ErrorIs(t, err, ErrInvalidNesting)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Other User", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+
+		name := "Mine"
+		_, err := service.UpdateLocation(ctx, 3, UpdateLocationRequest{UserID: 2, Name: &name})
+
+		assert.Equal(t, authz.ErrForbidden, err)
+	})
+}
+
+func TestReparentLocation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+		repo.On("GetByName", ctx, 1, intPtr(5), "Cupboard").Return(nil, ErrLocationNotFound)
+		repo.On("Update", ctx, mock.MatchedBy(func(l *Location) bool { return *l.ParentID == 5 })).Return(nil)
+
+		_, err := service.ReparentLocation(ctx, 4, ReparentLocationRequest{UserID: 1, ParentID: intPtr(5)})
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+
+		_, err := service.ReparentLocation(ctx, 2, ReparentLocationRequest{UserID: 1, ParentID: intPtr(4)})
+
+		assert.Equal(t, ErrLocationCycle, err)
+	})
+}
+
+func TestDeleteLocation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Not Empty", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+
+		err := service.DeleteLocation(ctx, 3, 1)
+
+		assert.Equal(t, ErrLocationNotEmpty, err)
+		repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockHome(repo)
+		repo.On("Delete", ctx, 4).Return(nil)
+
+		err := service.DeleteLocation(ctx, 4, 1)
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestListStats(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	service := NewService(repo)
+	mockHome(repo)
+	repo.On("ProductStats", ctx, 1).Return([]*Stats{
+		{LocationID: 4, ProductCount: 2, TotalValue: 150},
+		{LocationID: 3, ProductCount: 1, TotalValue: 30000},
+		{LocationID: 5, ProductCount: 1, TotalValue: 800},
+	}, nil)
+
+	stats, err := service.ListStats(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*Stats{
+		{LocationID: 1, ProductCount: 4, TotalValue: 30950},
+		{LocationID: 2, ProductCount: 3, TotalValue: 30150},
+		{LocationID: 3, ProductCount: 3, TotalValue: 30150},
+		{LocationID: 4, ProductCount: 2, TotalValue: 150},
+		{LocationID: 5, ProductCount: 1, TotalValue: 800},
+	}, stats)
+}
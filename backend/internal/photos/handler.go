@@ -0,0 +1,153 @@
+package photos
+
+import (
+	"encoding/json"
+	"errors"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/authz"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+
+	// Limit upload size to 10MB
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		http.Error(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	photo, err := h.service.UploadPhoto(r.Context(), productID, userID, file, header.Filename)
+	if err != nil {
+		writeServiceError(w, err, "Failed to upload photo")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(photo)
+}
+
+func (h *Handler) ListPhotos(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+
+	photos, err := h.service.ListPhotos(r.Context(), productID, userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to list photos")
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// ReorderPhotos handles PUT /products/{id}/photos/order.
+func (h *Handler) ReorderPhotos(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req OrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	photos, err := h.service.ReorderPhotos(r.Context(), productID, userID, req.PhotoIDs)
+	if err != nil {
+		writeServiceError(w, err, "Failed to reorder photos")
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// SetPrimaryPhoto handles PUT /products/{id}/photos/{photoID}/primary.
+func (h *Handler) SetPrimaryPhoto(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("photoID"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return
+	}
+
+	photos, err := h.service.SetPrimaryPhoto(r.Context(), productID, id, userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to set primary photo")
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+func (h *Handler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("photoID"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeletePhoto(r.Context(), productID, id, userID); err != nil {
+		writeServiceError(w, err, "Failed to delete photo")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// productParams reads the caller and the {id} product path value, writing
+// the error response if either is missing.
+func productParams(w http.ResponseWriter, r *http.Request) (userID, productID int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	productID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, productID, true
+}
+
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrPhotoNotFound), errors.Is(err, products.ErrProductNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, ErrUnsupportedImage), errors.Is(err, ErrInvalidOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
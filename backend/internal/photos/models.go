@@ -0,0 +1,65 @@
+package photos
+
+import (
+	"context"
+	"time"
+)
+
+// Thumbnail sizes: the longest side in pixels. Images are never upscaled.
+const (
+	SizeSmall  = 200
+	SizeMedium = 600
+	SizeLarge  = 1200
+)
+
+type Photo struct {
+	ID          int        `json:"id"`
+	ProductID   int        `json:"product_id"`
+	URL         string     `json:"url"`
+	ContentType string     `json:"content_type"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	Thumbnails  Thumbnails `json:"thumbnails"`
+	Position    int        `json:"position"`
+	Primary     bool       `json:"primary"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Thumbnails are JPEG URLs, one per size.
+type Thumbnails struct {
+	Small  string `json:"small"`
+	Medium string `json:"medium"`
+	Large  string `json:"large"`
+}
+
+// FileURLs returns the URLs of the original and its thumbnails.
+func (p *Photo) FileURLs() []string {
+	return []string{p.URL, p.Thumbnails.Small, p.Thumbnails.Medium, p.Thumbnails.Large}
+}
+
+// OrderRequest lists all of a product's photo IDs in their new order.
+type OrderRequest struct {
+	PhotoIDs []int `json:"photo_ids"`
+}
+
+type Repository interface {
+	// Create adds the photo after the product's other photos. The first
+	// photo of a product becomes its primary photo.
+	Create(ctx context.Context, photo *Photo) error
+	GetByID(ctx context.Context, id int) (*Photo, error)
+	// GetByURL returns the photo whose original or one of its thumbnails
+	// is stored at url.
+	GetByURL(ctx context.Context, url string) (*Photo, error)
+	// ListByProductID returns the product's photos in their order.
+	ListByProductID(ctx context.Context, productID int) ([]*Photo, error)
+	// ListByUserID returns the photos of all the user's products.
+	ListByUserID(ctx context.Context, userID int) ([]*Photo, error)
+	// UpdateURLs saves the URLs of the original and its thumbnails.
+	UpdateURLs(ctx context.Context, photo *Photo) error
+	// Reorder sets the position of each photo to its index in photoIDs.
+	Reorder(ctx context.Context, productID int, photoIDs []int) error
+	SetPrimary(ctx context.Context, productID, id int) error
+	// Delete removes the photo. If it was the primary photo, the first of
+	// the remaining ones takes over.
+	Delete(ctx context.Context, id int) error
+}
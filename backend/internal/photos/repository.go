@@ -0,0 +1,221 @@
+package photos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrPhotoNotFound = errors.New("photo not found")
+
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLRepository(db *sql.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+const photoColumns = `id, product_id, file_url, content_type, width, height,
+	thumbnail_small_url, thumbnail_medium_url, thumbnail_large_url, position, is_primary, created_at`
+
+func scanPhoto(row interface{ Scan(...any) error }) (*Photo, error) {
+	var p Photo
+	err := row.Scan(
+		&p.ID, &p.ProductID, &p.URL, &p.ContentType, &p.Width, &p.Height,
+		&p.Thumbnails.Small, &p.Thumbnails.Medium, &p.Thumbnails.Large, &p.Position, &p.Primary, &p.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *MySQLRepository) Create(ctx context.Context, photo *Photo) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the product's photos, so concurrent uploads get distinct positions
+	var count, last int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(MAX(position), -1) FROM keepsy_product_photos WHERE product_id = ? FOR UPDATE`,
+		photo.ProductID,
+	).Scan(&count, &last)
+	if err != nil {
+		return fmt.Errorf("failed to read photo positions: %w", err)
+	}
+	photo.Position = last + 1
+	photo.Primary = count == 0
+	photo.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO keepsy_product_photos (product_id, file_url, content_type, width, height,
+			thumbnail_small_url, thumbnail_medium_url, thumbnail_large_url, position, is_primary, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := tx.ExecContext(ctx, query,
+		photo.ProductID, photo.URL, photo.ContentType, photo.Width, photo.Height,
+		photo.Thumbnails.Small, photo.Thumbnails.Medium, photo.Thumbnails.Large,
+		photo.Position, photo.Primary, photo.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create photo: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	photo.ID = int(id)
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Photo, error) {
+	query := `SELECT ` + photoColumns + ` FROM keepsy_product_photos WHERE id = ?`
+	photo, err := scanPhoto(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPhotoNotFound
+		}
+		return nil, fmt.Errorf("failed to get photo: %w", err)
+	}
+	return photo, nil
+}
+
+func (r *MySQLRepository) GetByURL(ctx context.Context, url string) (*Photo, error) {
+	query := `SELECT ` + photoColumns + ` FROM keepsy_product_photos
+		WHERE file_url = ? OR thumbnail_small_url = ? OR thumbnail_medium_url = ? OR thumbnail_large_url = ?
+		LIMIT 1`
+	photo, err := scanPhoto(r.db.QueryRowContext(ctx, query, url, url, url, url))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPhotoNotFound
+		}
+		return nil, fmt.Errorf("failed to get photo: %w", err)
+	}
+	return photo, nil
+}
+
+func (r *MySQLRepository) ListByProductID(ctx context.Context, productID int) ([]*Photo, error) {
+	query := `SELECT ` + photoColumns + ` FROM keepsy_product_photos WHERE product_id = ? ORDER BY position, id`
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list photos: %w", err)
+	}
+	defer rows.Close()
+
+	var photos []*Photo
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan photo: %w", err)
+		}
+		photos = append(photos, photo)
+	}
+	return photos, rows.Err()
+}
+
+func (r *MySQLRepository) ListByUserID(ctx context.Context, userID int) ([]*Photo, error) {
+	query := `SELECT ` + photoColumns + ` FROM keepsy_product_photos
+		WHERE product_id IN (SELECT id FROM keepsy_products WHERE user_id = ?) ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list photos: %w", err)
+	}
+	defer rows.Close()
+
+	var photos []*Photo
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan photo: %w", err)
+		}
+		photos = append(photos, photo)
+	}
+	return photos, rows.Err()
+}
+
+func (r *MySQLRepository) UpdateURLs(ctx context.Context, photo *Photo) error {
+	query := `
+		UPDATE keepsy_product_photos
+		SET file_url = ?, thumbnail_small_url = ?, thumbnail_medium_url = ?, thumbnail_large_url = ?
+		WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query,
+		photo.URL, photo.Thumbnails.Small, photo.Thumbnails.Medium, photo.Thumbnails.Large, photo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update photo urls: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Reorder(ctx context.Context, productID int, photoIDs []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for position, id := range photoIDs {
+		query := `UPDATE keepsy_product_photos SET position = ? WHERE id = ? AND product_id = ?`
+		if _, err := tx.ExecContext(ctx, query, position, id, productID); err != nil {
+			return fmt.Errorf("failed to reorder photos: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) SetPrimary(ctx context.Context, productID, id int) error {
+	query := `UPDATE keepsy_product_photos SET is_primary = (id = ?) WHERE product_id = ?`
+	if _, err := r.db.ExecContext(ctx, query, id, productID); err != nil {
+		return fmt.Errorf("failed to set primary photo: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var productID int
+	var primary bool
+	err = tx.QueryRowContext(ctx, `SELECT product_id, is_primary FROM keepsy_product_photos WHERE id = ? FOR UPDATE`, id).
+		Scan(&productID, &primary)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPhotoNotFound
+		}
+		return fmt.Errorf("failed to get photo: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_product_photos WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete photo: %w", err)
+	}
+
+	if primary {
+		query := `UPDATE keepsy_product_photos SET is_primary = TRUE WHERE product_id = ? ORDER BY position, id LIMIT 1`
+		if _, err := tx.ExecContext(ctx, query, productID); err != nil {
+			return fmt.Errorf("failed to promote primary photo: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/users"
+	"log"
+	"path"
+	"strings"
+)
+
+// MaxPixels caps the decoded size of an upload, so a small file can't
+// expand into a huge image in memory.
+const MaxPixels = 50_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image")
+	ErrInvalidOrder     = errors.New("photo_ids must list each of the product's photos once")
+)
+
+type Service interface {
+	// UploadPhoto stores the image with its thumbnails and adds it to the
+	// end of the product's gallery.
+	UploadPhoto(ctx context.Context, productID, userID int, file io.Reader, filename string) (*Photo, error)
+	ListPhotos(ctx context.Context, productID, userID int) ([]*Photo, error)
+	// ReorderPhotos returns the photos in their new order.
+	ReorderPhotos(ctx context.Context, productID, userID int, photoIDs []int) ([]*Photo, error)
+	SetPrimaryPhoto(ctx context.Context, productID, id, userID int) ([]*Photo, error)
+	DeletePhoto(ctx context.Context, productID, id, userID int) error
+	// RelocateUserPhotos moves photos and their thumbnails that aren't
+	// stored under the user's current UUID prefix, and returns how many
+	// files moved.
+	RelocateUserPhotos(ctx context.Context, user *users.User) (int, error)
+}
+
+type service struct {
+	repo        Repository
+	productRepo products.Repository
+	userRepo    users.Repository
+	storage     storage.Service
+}
+
+func NewService(repo Repository, productRepo products.Repository, userRepo users.Repository, storage storage.Service) Service {
+	return &service{
+		repo:        repo,
+		productRepo: productRepo,
+		userRepo:    userRepo,
+		storage:     storage,
+	}
+}
+
+func (s *service) UploadPhoto(ctx context.Context, productID, userID int, file io.Reader, filename string) (*Photo, error) {
+	if _, err := s.ownedProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read photo: %w", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: use JPEG, PNG or GIF", ErrUnsupportedImage)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: image is too large", ErrUnsupportedImage)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	photo := &Photo{
+		ProductID:   productID,
+		ContentType: "image/" + format,
+		Width:       config.Width,
+		Height:      config.Height,
+	}
+	dir := photoDir(user.UUID, productID)
+
+	// Upload the original and its thumbnails, removing what was stored if
+	// any step fails
+	var uploaded []string
+	cleanup := func() {
+		for _, url := range uploaded {
+			_ = s.storage.Delete(ctx, url)
+		}
+	}
+	upload := func(data []byte, name string) (string, error) {
+		url, err := s.storage.Upload(ctx, bytes.NewReader(data), dir+name)
+		if err != nil {
+			return "", fmt.Errorf("storage upload failed: %w", err)
+		}
+		uploaded = append(uploaded, url)
+		return url, nil
+	}
+
+	if photo.URL, err = upload(data, path.Base(filename)); err != nil {
+		cleanup()
+		return nil, err
+	}
+	thumbnails := []struct {
+		size int
+		name string
+		url  *string
+	}{
+		{SizeSmall, "small", &photo.Thumbnails.Small},
+		{SizeMedium, "medium", &photo.Thumbnails.Medium},
+		{SizeLarge, "large", &photo.Thumbnails.Large},
+	}
+	for _, t := range thumbnails {
+		thumb, err := encodeThumbnail(img, t.size)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to create thumbnail: %w", err)
+		}
+		if *t.url, err = upload(thumb, "thumbnails/"+t.name+".jpg"); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Create(ctx, photo); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return photo, nil
+}
+
+func (s *service) ListPhotos(ctx context.Context, productID, userID int) ([]*Photo, error) {
+	if _, err := s.ownedProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+	return s.list(ctx, productID)
+}
+
+func (s *service) ReorderPhotos(ctx context.Context, productID, userID int, photoIDs []int) ([]*Photo, error) {
+	photos, err := s.ListPhotos(ctx, productID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(photoIDs) != len(photos) {
+		return nil, ErrInvalidOrder
+	}
+	remaining := make(map[int]bool, len(photos))
+	for _, p := range photos {
+		remaining[p.ID] = true
+	}
+	for _, id := range photoIDs {
+		if !remaining[id] {
+			return nil, ErrInvalidOrder
+		}
+		delete(remaining, id)
+	}
+
+	if err := s.repo.Reorder(ctx, productID, photoIDs); err != nil {
+		return nil, err
+	}
+	return s.list(ctx, productID)
+}
+
+func (s *service) SetPrimaryPhoto(ctx context.Context, productID, id, userID int) ([]*Photo, error) {
+	if _, err := s.ownedPhoto(ctx, productID, id, userID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetPrimary(ctx, productID, id); err != nil {
+		return nil, err
+	}
+	return s.list(ctx, productID)
+}
+
+func (s *service) DeletePhoto(ctx context.Context, productID, id, userID int) error {
+	photo, err := s.ownedPhoto(ctx, productID, id, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	// The row is gone, so a file that fails to delete is only a stray file
+	for _, url := range photo.FileURLs() {
+		if err := s.storage.Delete(ctx, url); err != nil {
+			log.Printf("failed to delete file of photo %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *service) RelocateUserPhotos(ctx context.Context, user *users.User) (int, error) {
+	photos, err := s.repo.ListByUserID(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, photo := range photos {
+		dir := photoDir(user.UUID, photo.ProductID)
+		if strings.Contains(photo.URL, "/"+dir) {
+			continue
+		}
+
+		// Moving a file twice is harmless, so a photo whose move was
+		// interrupted is simply moved again
+		files := []struct {
+			url  *string
+			name string
+		}{
+			{&photo.URL, path.Base(photo.URL)},
+			{&photo.Thumbnails.Small, "thumbnails/" + path.Base(photo.Thumbnails.Small)},
+			{&photo.Thumbnails.Medium, "thumbnails/" + path.Base(photo.Thumbnails.Medium)},
+			{&photo.Thumbnails.Large, "thumbnails/" + path.Base(photo.Thumbnails.Large)},
+		}
+		for _, f := range files {
+			url, err := s.storage.Move(ctx, *f.url, dir+f.name)
+			if err != nil {
+				return moved, fmt.Errorf("failed to move photo %d: %w", photo.ID, err)
+			}
+			*f.url = url
+		}
+		if err := s.repo.UpdateURLs(ctx, photo); err != nil {
+			return moved, err
+		}
+		moved += len(files)
+	}
+	return moved, nil
+}
+
+func (s *service) list(ctx context.Context, productID int) ([]*Photo, error) {
+	photos, err := s.repo.ListByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if photos == nil {
+		photos = []*Photo{}
+	}
+	return photos, nil
+}
+
+func (s *service) ownedProduct(ctx context.Context, productID, userID int) (*products.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(userID, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// ownedPhoto loads a photo of the product after authorizing userID to access
+// the product.
+func (s *service) ownedPhoto(ctx context.Context, productID, id, userID int) (*Photo, error) {
+	if _, err := s.ownedProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+	photo, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if photo.ProductID != productID {
+		return nil, ErrPhotoNotFound
+	}
+	return photo, nil
+}
+
+// photoDir is where a product's photos are stored: <uuid>/products/<id>/
+func photoDir(userUUID string, productID int) string {
+	return fmt.Sprintf("%s/products/%d/", userUUID, productID)
+}
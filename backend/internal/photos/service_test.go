@@ -0,0 +1,375 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Create(ctx context.Context, photo *Photo) error {
+	args := m.Called(ctx, photo)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetByID(ctx context.Context, id int) (*Photo, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Photo), args.Error(1)
+}
+
+func (m *MockRepo) GetByURL(ctx context.Context, url string) (*Photo, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Photo), args.Error(1)
+}
+
+func (m *MockRepo) ListByProductID(ctx context.Context, productID int) ([]*Photo, error) {
+	args := m.Called(ctx, productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Photo), args.Error(1)
+}
+
+func (m *MockRepo) ListByUserID(ctx context.Context, userID int) ([]*Photo, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Photo), args.Error(1)
+}
+
+func (m *MockRepo) UpdateURLs(ctx context.Context, photo *Photo) error {
+	args := m.Called(ctx, photo)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Reorder(ctx context.Context, productID int, photoIDs []int) error {
+	args := m.Called(ctx, productID, photoIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SetPrimary(ctx context.Context, productID, id int) error {
+	args := m.Called(ctx, productID, id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// productRepo serves a fixed set of products; other methods are unused.
+type productRepo struct {
+	products.Repository
+	byID map[int]*products.Product
+}
+
+func (r productRepo) GetByID(ctx context.Context, id int) (*products.Product, error) {
+	if p, ok := r.byID[id]; ok {
+		return p, nil
+	}
+	return nil, products.ErrProductNotFound
+}
+
+// userRepo serves every user with the same UUID; other methods are unused.
+type userRepo struct {
+	users.Repository
+}
+
+func (userRepo) GetByID(ctx context.Context, id int) (*users.User, error) {
+	return &users.User{ID: id, UUID: "test-uuid"}, nil
+}
+
+// memoryStorage keeps uploads in a map keyed by URL; other methods are
+// unused.
+type memoryStorage struct {
+	storage.Service
+	files map[string][]byte
+	// failAfter makes uploads fail once this many files are stored
+	failAfter int
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{files: make(map[string][]byte), failAfter: -1}
+}
+
+func (s *memoryStorage) Upload(ctx context.Context, file io.Reader, filename string) (string, error) {
+	if len(s.files) == s.failAfter {
+		return "", io.ErrShortWrite
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	url := "http://storage/" + filename
+	s.files[url] = data
+	return url, nil
+}
+
+func (s *memoryStorage) Move(ctx context.Context, url, filename string) (string, error) {
+	data, ok := s.files[url]
+	if !ok {
+		return "", fs.ErrNotExist
+	}
+	// Like LocalStorage, never overwrite another file
+	moved := "http://storage/" + filename
+	if _, taken := s.files[moved]; taken {
+		return "", fs.ErrExist
+	}
+	delete(s.files, url)
+	s.files[moved] = data
+	return moved, nil
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, url string) error {
+	delete(s.files, url)
+	return nil
+}
+
+// newTestService returns a service where user 1 owns product 10 and user 2
+// owns product 20.
+func newTestService(repo Repository, store storage.Service) Service {
+	return NewService(repo,
+		productRepo{byID: map[int]*products.Product{
+			10: {ID: 10, UserID: 1},
+			20: {ID: 20, UserID: 2},
+		}},
+		userRepo{},
+		store,
+	)
+}
+
+func encodePNG(w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 20, B: 20, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestUploadPhoto(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		store := newMemoryStorage()
+		service := newTestService(repo, store)
+		repo.On("Create", ctx, mock.AnythingOfType("*photos.Photo")).Return(nil)
+
+		photo, err := service.UploadPhoto(ctx, 10, 1, bytes.NewReader(encodePNG(1600, 800)), "fridge.png")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", photo.ContentType)
+		assert.Equal(t, 1600, photo.Width)
+		assert.Equal(t, 800, photo.Height)
+		assert.Equal(t, "http://storage/test-uuid/products/10/fridge.png", photo.URL)
+		assert.Equal(t, "http://storage/test-uuid/products/10/thumbnails/small.jpg", photo.Thumbnails.Small)
+		assert.Len(t, store.files, 4)
+
+		for url, size := range map[string]image.Point{
+			photo.Thumbnails.Small:  {200, 100},
+			photo.Thumbnails.Medium: {600, 300},
+			photo.Thumbnails.Large:  {1200, 600},
+		} {
+			thumb, err := jpeg.Decode(bytes.NewReader(store.files[url]))
+			assert.NoError(t, err, url)
+			assert.Equal(t, size, thumb.Bounds().Size(), url)
+		}
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Not An Image", func(t *testing.T) {
+		store := newMemoryStorage()
+		service := newTestService(new(MockRepo), store)
+
+		_, err := service.UploadPhoto(ctx, 10, 1, strings.NewReader("%PDF-1.4"), "bill.pdf")
+
+		assert.ErrorIs(t, err, ErrUnsupportedImage)
+		assert.Empty(t, store.files)
+	})
+
+	t.Run("Upload Failure Cleans Up", func(t *testing.T) {
+		repo := new(MockRepo)
+		store := newMemoryStorage()
+		store.failAfter = 2
+		service := newTestService(repo, store)
+
+		_, err := service.UploadPhoto(ctx, 10, 1, bytes.NewReader(encodePNG(10, 10)), "fridge.png")
+
+		assert.Error(t, err)
+		assert.Empty(t, store.files)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Other User's Product", func(t *testing.T) {
+		store := newMemoryStorage()
+		service := newTestService(new(MockRepo), store)
+
+		_, err := service.UploadPhoto(ctx, 20, 1, bytes.NewReader(encodePNG(10, 10)), "tv.png")
+
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		assert.Empty(t, store.files)
+	})
+}
+
+func TestReorderPhotos(t *testing.T) {
+	ctx := context.Background()
+	photos := []*Photo{{ID: 1, ProductID: 10}, {ID: 2, ProductID: 10}, {ID: 3, ProductID: 10}}
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo, newMemoryStorage())
+		repo.On("ListByProductID", ctx, 10).Return(photos, nil)
+		repo.On("Reorder", ctx, 10, []int{3, 1, 2}).Return(nil)
+
+		_, err := service.ReorderPhotos(ctx, 10, 1, []int{3, 1, 2})
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, ids := range [][]int{{3, 1}, {3, 1, 1}, {3, 1, 9}} {
+			repo := new(MockRepo)
+			service := newTestService(repo, newMemoryStorage())
+			repo.On("ListByProductID", ctx, 10).Return(photos, nil)
+
+			_, err := service.ReorderPhotos(ctx, 10, 1, ids)
+
+			assert.ErrorIs(t, err, ErrInvalidOrder, ids)
+			repo.AssertNotCalled(t, "Reorder", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
+}
+
+func TestSetPrimaryPhoto(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Photo Of Another Product", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo, newMemoryStorage())
+		repo.On("GetByID", ctx, 4).Return(&Photo{ID: 4, ProductID: 20}, nil)
+
+		_, err := service.SetPrimaryPhoto(ctx, 10, 4, 1)
+
+		assert.ErrorIs(t, err, ErrPhotoNotFound)
+		repo.AssertNotCalled(t, "SetPrimary", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeletePhoto(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	store := newMemoryStorage()
+	service := newTestService(repo, store)
+
+	photo := &Photo{ID: 4, ProductID: 10, URL: "http://storage/a.png", Thumbnails: Thumbnails{
+		Small: "http://storage/s.jpg", Medium: "http://storage/m.jpg", Large: "http://storage/l.jpg",
+	}}
+	for _, url := range photo.FileURLs() {
+		store.files[url] = []byte("x")
+	}
+	store.files["http://storage/other.png"] = []byte("x")
+	repo.On("GetByID", ctx, 4).Return(photo, nil)
+	repo.On("Delete", ctx, 4).Return(nil)
+
+	err := service.DeletePhoto(ctx, 10, 4, 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, store.files, 1)
+	assert.Contains(t, store.files, "http://storage/other.png")
+}
+
+func TestRelocateUserPhotos(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	store := newMemoryStorage()
+	service := newTestService(repo, store)
+
+	// Named like Upload stores them: <ts>_<name>
+	photo := func(id int, ts string) *Photo {
+		dir := "http://storage/old-uuid/products/10/"
+		return &Photo{ID: id, ProductID: 10, URL: dir + ts + "_a.png", Thumbnails: Thumbnails{
+			Small:  dir + "thumbnails/" + ts + "_small.jpg",
+			Medium: dir + "thumbnails/" + ts + "_medium.jpg",
+			Large:  dir + "thumbnails/" + ts + "_large.jpg",
+		}}
+	}
+	first, second := photo(4, "100"), photo(5, "200")
+	current := &Photo{ID: 6, ProductID: 11, URL: "http://storage/new-uuid/products/11/300_b.png"}
+	for _, p := range []*Photo{first, second} {
+		for _, url := range p.FileURLs() {
+			store.files[url] = []byte("x")
+		}
+	}
+	repo.On("ListByUserID", ctx, 1).Return([]*Photo{first, second, current}, nil)
+	repo.On("UpdateURLs", ctx, mock.Anything).Return(nil)
+
+	moved, err := service.RelocateUserPhotos(ctx, &users.User{ID: 1, UUID: "new-uuid"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 8, moved)
+	assert.Equal(t, "http://storage/new-uuid/products/10/200_a.png", second.URL)
+	assert.Equal(t, Thumbnails{
+		Small:  "http://storage/new-uuid/products/10/thumbnails/200_small.jpg",
+		Medium: "http://storage/new-uuid/products/10/thumbnails/200_medium.jpg",
+		Large:  "http://storage/new-uuid/products/10/thumbnails/200_large.jpg",
+	}, second.Thumbnails)
+	assert.Len(t, store.files, 8)
+	for _, p := range []*Photo{first, second} {
+		for _, url := range p.FileURLs() {
+			assert.Contains(t, store.files, url)
+		}
+	}
+	repo.AssertNumberOfCalls(t, "UpdateURLs", 2)
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		c := uint8(0)
+		if x%2 == 1 {
+			c = 200
+		}
+		src.Set(x, 0, color.RGBA{R: c, G: c, B: c, A: 255})
+		src.Set(x, 1, color.RGBA{R: c, G: c, B: c, A: 255})
+	}
+
+	small := resize(src, 2)
+
+	assert.Equal(t, image.Pt(2, 1), small.Bounds().Size())
+	// Each pixel averages a black and a grey column
+	r, _, _, _ := small.At(0, 0).RGBA()
+	assert.Equal(t, uint32(100), r>>8)
+
+	// Never upscales
+	assert.Equal(t, src, resize(src, 10))
+}
@@ -2,8 +2,17 @@ package products
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/locations"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/authz"
+	"keepsy-backend/internal/warranty"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Handler struct {
@@ -21,16 +30,16 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// In a real app we'd get UserID from context (middleware)
-	// ensuring req.UserID matches token or is set by middleware
-	if req.UserID == 0 {
-		http.Error(w, "UserID is required", http.StatusBadRequest)
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	req.UserID = userID
 
 	product, err := h.service.CreateProduct(r.Context(), req)
 	if err != nil {
-		http.Error(w, "Failed to create product: "+err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err, "Failed to create product")
 		return
 	}
 
@@ -39,6 +48,12 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
 		http.Error(w, "Missing product ID", http.StatusBadRequest)
@@ -51,36 +66,227 @@ func (h *Handler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product, err := h.service.GetProduct(r.Context(), id)
+	product, err := h.service.GetProduct(r.Context(), id, userID)
 	if err != nil {
-		http.Error(w, "Product not found", http.StatusNotFound)
+		writeServiceError(w, err, "Failed to get product")
 		return
 	}
 
 	json.NewEncoder(w).Encode(product)
 }
 
+// ListProducts returns the current user's products, one page at a time.
+//
+// Filters: category_id, location_id (including the locations inside it),
+// brand, min_price, max_price, purchased_from, purchased_to (YYYY-MM-DD),
+// warranty (active, expired, none), tag_ids (comma-separated, with
+// tag_match any or all) and attr.<key> for attribute values, like
+// attr.ram_gb=16.
+// Sorting: sort (created_at, name, price, purchase_date, warranty_end_date)
+// and order (asc, desc; newest first by default).
+// Paging: limit and cursor, taken from next_cursor of the previous page.
 func (h *Handler) ListProducts(w http.ResponseWriter, r *http.Request) {
-	userIDStr := r.URL.Query().Get("user_id")
-	if userIDStr == "" {
-		http.Error(w, "Missing user_id", http.StatusBadRequest)
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
+	query, err := parseListQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	query.UserID = userID
 
-	products, err := h.service.ListProducts(r.Context(), userID)
+	page, err := h.service.ListProducts(r.Context(), query, r.URL.Query().Get("cursor"))
 	if err != nil {
-		http.Error(w, "Failed to list products", http.StatusInternalServerError)
+		writeServiceError(w, err, "Failed to list products")
+		return
+	}
+
+	json.NewEncoder(w).Encode(page)
+}
+
+// ListExpiringProducts handles GET /products/expiring?within=30d: products
+// whose warranty ends within that many days (default 30), soonest first.
+func (h *Handler) ListExpiringProducts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if products == nil {
-		products = []*Product{}
+	within := warranty.DefaultExpiringWithin
+	if raw := r.URL.Query().Get("within"); raw != "" {
+		days, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if err != nil {
+			http.Error(w, "Invalid within, expected days like 30d", http.StatusBadRequest)
+			return
+		}
+		within = days
 	}
+
+	products, err := h.service.ListExpiringProducts(r.Context(), userID, within)
+	if err != nil {
+		writeServiceError(w, err, "Failed to list expiring products")
+		return
+	}
+
 	json.NewEncoder(w).Encode(products)
 }
+
+func parseListQuery(values url.Values) (ListQuery, error) {
+	query := ListQuery{
+		Brand:    values.Get("brand"),
+		Warranty: values.Get("warranty"),
+		TagMatch: values.Get("tag_match"),
+		Sort:     values.Get("sort"),
+	}
+
+	var err error
+	if query.CategoryID, err = parseParam(values, "category_id", strconv.Atoi); err != nil {
+		return query, err
+	}
+	if query.LocationID, err = parseParam(values, "location_id", strconv.Atoi); err != nil {
+		return query, err
+	}
+	if tagIDs, err := parseParam(values, "tag_ids", parseIDs); err != nil {
+		return query, err
+	} else if tagIDs != nil {
+		query.TagIDs = *tagIDs
+	}
+	for name, raw := range values {
+		key, ok := strings.CutPrefix(name, "attr.")
+		if !ok {
+			continue
+		}
+		if key == "" || raw[0] == "" {
+			return query, fmt.Errorf("Invalid %s", name)
+		}
+		if query.Attributes == nil {
+			query.Attributes = make(map[string]string)
+		}
+		query.Attributes[key] = raw[0]
+	}
+	parseFloat := func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	if query.MinPrice, err = parseParam(values, "min_price", parseFloat); err != nil {
+		return query, err
+	}
+	if query.MaxPrice, err = parseParam(values, "max_price", parseFloat); err != nil {
+		return query, err
+	}
+	parseDate := func(s string) (time.Time, error) { return time.Parse(time.DateOnly, s) }
+	if query.PurchasedFrom, err = parseParam(values, "purchased_from", parseDate); err != nil {
+		return query, err
+	}
+	if query.PurchasedTo, err = parseParam(values, "purchased_to", parseDate); err != nil {
+		return query, err
+	}
+	if limit, err := parseParam(values, "limit", strconv.Atoi); err != nil {
+		return query, err
+	} else if limit != nil {
+		query.Limit = *limit
+	}
+
+	switch values.Get("order") {
+	case "":
+		query.Desc = query.Sort == "" || query.Sort == SortCreatedAt
+	case "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, errors.New("Invalid order")
+	}
+	return query, nil
+}
+
+// parseIDs parses a comma-separated list of IDs like "3,7".
+func parseIDs(s string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseParam parses an optional query parameter, nil when it is absent.
+func parseParam[T any](values url.Values, name string, parse func(string) (T, error)) (*T, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s", name)
+	}
+	return &v, nil
+}
+
+func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	product, err := h.service.UpdateProduct(r.Context(), id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to update product")
+		return
+	}
+
+	json.NewEncoder(w).Encode(product)
+}
+
+func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteProduct(r.Context(), id, userID); err != nil {
+		writeServiceError(w, err, "Failed to delete product")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrProductNotFound):
+		http.Error(w, "Product not found", http.StatusNotFound)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, ErrNameRequired), errors.Is(err, ErrInvalidQuery), errors.Is(err, ErrInvalidAttribute),
+		errors.Is(err, locations.ErrLocationNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,77 @@
+package products
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"keepsy-backend/internal/services/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandlerRejectsOtherUsers(t *testing.T) {
+	newMux := func() (*http.ServeMux, *MockRepo) {
+		mockRepo := new(MockRepo)
+		mockRepo.On("GetByID", mock.Anything, 1).Return(&Product{ID: 1, UserID: 1, Name: "Fridge"}, nil)
+
+		handler := NewHandler(NewService(mockRepo, new(MockStorage), testWarranties, testLocations, testCategories))
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /products", handler.GetProduct)
+		mux.HandleFunc("PATCH /products/{id}", handler.UpdateProduct)
+		mux.HandleFunc("DELETE /products/{id}", handler.DeleteProduct)
+		return mux, mockRepo
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{"Get", http.MethodGet, "/products?id=1", ""},
+		{"Update", http.MethodPatch, "/products/1", `{"name": "Mine now"}`},
+		{"Delete", http.MethodDelete, "/products/1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, mockRepo := newMux()
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: 2}))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+			mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("Owner", func(t *testing.T) {
+		mux, _ := newMux()
+
+		req := httptest.NewRequest(http.MethodGet, "/products?id=1", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: 1}))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockRepo.On("GetByID", mock.Anything, 9).Return(nil, ErrProductNotFound)
+		handler := NewHandler(NewService(mockRepo, nil, testWarranties, testLocations, testCategories))
+
+		req := httptest.NewRequest(http.MethodGet, "/products?id=9", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: 1}))
+		rec := httptest.NewRecorder()
+		handler.GetProduct(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
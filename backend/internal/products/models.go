@@ -2,6 +2,8 @@ package products
 
 import (
 	"context"
+	"encoding/json"
+	"keepsy-backend/internal/warranty"
 	"time"
 )
 
@@ -12,13 +14,38 @@ type Product struct {
 	Name            string           `json:"name"`
 	Brand           string           `json:"brand,omitempty"`
 	Model           string           `json:"model,omitempty"`
-	Location        string           `json:"location,omitempty"`
+	LocationID      *int             `json:"location_id,omitempty"`
+	Location        string           `json:"location,omitempty"` // Name of LocationID
 	Price           *float64         `json:"price,omitempty"`
 	PurchaseDate    *time.Time       `json:"purchase_date,omitempty"`
 	WarrantyEndDate *time.Time       `json:"warranty_end_date,omitempty"`
 	PurchaseDetails *PurchaseDetails `json:"purchase_details,omitempty"`
 	CreatedAt       time.Time        `json:"created_at"`
 	UpdatedAt       time.Time        `json:"updated_at"`
+
+	// Attributes are the values of the category's custom attributes by key:
+	// numbers as float64, dates as "2006-01-02" and the rest as strings.
+	Attributes map[string]any `json:"attributes,omitempty"`
+
+	// PrimaryThumbnailURL is the small thumbnail of the primary photo.
+	PrimaryThumbnailURL *string `json:"primary_thumbnail_url,omitempty"`
+	Tags                []*Tag  `json:"tags,omitempty"`
+
+	// Warranty is worked out by the service from the product's warranty
+	// records, the dates above and DefaultWarrantyMonths, the default
+	// period of the product's category.
+	Warranty              *warranty.Warranty `json:"warranty,omitempty"`
+	Warranties            []*warranty.Record `json:"warranties,omitempty"`
+	DefaultWarrantyMonths *int               `json:"-"`
+}
+
+// OwnerID implements authz.Owned.
+func (p *Product) OwnerID() int { return p.UserID }
+
+// Tag is one of the user's own tags on a product.
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
 }
 
 type PurchaseDetails struct {
@@ -32,20 +59,138 @@ type PurchaseDetails struct {
 }
 
 type CreateProductRequest struct {
-	UserID          int              `json:"user_id"` // In real app, this comes from auth context
+	UserID          int              `json:"-"` // From Context/Auth
 	CategoryID      *int             `json:"category_id,omitempty"`
 	Name            string           `json:"name"`
 	Brand           string           `json:"brand,omitempty"`
 	Model           string           `json:"model,omitempty"`
-	Location        string           `json:"location,omitempty"`
+	LocationID      *int             `json:"location_id,omitempty"`
 	Price           *float64         `json:"price,omitempty"`
 	PurchaseDate    *time.Time       `json:"purchase_date,omitempty"`
 	WarrantyEndDate *time.Time       `json:"warranty_end_date,omitempty"`
 	PurchaseDetails *PurchaseDetails `json:"purchase_details,omitempty"`
+	Attributes      map[string]any   `json:"attributes,omitempty"` // Checked against the category's attributes
+}
+
+// UpdateProductRequest is a partial update: fields left out stay unchanged.
+// Nullable fields can be cleared with null; purchase_details set to an
+// object replaces the stored details, null removes them. attributes
+// replaces all attribute values; a new category clears them unless new
+// ones are given.
+type UpdateProductRequest struct {
+	UserID          int                       `json:"-"` // From Context/Auth
+	CategoryID      Nullable[int]             `json:"category_id"`
+	Name            *string                   `json:"name"`
+	Brand           *string                   `json:"brand"`
+	Model           *string                   `json:"model"`
+	LocationID      Nullable[int]             `json:"location_id"`
+	Price           Nullable[float64]         `json:"price"`
+	PurchaseDate    Nullable[time.Time]       `json:"purchase_date"`
+	WarrantyEndDate Nullable[time.Time]       `json:"warranty_end_date"`
+	PurchaseDetails Nullable[PurchaseDetails] `json:"purchase_details"`
+	Attributes      map[string]any            `json:"attributes"`
+}
+
+// Nullable tells a JSON field that was left out (Set is false) from one that
+// was null (Set is true, Value is nil).
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	n.Value = &v
+	return nil
+}
+
+// Sort keys for product listing. Like in MySQL, a missing value (e.g. no
+// price) sorts lowest: first when ascending, last when descending.
+const (
+	SortCreatedAt   = "created_at"
+	SortName        = "name"
+	SortPrice       = "price"
+	SortPurchased   = "purchase_date"
+	SortWarrantyEnd = "warranty_end_date"
+)
+
+// How the tags of a tag filter must match.
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+)
+
+// Warranty states for filtering.
+const (
+	WarrantyActive  = "active"
+	WarrantyExpired = "expired"
+	WarrantyNone    = "none"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	// MaxExpiringWithin is how many days ahead GET /products/expiring may look.
+	MaxExpiringWithin = 365
+)
+
+// ListQuery filters, sorts and pages a user's products. Zero values mean
+// "no filter"; a Limit of 0 returns everything.
+type ListQuery struct {
+	UserID        int
+	CategoryID    *int
+	LocationID    *int // Including the locations inside it
+	Brand         string
+	MinPrice      *float64
+	MaxPrice      *float64
+	PurchasedFrom *time.Time
+	PurchasedTo   *time.Time
+	Warranty      string
+	TagIDs        []int
+	TagMatch      string            // TagMatchAny (also when empty) or TagMatchAll
+	Attributes    map[string]string // Attribute values by key, all of which must match
+	Sort          string
+	Desc          bool
+	After         *Cursor
+	Limit         int
+}
+
+// Cursor is the position after the last product of a page: its sort value
+// and ID. Value is nil when that product has no value for the sort key.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value any    `json:"v"`
+	ID    int    `json:"id"`
+}
+
+type ProductPage struct {
+	Products   []*Product `json:"products"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
 
 type Repository interface {
 	Create(ctx context.Context, product *Product) error
 	GetByID(ctx context.Context, id int) (*Product, error)
-	ListByUserID(ctx context.Context, userID int) ([]*Product, error)
+	// List returns the products matching query in its sort order, starting
+	// after query.After.
+	List(ctx context.Context, query ListQuery) ([]*Product, error)
+	// ListExpiring returns the user's products whose warranty (stored or
+	// inferred) ends between from and to, soonest first.
+	ListExpiring(ctx context.Context, userID int, from, to time.Time) ([]*Product, error)
+	// Update saves the product and its purchase details in one transaction;
+	// nil PurchaseDetails deletes them.
+	Update(ctx context.Context, product *Product) error
+	// Delete removes the product with its purchase details, bills, photos
+	// and reminders, and returns the URLs of the bill and photo files it had.
+	Delete(ctx context.Context, id int) ([]string, error)
 }
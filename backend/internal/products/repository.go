@@ -3,10 +3,18 @@ package products
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"keepsy-backend/internal/categories"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var ErrProductNotFound = errors.New("product not found")
+
 type MySQLRepository struct {
 	db *sql.DB
 }
@@ -15,6 +23,43 @@ func NewMySQLRepository(db *sql.DB) *MySQLRepository {
 	return &MySQLRepository{db: db}
 }
 
+// productColumns are selected FROM productTables, so products come with
+// their category's default warranty period and their primary photo's small
+// thumbnail.
+const (
+	productColumns = `p.id, p.user_id, p.category_id, p.name, p.brand, p.model, p.location_id, p.location, p.price,
+		p.purchase_date, p.warranty_end_date, p.created_at, p.updated_at, c.default_warranty_months,
+		(SELECT ph.thumbnail_small_url FROM keepsy_product_photos ph WHERE ph.product_id = p.id AND ph.is_primary LIMIT 1)`
+	productTables = `keepsy_products p LEFT JOIN keepsy_categories c ON c.id = p.category_id`
+
+	// warrantyEnd is the effective warranty end date, like
+	// warranty.Service.Evaluate works it out: the latest end of the
+	// product's warranty records that have started, or else the stored end
+	// date, or else the purchase date plus the category default.
+	warrantyEnd = `COALESCE(
+		(SELECT MAX(w.end_date) FROM keepsy_warranties w
+			WHERE w.product_id = p.id AND (w.start_date IS NULL OR w.start_date <= CURDATE())),
+		p.warranty_end_date,
+		DATE_ADD(p.purchase_date, INTERVAL c.default_warranty_months MONTH))`
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProduct(row scanner) (*Product, error) {
+	var p Product
+	err := row.Scan(
+		&p.ID, &p.UserID, &p.CategoryID, &p.Name, &p.Brand, &p.Model, &p.LocationID, &p.Location, &p.Price,
+		&p.PurchaseDate, &p.WarrantyEndDate, &p.CreatedAt, &p.UpdatedAt, &p.DefaultWarrantyMonths,
+		&p.PrimaryThumbnailURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 func (r *MySQLRepository) Create(ctx context.Context, product *Product) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -23,15 +68,15 @@ func (r *MySQLRepository) Create(ctx context.Context, product *Product) error {
 	defer tx.Rollback()
 
 	query := `
-		INSERT INTO keepsy_products (user_id, category_id, name, brand, model, location, price, purchase_date, warranty_end_date, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO keepsy_products (user_id, category_id, name, brand, model, location_id, location, price, purchase_date, warranty_end_date, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	product.CreatedAt = time.Now()
 	product.UpdatedAt = time.Now()
 
 	result, err := tx.ExecContext(ctx, query,
 		product.UserID, product.CategoryID, product.Name, product.Brand, product.Model,
-		product.Location, product.Price, product.PurchaseDate, product.WarrantyEndDate,
+		product.LocationID, product.Location, product.Price, product.PurchaseDate, product.WarrantyEndDate,
 		product.CreatedAt, product.UpdatedAt,
 	)
 
@@ -62,6 +107,13 @@ func (r *MySQLRepository) Create(ctx context.Context, product *Product) error {
 		details.ProductID = product.ID
 	}
 
+	if err := saveAttributes(ctx, tx, product); err != nil {
+		return err
+	}
+	if err := loadCategoryWarranty(ctx, tx, product); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -69,19 +121,54 @@ func (r *MySQLRepository) Create(ctx context.Context, product *Product) error {
 	return nil
 }
 
-func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Product, error) {
+// saveAttributes inserts the product's attribute values, looking up the
+// attributes by key in the product's category.
+func saveAttributes(ctx context.Context, tx *sql.Tx, product *Product) error {
+	if product.CategoryID == nil {
+		return nil
+	}
 	query := `
-		SELECT id, user_id, category_id, name, brand, model, location, price, purchase_date, warranty_end_date, created_at, updated_at
-		FROM keepsy_products WHERE id = ?
+		INSERT INTO keepsy_product_attributes (product_id, attribute_id, value)
+		SELECT ?, id, ? FROM keepsy_category_attributes WHERE category_id = ? AND attribute_key = ?
 	`
-	var p Product
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&p.ID, &p.UserID, &p.CategoryID, &p.Name, &p.Brand, &p.Model,
-		&p.Location, &p.Price, &p.PurchaseDate, &p.WarrantyEndDate, &p.CreatedAt, &p.UpdatedAt,
-	)
+	for key, value := range product.Attributes {
+		if _, err := tx.ExecContext(ctx, query, product.ID, attributeText(value), *product.CategoryID, key); err != nil {
+			return fmt.Errorf("failed to save attribute %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// attributeText is the stored form of an attribute value: numbers in their
+// shortest form, anything else as it is.
+func attributeText(value any) string {
+	if n, ok := value.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// loadCategoryWarranty sets the default warranty period of the product's
+// (possibly changed) category.
+func loadCategoryWarranty(ctx context.Context, tx *sql.Tx, product *Product) error {
+	product.DefaultWarrantyMonths = nil
+	if product.CategoryID == nil {
+		return nil
+	}
+	err := tx.QueryRowContext(ctx, `SELECT default_warranty_months FROM keepsy_categories WHERE id = ?`, *product.CategoryID).
+		Scan(&product.DefaultWarrantyMonths)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get category warranty: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Product, error) {
+	query := `SELECT ` + productColumns + ` FROM ` + productTables + ` WHERE p.id = ?`
+	p, err := scanProduct(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product not found")
+			return nil, ErrProductNotFound
 		}
 		return nil, fmt.Errorf("failed to get product: %w", err)
 	}
@@ -102,30 +189,390 @@ func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Product, error)
 		return nil, fmt.Errorf("failed to get purchase details: %w", err)
 	}
 
-	return &p, nil
+	if err := r.loadRelated(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-func (r *MySQLRepository) ListByUserID(ctx context.Context, userID int) ([]*Product, error) {
-	query := `
-		SELECT id, user_id, category_id, name, brand, model, location, price, purchase_date, warranty_end_date, created_at, updated_at
-		FROM keepsy_products WHERE user_id = ? ORDER BY created_at DESC
-	`
-	rows, err := r.db.QueryContext(ctx, query, userID)
+// sortColumns maps sort keys to columns. Each has an index on
+// (user_id, <column>, id) so pages are read in index order, except the
+// warranty end, which sorts by the effective end date like the listing
+// shows it.
+var sortColumns = map[string]string{
+	SortCreatedAt:   "p.created_at",
+	SortName:        "p.name",
+	SortPrice:       "p.price",
+	SortPurchased:   "p.purchase_date",
+	SortWarrantyEnd: warrantyEnd,
+}
+
+func (r *MySQLRepository) List(ctx context.Context, q ListQuery) ([]*Product, error) {
+	column, ok := sortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key %q", q.Sort)
+	}
+
+	where := []string{"p.user_id = ?"}
+	args := []any{q.UserID}
+	filter := func(cond string, arg any) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if q.CategoryID != nil {
+		filter("p.category_id = ?", *q.CategoryID)
+	}
+	if q.LocationID != nil {
+		filter(`p.location_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM keepsy_locations WHERE id = ?
+				UNION ALL
+				SELECT l.id FROM keepsy_locations l JOIN tree ON l.parent_id = tree.id
+			)
+			SELECT id FROM tree)`, *q.LocationID)
+	}
+	if q.Brand != "" {
+		filter("p.brand = ?", q.Brand)
+	}
+	if q.MinPrice != nil {
+		filter("p.price >= ?", *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		filter("p.price <= ?", *q.MaxPrice)
+	}
+	if q.PurchasedFrom != nil {
+		filter("p.purchase_date >= ?", *q.PurchasedFrom)
+	}
+	if q.PurchasedTo != nil {
+		filter("p.purchase_date <= ?", *q.PurchasedTo)
+	}
+	if len(q.TagIDs) > 0 {
+		// With "all", every tag must be on the product
+		in := "(?" + strings.Repeat(", ?", len(q.TagIDs)-1) + ")"
+		tagged := `SELECT COUNT(DISTINCT pt.tag_id) FROM keepsy_product_tags pt WHERE pt.product_id = p.id AND pt.tag_id IN ` + in
+		need := 1
+		if q.TagMatch == TagMatchAll {
+			need = len(q.TagIDs)
+		}
+		where = append(where, "("+tagged+") >= ?")
+		for _, id := range q.TagIDs {
+			args = append(args, id)
+		}
+		args = append(args, need)
+	}
+	for _, key := range slices.Sorted(maps.Keys(q.Attributes)) {
+		// Numbers are compared in their stored form, so 16.0 finds 16
+		value := q.Attributes[key]
+		number := value
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			number = attributeText(n)
+		}
+		where = append(where, `EXISTS (
+			SELECT 1 FROM keepsy_product_attributes pa
+			JOIN keepsy_category_attributes ca ON ca.id = pa.attribute_id
+			WHERE pa.product_id = p.id AND ca.attribute_key = ? AND pa.value = IF(ca.type = 'number', ?, ?))`)
+		args = append(args, key, number, value)
+	}
+	switch q.Warranty {
+	case WarrantyActive:
+		where = append(where, warrantyEnd+" >= CURDATE()")
+	case WarrantyExpired:
+		where = append(where, warrantyEnd+" < CURDATE()")
+	case WarrantyNone:
+		where = append(where, warrantyEnd+" IS NULL")
+	}
+
+	dir := "ASC"
+	if q.Desc {
+		dir = "DESC"
+	}
+	if q.After != nil {
+		cond, condArgs := afterCursor(column, q.Desc, q.After)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s ORDER BY %s %s, p.id %s`,
+		productColumns, productTables, strings.Join(where, " AND "), column, dir, dir)
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list products: %w", err)
 	}
 	defer rows.Close()
 
+	products, err := scanProducts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return products, r.loadRelated(ctx, products...)
+}
+
+func (r *MySQLRepository) ListExpiring(ctx context.Context, userID int, from, to time.Time) ([]*Product, error) {
+	query := `SELECT ` + productColumns + ` FROM ` + productTables + `
+		WHERE p.user_id = ? AND ` + warrantyEnd + ` BETWEEN ? AND ?
+		ORDER BY ` + warrantyEnd + ` ASC, p.id ASC`
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring products: %w", err)
+	}
+	defer rows.Close()
+
+	products, err := scanProducts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return products, r.loadRelated(ctx, products...)
+}
+
+// loadRelated loads the tags and attributes of the products.
+func (r *MySQLRepository) loadRelated(ctx context.Context, products ...*Product) error {
+	if err := r.loadTags(ctx, products...); err != nil {
+		return err
+	}
+	return r.loadAttributes(ctx, products...)
+}
+
+// loadTags sets the tags of the products, by name.
+func (r *MySQLRepository) loadTags(ctx context.Context, products ...*Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Product, len(products))
+	args := make([]any, len(products))
+	for i, p := range products {
+		byID[p.ID] = p
+		args[i] = p.ID
+	}
+	query := `
+		SELECT pt.product_id, t.id, t.name
+		FROM keepsy_product_tags pt
+		JOIN keepsy_tags t ON t.id = pt.tag_id
+		WHERE pt.product_id IN (?` + strings.Repeat(", ?", len(products)-1) + `)
+		ORDER BY t.name`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID int
+		var tag Tag
+		if err := rows.Scan(&productID, &tag.ID, &tag.Name); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if p, ok := byID[productID]; ok {
+			p.Tags = append(p.Tags, &tag)
+		}
+	}
+	return rows.Err()
+}
+
+// loadAttributes sets the attribute values of the products, converting
+// numbers back from their stored text.
+func (r *MySQLRepository) loadAttributes(ctx context.Context, products ...*Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Product, len(products))
+	args := make([]any, len(products))
+	for i, p := range products {
+		byID[p.ID] = p
+		args[i] = p.ID
+	}
+	query := `
+		SELECT pa.product_id, ca.attribute_key, ca.type, pa.value
+		FROM keepsy_product_attributes pa
+		JOIN keepsy_category_attributes ca ON ca.id = pa.attribute_id
+		WHERE pa.product_id IN (?` + strings.Repeat(", ?", len(products)-1) + `)`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load attributes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID int
+		var key, typ, text string
+		if err := rows.Scan(&productID, &key, &typ, &text); err != nil {
+			return fmt.Errorf("failed to scan attribute: %w", err)
+		}
+		p, ok := byID[productID]
+		if !ok {
+			continue
+		}
+		var value any = text
+		if typ == categories.AttributeNumber {
+			if value, err = strconv.ParseFloat(text, 64); err != nil {
+				return fmt.Errorf("failed to parse attribute %s: %w", key, err)
+			}
+		}
+		if p.Attributes == nil {
+			p.Attributes = make(map[string]any)
+		}
+		p.Attributes[key] = value
+	}
+	return rows.Err()
+}
+
+func scanProducts(rows *sql.Rows) ([]*Product, error) {
 	var products []*Product
 	for rows.Next() {
-		var p Product
-		if err := rows.Scan(
-			&p.ID, &p.UserID, &p.CategoryID, &p.Name, &p.Brand, &p.Model,
-			&p.Location, &p.Price, &p.PurchaseDate, &p.WarrantyEndDate, &p.CreatedAt, &p.UpdatedAt,
-		); err != nil {
+		p, err := scanProduct(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan product: %w", err)
 		}
-		products = append(products, &p)
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// afterCursor is the keyset condition for rows that come after the cursor in
+// "ORDER BY column, id". NULL sorts lowest, so it is the start of an
+// ascending listing and the end of a descending one.
+func afterCursor(column string, desc bool, c *Cursor) (string, []any) {
+	switch {
+	case c.Value == nil && !desc:
+		return fmt.Sprintf("((%[1]s IS NULL AND p.id > ?) OR %[1]s IS NOT NULL)", column), []any{c.ID}
+	case c.Value == nil:
+		return fmt.Sprintf("(%s IS NULL AND p.id < ?)", column), []any{c.ID}
+	case !desc:
+		return fmt.Sprintf("(%[1]s > ? OR (%[1]s = ? AND p.id > ?))", column), []any{c.Value, c.Value, c.ID}
+	default:
+		return fmt.Sprintf("(%[1]s < ? OR (%[1]s = ? AND p.id < ?) OR %[1]s IS NULL)", column), []any{c.Value, c.Value, c.ID}
+	}
+}
+
+func (r *MySQLRepository) Update(ctx context.Context, product *Product) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE keepsy_products
+		SET category_id = ?, name = ?, brand = ?, model = ?, location_id = ?, location = ?, price = ?, purchase_date = ?, warranty_end_date = ?, updated_at = ?
+		WHERE id = ?
+	`
+	product.UpdatedAt = time.Now()
+
+	_, err = tx.ExecContext(ctx, query,
+		product.CategoryID, product.Name, product.Brand, product.Model, product.LocationID, product.Location,
+		product.Price, product.PurchaseDate, product.WarrantyEndDate, product.UpdatedAt, product.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	if details := product.PurchaseDetails; details != nil {
+		detailsQuery := `
+			INSERT INTO keepsy_product_purchase_details (product_id, shop_name, shop_address, contact_person, contact_number, order_id, delivery_status)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				shop_name = VALUES(shop_name), shop_address = VALUES(shop_address), contact_person = VALUES(contact_person),
+				contact_number = VALUES(contact_number), order_id = VALUES(order_id), delivery_status = VALUES(delivery_status)
+		`
+		_, err = tx.ExecContext(ctx, detailsQuery,
+			product.ID, details.ShopName, details.ShopAddress, details.ContactPerson,
+			details.ContactNumber, details.OrderID, details.DeliveryStatus,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save purchase details: %w", err)
+		}
+		details.ProductID = product.ID
+	} else {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_product_purchase_details WHERE product_id = ?`, product.ID); err != nil {
+			return fmt.Errorf("failed to delete purchase details: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_product_attributes WHERE product_id = ?`, product.ID); err != nil {
+		return fmt.Errorf("failed to delete attributes: %w", err)
+	}
+	if err := saveAttributes(ctx, tx, product); err != nil {
+		return err
+	}
+	if err := loadCategoryWarranty(ctx, tx, product); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Delete(ctx context.Context, id int) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the bills and photos, so none is added between reading and
+	// deleting them
+	fileURLs, err := queryFileURLs(ctx, tx, `SELECT file_url FROM keepsy_bills WHERE product_id = ? FOR UPDATE`, id)
+	if err != nil {
+		return nil, err
+	}
+	photoURLs, err := queryFileURLs(ctx, tx, `
+		SELECT file_url, thumbnail_small_url, thumbnail_medium_url, thumbnail_large_url
+		FROM keepsy_product_photos WHERE product_id = ? FOR UPDATE`, id)
+	if err != nil {
+		return nil, err
+	}
+	fileURLs = append(fileURLs, photoURLs...)
+
+	// Purchase details, bills, photos and reminders cascade
+	result, err := tx.ExecContext(ctx, `DELETE FROM keepsy_products WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete product: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to delete product: %w", err)
+	} else if n == 0 {
+		return nil, ErrProductNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return fileURLs, nil
+}
+
+// queryFileURLs returns the values of all columns of the rows, each a file
+// URL.
+func queryFileURLs(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	var urls []string
+	for rows.Next() {
+		row := make([]string, len(columns))
+		dest := make([]any, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		urls = append(urls, row...)
 	}
-	return products, nil
+	return urls, rows.Err()
 }
@@ -2,22 +2,62 @@ package products
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"keepsy-backend/internal/categories"
+	"keepsy-backend/internal/locations"
+	"keepsy-backend/internal/services/authz"
+	"keepsy-backend/internal/services/storage"
+	"keepsy-backend/internal/warranty"
+	"log"
+	"maps"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+var (
+	ErrNameRequired     = errors.New("product name is required")
+	ErrInvalidQuery     = errors.New("invalid product query")
+	ErrInvalidAttribute = errors.New("invalid product attribute")
+)
+
+// maxAttributeLength is the longest string attribute value, in characters.
+const maxAttributeLength = 255
+
 type Service interface {
 	CreateProduct(ctx context.Context, req CreateProductRequest) (*Product, error)
-	GetProduct(ctx context.Context, id int) (*Product, error)
-	ListProducts(ctx context.Context, userID int) ([]*Product, error)
+	GetProduct(ctx context.Context, id, userID int) (*Product, error)
+	// ListProducts returns one page of products. cursor is the NextCursor of
+	// the previous page, empty for the first one.
+	ListProducts(ctx context.Context, query ListQuery, cursor string) (*ProductPage, error)
+	// ListExpiringProducts returns the products whose warranty ends within
+	// the next `within` days, soonest first.
+	ListExpiringProducts(ctx context.Context, userID, within int) ([]*Product, error)
+	UpdateProduct(ctx context.Context, id int, req UpdateProductRequest) (*Product, error)
+	// DeleteProduct deletes the product with everything attached, including bill files.
+	DeleteProduct(ctx context.Context, id, userID int) error
 }
 
 type service struct {
-	repo Repository
+	repo       Repository
+	storage    storage.Service
+	warranties warranty.Service
+	locations  locations.Repository
+	categories categories.Repository
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, storage storage.Service, warranties warranty.Service, locations locations.Repository, categories categories.Repository) Service {
+	return &service{
+		repo:       repo,
+		storage:    storage,
+		warranties: warranties,
+		locations:  locations,
+		categories: categories,
+	}
 }
 
 func (s *service) CreateProduct(ctx context.Context, req CreateProductRequest) (*Product, error) {
@@ -25,7 +65,7 @@ func (s *service) CreateProduct(ctx context.Context, req CreateProductRequest) (
 		return nil, errors.New("user ID is required")
 	}
 	if req.Name == "" {
-		return nil, errors.New("product name is required")
+		return nil, ErrNameRequired
 	}
 
 	product := &Product{
@@ -34,7 +74,6 @@ func (s *service) CreateProduct(ctx context.Context, req CreateProductRequest) (
 		Name:            req.Name,
 		Brand:           req.Brand,
 		Model:           req.Model,
-		Location:        req.Location,
 		Price:           req.Price,
 		PurchaseDate:    req.PurchaseDate,
 		WarrantyEndDate: req.WarrantyEndDate,
@@ -42,24 +81,452 @@ func (s *service) CreateProduct(ctx context.Context, req CreateProductRequest) (
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
+	if err := s.setLocation(ctx, product, req.LocationID); err != nil {
+		return nil, err
+	}
+	if err := s.setAttributes(ctx, product, req.Attributes); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.Create(ctx, product); err != nil {
 		return nil, err
 	}
 
+	if err := s.evaluateWarranties(ctx, warranty.DefaultExpiringWithin, product); err != nil {
+		return nil, err
+	}
 	return product, nil
 }
 
-func (s *service) GetProduct(ctx context.Context, id int) (*Product, error) {
-	if id <= 0 {
-		return nil, errors.New("invalid product ID")
+func (s *service) GetProduct(ctx context.Context, id, userID int) (*Product, error) {
+	product, err := s.ownedProduct(ctx, id, userID)
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.GetByID(ctx, id)
+	if err := s.evaluateWarranties(ctx, warranty.DefaultExpiringWithin, product); err != nil {
+		return nil, err
+	}
+	return product, nil
 }
 
-func (s *service) ListProducts(ctx context.Context, userID int) ([]*Product, error) {
+func (s *service) ListProducts(ctx context.Context, query ListQuery, cursor string) (*ProductPage, error) {
+	if query.UserID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	if query.Sort == "" {
+		query.Sort = SortCreatedAt
+	}
+	switch query.Sort {
+	case SortCreatedAt, SortName, SortPrice, SortPurchased, SortWarrantyEnd:
+	default:
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, query.Sort)
+	}
+	switch query.Warranty {
+	case "", WarrantyActive, WarrantyExpired, WarrantyNone:
+	default:
+		return nil, fmt.Errorf("%w: unknown warranty state %q", ErrInvalidQuery, query.Warranty)
+	}
+	query.TagIDs = uniqueIDs(query.TagIDs)
+	switch query.TagMatch {
+	case "", TagMatchAny, TagMatchAll:
+	default:
+		return nil, fmt.Errorf("%w: tag_match must be any or all", ErrInvalidQuery)
+	}
+	for key, value := range query.Attributes {
+		if key == "" || value == "" {
+			return nil, fmt.Errorf("%w: attribute filters need a key and a value", ErrInvalidQuery)
+		}
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return nil, fmt.Errorf("%w: min_price is above max_price", ErrInvalidQuery)
+	}
+	if query.PurchasedFrom != nil && query.PurchasedTo != nil && query.PurchasedFrom.After(*query.PurchasedTo) {
+		return nil, fmt.Errorf("%w: purchased_from is after purchased_to", ErrInvalidQuery)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.Sort != query.Sort || after.Desc != query.Desc {
+			return nil, fmt.Errorf("%w: cursor belongs to a different sort", ErrInvalidQuery)
+		}
+		query.After = after
+	}
+
+	// Ask for one more to know whether there is a next page.
+	query.Limit = limit + 1
+	products, err := s.repo.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ProductPage{Products: products}
+	if len(products) > limit {
+		page.Products = products[:limit]
+	}
+	if page.Products == nil {
+		page.Products = []*Product{}
+	}
+	// The warranty end cursor is the evaluated end date, so evaluate first
+	if err := s.evaluateWarranties(ctx, warranty.DefaultExpiringWithin, page.Products...); err != nil {
+		return nil, err
+	}
+	if len(products) > limit {
+		last := page.Products[limit-1]
+		page.NextCursor = encodeCursor(&Cursor{
+			Sort:  query.Sort,
+			Desc:  query.Desc,
+			Value: sortValue(last, query.Sort),
+			ID:    last.ID,
+		})
+	}
+	return page, nil
+}
+
+func (s *service) ListExpiringProducts(ctx context.Context, userID, within int) ([]*Product, error) {
 	if userID <= 0 {
 		return nil, errors.New("invalid user ID")
 	}
-	return s.repo.ListByUserID(ctx, userID)
+	if within < 0 || within > MaxExpiringWithin {
+		return nil, fmt.Errorf("%w: within must be between 0 and %d days", ErrInvalidQuery, MaxExpiringWithin)
+	}
+
+	today := s.warranties.Today()
+	products, err := s.repo.ListExpiring(ctx, userID, today, today.AddDate(0, 0, within))
+	if err != nil {
+		return nil, err
+	}
+	if products == nil {
+		products = []*Product{}
+	}
+	// Everything listed ends within the window, so it all shows as expiring
+	if err := s.evaluateWarranties(ctx, within, products...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// evaluateWarranties loads the warranty records of the products and sets
+// their effective warranty, expiring meaning it ends within the given
+// number of days.
+func (s *service) evaluateWarranties(ctx context.Context, within int, products ...*Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	records, err := s.warranties.RecordsByProduct(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		p.Warranties = records[p.ID]
+		p.Warranty = s.warranties.Evaluate(warranty.Dates{
+			Records:       p.Warranties,
+			EndDate:       p.WarrantyEndDate,
+			PurchaseDate:  p.PurchaseDate,
+			DefaultMonths: p.DefaultWarrantyMonths,
+		}, within)
+	}
+	return nil
+}
+
+// sortValue returns the product's value for a sort key, nil if it has none.
+func sortValue(p *Product, sort string) any {
+	switch sort {
+	case SortName:
+		return p.Name
+	case SortPrice:
+		if p.Price != nil {
+			return *p.Price
+		}
+	case SortPurchased:
+		if p.PurchaseDate != nil {
+			return *p.PurchaseDate
+		}
+	case SortWarrantyEnd:
+		if p.Warranty != nil && p.Warranty.EndDate != nil {
+			return *p.Warranty.EndDate
+		}
+	default:
+		return p.CreatedAt
+	}
+	return nil
+}
+
+func encodeCursor(c *Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor and turns its value back into the type of
+// its sort key (JSON leaves times as strings).
+func decodeCursor(cursor string) (*Cursor, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, invalid
+	}
+
+	switch v := c.Value.(type) {
+	case nil:
+		if c.Sort == SortName || c.Sort == SortCreatedAt {
+			return nil, invalid
+		}
+	case string:
+		if c.Sort == SortName {
+			break
+		}
+		if c.Sort == SortPrice {
+			return nil, invalid
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, invalid
+		}
+		c.Value = t
+	case float64:
+		if c.Sort != SortPrice {
+			return nil, invalid
+		}
+	default:
+		return nil, invalid
+	}
+	return &c, nil
+}
+
+func (s *service) UpdateProduct(ctx context.Context, id int, req UpdateProductRequest) (*Product, error) {
+	product, err := s.ownedProduct(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, ErrNameRequired
+		}
+		product.Name = name
+	}
+	if req.Brand != nil {
+		product.Brand = *req.Brand
+	}
+	if req.Model != nil {
+		product.Model = *req.Model
+	}
+	if req.LocationID.Set {
+		if err := s.setLocation(ctx, product, req.LocationID.Value); err != nil {
+			return nil, err
+		}
+	}
+	if req.CategoryID.Set || req.Attributes != nil {
+		values := req.Attributes
+		if values == nil && sameID(product.CategoryID, req.CategoryID.Value) {
+			values = product.Attributes
+		}
+		if req.CategoryID.Set {
+			product.CategoryID = req.CategoryID.Value
+		}
+		if err := s.setAttributes(ctx, product, values); err != nil {
+			return nil, err
+		}
+	}
+	if req.Price.Set {
+		product.Price = req.Price.Value
+	}
+	if req.PurchaseDate.Set {
+		product.PurchaseDate = req.PurchaseDate.Value
+	}
+	if req.WarrantyEndDate.Set {
+		product.WarrantyEndDate = req.WarrantyEndDate.Value
+	}
+	if req.PurchaseDetails.Set {
+		product.PurchaseDetails = req.PurchaseDetails.Value
+	}
+
+	if err := s.repo.Update(ctx, product); err != nil {
+		return nil, err
+	}
+	if err := s.evaluateWarranties(ctx, warranty.DefaultExpiringWithin, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (s *service) DeleteProduct(ctx context.Context, id, userID int) error {
+	if _, err := s.ownedProduct(ctx, id, userID); err != nil {
+		return err
+	}
+
+	fileURLs, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// The rows are gone, so a file that fails to delete is only a stray file.
+	// Don't fail the request for it.
+	for _, url := range fileURLs {
+		if err := s.storage.Delete(ctx, url); err != nil {
+			log.Printf("failed to delete file of product %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// setLocation puts the product in the user's location id, or in none if id
+// is nil. Another user's location is reported as missing.
+func (s *service) setLocation(ctx context.Context, product *Product, id *int) error {
+	if id == nil {
+		product.LocationID, product.Location = nil, ""
+		return nil
+	}
+
+	location, err := s.locations.GetByID(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if authz.Authorize(product.UserID, location) != nil {
+		return locations.ErrLocationNotFound
+	}
+	product.LocationID, product.Location = &location.ID, location.Name
+	return nil
+}
+
+// setAttributes checks values against the attributes of the product's
+// category and sets them as the product's attributes. Empty values count as
+// not given.
+func (s *service) setAttributes(ctx context.Context, product *Product, values map[string]any) error {
+	var schema []*categories.Attribute
+	if product.CategoryID != nil {
+		var err error
+		if schema, err = s.categories.ListAttributes(ctx, *product.CategoryID); err != nil {
+			return err
+		}
+	}
+
+	attributes := make(map[string]any, len(schema))
+	known := make(map[string]bool, len(schema))
+	for _, attribute := range schema {
+		known[attribute.Key] = true
+		value, err := attributeValue(attribute, values[attribute.Key])
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			if attribute.Required {
+				return fmt.Errorf("%w: %s is required", ErrInvalidAttribute, attribute.Key)
+			}
+			continue
+		}
+		attributes[attribute.Key] = value
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if !known[key] {
+			return fmt.Errorf("%w: the category has no attribute %q", ErrInvalidAttribute, key)
+		}
+	}
+
+	product.Attributes = attributes
+	return nil
+}
+
+// attributeValue checks a JSON value against the attribute's type and
+// returns it in the form it is stored in: numbers as float64, dates as
+// "2006-01-02" and the rest as trimmed strings. It returns nil for a missing
+// or empty value.
+func attributeValue(attribute *categories.Attribute, raw any) (any, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	invalid := fmt.Errorf("%w: %s must be a %s", ErrInvalidAttribute, attribute.Key, attribute.Type)
+
+	if attribute.Type == categories.AttributeNumber {
+		n, ok := raw.(float64)
+		if !ok {
+			return nil, invalid
+		}
+		return n, nil
+	}
+
+	str, ok := raw.(string)
+	if !ok {
+		return nil, invalid
+	}
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return nil, nil
+	}
+
+	switch attribute.Type {
+	case categories.AttributeDate:
+		date, err := time.Parse(time.DateOnly, str)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a date (YYYY-MM-DD)", ErrInvalidAttribute, attribute.Key)
+		}
+		return date.Format(time.DateOnly), nil
+	case categories.AttributeEnum:
+		if !slices.Contains(attribute.Options, str) {
+			return nil, fmt.Errorf("%w: %s must be one of %s", ErrInvalidAttribute, attribute.Key, strings.Join(attribute.Options, ", "))
+		}
+	default:
+		if utf8.RuneCountInString(str) > maxAttributeLength {
+			return nil, fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidAttribute, attribute.Key, maxAttributeLength)
+		}
+	}
+	return str, nil
+}
+
+// sameID reports whether a and b are both nil or the same ID.
+func sameID(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ownedProduct loads a product and authorizes userID to access it.
+func (s *service) ownedProduct(ctx context.Context, id, userID int) (*Product, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid product ID")
+	}
+	product, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(userID, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// uniqueIDs returns ids without duplicates, in their first order.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var out []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
 }
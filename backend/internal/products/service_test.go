@@ -2,12 +2,84 @@ package products
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"testing"
+	"time"
+
+	"keepsy-backend/internal/categories"
+	"keepsy-backend/internal/locations"
+	"keepsy-backend/internal/services/authz"
+	"keepsy-backend/internal/warranty"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// testWarranties evaluates warranties on 2026-10-17, without any
+// warranty records.
+var testWarranties = newTestWarranties(nil)
+
+func newTestWarranties(records map[int][]*warranty.Record) warranty.Service {
+	return warranty.NewService(warrantyRecords{byProduct: records}, func() time.Time {
+		return time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	})
+}
+
+// warrantyRecords is a warranty repository that can only list records.
+type warrantyRecords struct {
+	warranty.Repository
+	byProduct map[int][]*warranty.Record
+}
+
+func (w warrantyRecords) ListByProductIDs(ctx context.Context, productIDs []int) ([]*warranty.Record, error) {
+	var records []*warranty.Record
+	for _, id := range productIDs {
+		records = append(records, w.byProduct[id]...)
+	}
+	return records, nil
+}
+
+// testLocations has user 1's Kitchen (7) and user 2's Garage (8).
+var testLocations = locationRepo{byID: map[int]*locations.Location{
+	7: {ID: 7, UserID: 1, Name: "Kitchen", Kind: locations.KindRoom},
+	8: {ID: 8, UserID: 2, Name: "Garage", Kind: locations.KindRoom},
+}}
+
+// locationRepo is a locations repository that can only get locations.
+type locationRepo struct {
+	locations.Repository
+	byID map[int]*locations.Location
+}
+
+func (r locationRepo) GetByID(ctx context.Context, id int) (*locations.Location, error) {
+	if l, ok := r.byID[id]; ok {
+		return l, nil
+	}
+	return nil, locations.ErrLocationNotFound
+}
+
+// testCategories has attributes for Phones (3) only.
+var testCategories = categoryRepo{attributes: map[int][]*categories.Attribute{
+	3: {
+		{ID: 1, CategoryID: 3, Key: "imei", Type: categories.AttributeString, Required: true},
+		{ID: 2, CategoryID: 3, Key: "storage", Type: categories.AttributeEnum, Options: []string{"128 GB", "256 GB"}},
+		{ID: 3, CategoryID: 3, Key: "ram_gb", Type: categories.AttributeNumber},
+		{ID: 4, CategoryID: 3, Key: "activated_on", Type: categories.AttributeDate},
+	},
+}}
+
+// categoryRepo is a categories repository that can only list attributes.
+type categoryRepo struct {
+	categories.Repository
+	attributes map[int][]*categories.Attribute
+}
+
+func (r categoryRepo) ListAttributes(ctx context.Context, categoryID int) ([]*categories.Attribute, error) {
+	return r.attributes[categoryID], nil
+}
+
 type MockRepo struct {
 	mock.Mock
 }
@@ -29,18 +101,80 @@ func (m *MockRepo) GetByID(ctx context.Context, id int) (*Product, error) {
 	return args.Get(0).(*Product), args.Error(1)
 }
 
-func (m *MockRepo) ListByUserID(ctx context.Context, userID int) ([]*Product, error) {
-	args := m.Called(ctx, userID)
+func (m *MockRepo) List(ctx context.Context, query ListQuery) ([]*Product, error) {
+	args := m.Called(ctx, query)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*Product), args.Error(1)
 }
 
+func (m *MockRepo) ListExpiring(ctx context.Context, userID int, from, to time.Time) ([]*Product, error) {
+	args := m.Called(ctx, userID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Product), args.Error(1)
+}
+
+func (m *MockRepo) Update(ctx context.Context, product *Product) error {
+	args := m.Called(ctx, product)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(ctx context.Context, id int) ([]string, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockStorage
+type MockStorage struct {
+	mock.Mock
+}
+
+func (m *MockStorage) Upload(ctx context.Context, file io.Reader, filename string) (string, error) {
+	args := m.Called(ctx, file, filename)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) Delete(ctx context.Context, url string) error {
+	args := m.Called(ctx, url)
+	return args.Error(0)
+}
+
+func (m *MockStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorage) Move(ctx context.Context, url, filename string) (string, error) {
+	args := m.Called(ctx, url, filename)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) Open(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadSeekCloser), args.Error(1)
+}
+
+func (m *MockStorage) GetDownloadURL(ctx context.Context, url string) (string, error) {
+	args := m.Called(ctx, url)
+	return args.String(0), args.Error(1)
+}
+
 func TestCreateProduct(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		mockRepo := new(MockRepo)
-		service := NewService(mockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
 
 		req := CreateProductRequest{
 			UserID: 1,
@@ -60,44 +194,483 @@ func TestCreateProduct(t *testing.T) {
 	})
 
 	t.Run("MissingUserID", func(t *testing.T) {
-		service := NewService(nil)
+		service := NewService(nil, nil, testWarranties, testLocations, testCategories)
 		_, err := service.CreateProduct(context.Background(), CreateProductRequest{Name: "P"})
 		assert.Error(t, err)
 		assert.Equal(t, "user ID is required", err.Error())
 	})
+
+	t.Run("Location", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(p *Product) bool {
+			return *p.LocationID == 7 && p.Location == "Kitchen"
+		})).Return(nil)
+
+		kitchen := 7
+		_, err := service.CreateProduct(context.Background(), CreateProductRequest{UserID: 1, Name: "Kettle", LocationID: &kitchen})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Other Users Location", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		garage := 8
+		_, err := service.CreateProduct(context.Background(), CreateProductRequest{UserID: 1, Name: "Kettle", LocationID: &garage})
+
+		assert.ErrorIs(t, err, locations.ErrLocationNotFound)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestProductAttributes(t *testing.T) {
+	ctx := context.Background()
+	phones, kettles := 3, 4
+
+	t.Run("Valid", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		var req CreateProductRequest
+		assert.NoError(t, json.Unmarshal([]byte(`{"name": "Phone", "category_id": 3, "attributes": {
+			"imei": " 356938035643809 ", "storage": "128 GB", "ram_gb": 8, "activated_on": "2026-01-05"
+		}}`), &req))
+		req.UserID = 1
+
+		mockRepo.On("Create", ctx, mock.MatchedBy(func(p *Product) bool {
+			return assert.ObjectsAreEqual(map[string]any{
+				"imei": "356938035643809", "storage": "128 GB", "ram_gb": 8.0, "activated_on": "2026-01-05",
+			}, p.Attributes)
+		})).Return(nil)
+
+		_, err := service.CreateProduct(ctx, req)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		requests := []CreateProductRequest{
+			{CategoryID: &phones, Attributes: map[string]any{"storage": "128 GB"}},                // No IMEI
+			{CategoryID: &phones, Attributes: map[string]any{"imei": " "}},                        // Empty IMEI
+			{CategoryID: &phones, Attributes: map[string]any{"imei": "1", "storage": "64 GB"}},    // Not an option
+			{CategoryID: &phones, Attributes: map[string]any{"imei": "1", "ram_gb": "8"}},         // Not a number
+			{CategoryID: &phones, Attributes: map[string]any{"imei": "1", "activated_on": "5/1"}}, // Not a date
+			{CategoryID: &phones, Attributes: map[string]any{"imei": "1", "color": "Black"}},      // Unknown
+			{CategoryID: &kettles, Attributes: map[string]any{"imei": "1"}},                       // Not a phone
+			{Attributes: map[string]any{"imei": "1"}},                                             // No category
+		}
+		for _, req := range requests {
+			req.UserID, req.Name = 1, "Phone"
+			_, err := service.CreateProduct(ctx, req)
+			assert.ErrorIs(t, err, ErrInvalidAttribute, req.Attributes)
+		}
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("New Category Clears Them", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("GetByID", ctx, 5).Return(&Product{
+			ID: 5, UserID: 1, Name: "Phone", CategoryID: &phones, Attributes: map[string]any{"imei": "1"},
+		}, nil)
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(p *Product) bool {
+			return *p.CategoryID == kettles && len(p.Attributes) == 0
+		})).Return(nil)
+
+		_, err := service.UpdateProduct(ctx, 5, UpdateProductRequest{UserID: 1, CategoryID: Nullable[int]{Set: true, Value: &kettles}})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Required On New Category", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("GetByID", ctx, 5).Return(&Product{ID: 5, UserID: 1, Name: "Phone"}, nil)
+
+		_, err := service.UpdateProduct(ctx, 5, UpdateProductRequest{UserID: 1, CategoryID: Nullable[int]{Set: true, Value: &phones}})
+
+		assert.ErrorIs(t, err, ErrInvalidAttribute)
+		mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
 }
 
 func TestGetProduct(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		mockRepo := new(MockRepo)
-		service := NewService(mockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
 
-		expected := &Product{ID: 1, Name: "P"}
+		expected := &Product{ID: 1, UserID: 1, Name: "P"}
 		mockRepo.On("GetByID", mock.Anything, 1).Return(expected, nil)
 
-		product, err := service.GetProduct(context.Background(), 1)
+		product, err := service.GetProduct(context.Background(), 1, 1)
 		assert.NoError(t, err)
 		assert.Equal(t, expected, product)
 	})
 
+	t.Run("OtherUsersProduct", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("GetByID", mock.Anything, 1).Return(&Product{ID: 1, UserID: 1, Name: "P"}, nil)
+
+		product, err := service.GetProduct(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		assert.Nil(t, product)
+	})
+
 	t.Run("InvalidID", func(t *testing.T) {
-		service := NewService(nil)
-		_, err := service.GetProduct(context.Background(), 0)
+		service := NewService(nil, nil, testWarranties, testLocations, testCategories)
+		_, err := service.GetProduct(context.Background(), 0, 1)
 		assert.Error(t, err)
 		assert.Equal(t, "invalid product ID", err.Error())
 	})
 }
 
 func TestListProducts(t *testing.T) {
-	t.Run("Success", func(t *testing.T) {
+	ctx := context.Background()
+	price := func(v float64) *float64 { return &v }
+
+	t.Run("Default Sort", func(t *testing.T) {
 		mockRepo := new(MockRepo)
-		service := NewService(mockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
 
 		expected := []*Product{{ID: 1}, {ID: 2}}
-		mockRepo.On("ListByUserID", mock.Anything, 1).Return(expected, nil)
+		mockRepo.On("List", ctx, ListQuery{UserID: 1, Sort: SortCreatedAt, Desc: true, Limit: DefaultPageSize + 1}).Return(expected, nil)
 
-		products, err := service.ListProducts(context.Background(), 1)
+		page, err := service.ListProducts(ctx, ListQuery{UserID: 1, Desc: true}, "")
 		assert.NoError(t, err)
-		assert.Equal(t, expected, products)
+		assert.Equal(t, expected, page.Products)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("Cursor Round Trip", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		query := ListQuery{UserID: 1, Sort: SortPrice, Limit: 2}
+		mockRepo.On("List", ctx, mock.MatchedBy(func(q ListQuery) bool { return q.After == nil })).
+			Return([]*Product{{ID: 3}, {ID: 7, Price: price(10)}, {ID: 4, Price: price(10)}}, nil)
+
+		page, err := service.ListProducts(ctx, query, "")
+		assert.NoError(t, err)
+		assert.Len(t, page.Products, 2)
+		assert.NotEmpty(t, page.NextCursor)
+
+		mockRepo.On("List", ctx, mock.MatchedBy(func(q ListQuery) bool {
+			return q.After != nil && q.After.Value == 10.0 && q.After.ID == 7 && q.Limit == 3
+		})).Return([]*Product{{ID: 4, Price: price(10)}}, nil)
+
+		page, err = service.ListProducts(ctx, query, page.NextCursor)
+		assert.NoError(t, err)
+		assert.Len(t, page.Products, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("Date Cursor", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		bought := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		query := ListQuery{UserID: 1, Sort: SortPurchased, Desc: true, Limit: 1}
+		mockRepo.On("List", ctx, mock.MatchedBy(func(q ListQuery) bool { return q.After == nil })).
+			Return([]*Product{{ID: 1, PurchaseDate: &bought}, {ID: 2}}, nil)
+		mockRepo.On("List", ctx, mock.MatchedBy(func(q ListQuery) bool {
+			return q.After != nil && q.After.Value == bought
+		})).Return([]*Product{{ID: 2}}, nil)
+
+		page, err := service.ListProducts(ctx, query, "")
+		assert.NoError(t, err)
+		_, err = service.ListProducts(ctx, query, page.NextCursor)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Tag Filter", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		// A repeated tag would make "all" unmatchable
+		mockRepo.On("List", ctx, mock.MatchedBy(func(q ListQuery) bool {
+			return assert.ObjectsAreEqual([]int{3, 7}, q.TagIDs) && q.TagMatch == TagMatchAll
+		})).Return([]*Product{}, nil)
+
+		_, err := service.ListProducts(ctx, ListQuery{UserID: 1, TagIDs: []int{3, 7, 3}, TagMatch: TagMatchAll}, "")
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Query", func(t *testing.T) {
+		service := NewService(new(MockRepo), nil, testWarranties, testLocations, testCategories)
+
+		queries := []ListQuery{
+			{UserID: 1, Sort: "brand"},
+			{UserID: 1, Warranty: "soon"},
+			{UserID: 1, MinPrice: price(20), MaxPrice: price(10)},
+			{UserID: 1, TagIDs: []int{3}, TagMatch: "some"},
+			{UserID: 1, Attributes: map[string]string{"ram_gb": ""}},
+		}
+		for _, q := range queries {
+			_, err := service.ListProducts(ctx, q, "")
+			assert.ErrorIs(t, err, ErrInvalidQuery)
+		}
+
+		_, err := service.ListProducts(ctx, ListQuery{UserID: 1}, "not-a-cursor")
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+
+		// A cursor from a name-sorted listing can't page a price-sorted one
+		cursor := encodeCursor(&Cursor{Sort: SortName, Value: "Fridge", ID: 4})
+		_, err = service.ListProducts(ctx, ListQuery{UserID: 1, Sort: SortPrice}, cursor)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+}
+
+func TestUpdateProduct(t *testing.T) {
+	ctx := context.Background()
+	price := 499.0
+	stored := func() *Product {
+		return &Product{
+			ID: 5, UserID: 1, Name: "Fridg", Brand: "Cool", Price: &price,
+			PurchaseDetails: &PurchaseDetails{ProductID: 5, ShopName: "Shop"},
+		}
+	}
+
+	t.Run("Partial Update", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		var req UpdateProductRequest
+		assert.NoError(t, json.Unmarshal([]byte(`{"name": "Fridge", "price": null, "purchase_details": null}`), &req))
+		req.UserID = 1
+
+		mockRepo.On("GetByID", ctx, 5).Return(stored(), nil)
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(p *Product) bool {
+			return p.Name == "Fridge" && p.Brand == "Cool" && p.Price == nil && p.PurchaseDetails == nil
+		})).Return(nil)
+
+		product, err := service.UpdateProduct(ctx, 5, req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Fridge", product.Name)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Replaces Purchase Details", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		var req UpdateProductRequest
+		assert.NoError(t, json.Unmarshal([]byte(`{"purchase_details": {"shop_name": "Other", "order_id": "A-1"}, "purchase_date": "2026-01-02T00:00:00Z"}`), &req))
+		req.UserID = 1
+
+		mockRepo.On("GetByID", ctx, 5).Return(stored(), nil)
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(p *Product) bool {
+			return p.PurchaseDetails.ShopName == "Other" && p.PurchaseDetails.OrderID == "A-1" &&
+				p.PurchaseDate.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)) && *p.Price == price
+		})).Return(nil)
+
+		_, err := service.UpdateProduct(ctx, 5, req)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Other Users Product", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		name := "Mine now"
+		mockRepo.On("GetByID", ctx, 5).Return(stored(), nil)
+
+		_, err := service.UpdateProduct(ctx, 5, UpdateProductRequest{UserID: 2, Name: &name})
+
+		assert.Equal(t, authz.ErrForbidden, err)
+		mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Empty Name", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		name := " "
+		mockRepo.On("GetByID", ctx, 5).Return(stored(), nil)
+
+		_, err := service.UpdateProduct(ctx, 5, UpdateProductRequest{UserID: 1, Name: &name})
+
+		assert.Equal(t, ErrNameRequired, err)
+	})
+}
+
+func TestDeleteProduct(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deletes Bill Files", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, mockStorage, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("GetByID", ctx, 5).Return(&Product{ID: 5, UserID: 1}, nil)
+		mockRepo.On("Delete", ctx, 5).Return([]string{"http://localhost/uploads/u/bills/1_a.pdf", "http://localhost/uploads/u/bills/2_b.pdf"}, nil)
+		mockStorage.On("Delete", ctx, "http://localhost/uploads/u/bills/1_a.pdf").Return(errors.New("disk error"))
+		mockStorage.On("Delete", ctx, "http://localhost/uploads/u/bills/2_b.pdf").Return(nil)
+
+		err := service.DeleteProduct(ctx, 5, 1)
+
+		assert.NoError(t, err)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Other Users Product", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, mockStorage, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("GetByID", ctx, 5).Return(&Product{ID: 5, UserID: 1}, nil)
+
+		err := service.DeleteProduct(ctx, 5, 2)
+
+		assert.Equal(t, authz.ErrForbidden, err)
+		mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		mockStorage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("GetByID", ctx, 9).Return(nil, ErrProductNotFound)
+
+		err := service.DeleteProduct(ctx, 9, 1)
+
+		assert.Equal(t, ErrProductNotFound, err)
+	})
+}
+
+func TestProductWarranty(t *testing.T) {
+	ctx := context.Background()
+	date := func(y int, m time.Month, d int) *time.Time {
+		t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	months := func(n int) *int { return &n }
+
+	t.Run("Inferred From Category", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("GetByID", ctx, 1).Return(&Product{
+			ID: 1, UserID: 1, PurchaseDate: date(2025, 11, 1), DefaultWarrantyMonths: months(12),
+		}, nil)
+
+		product, err := service.GetProduct(ctx, 1, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, warranty.StatusExpiring, product.Warranty.Status)
+		assert.Equal(t, date(2026, 11, 1), product.Warranty.EndDate)
+		assert.Equal(t, 15, *product.Warranty.DaysRemaining)
+		assert.True(t, product.Warranty.Inferred)
+	})
+
+	t.Run("From Records", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, newTestWarranties(map[int][]*warranty.Record{
+			1: {
+				{ID: 7, ProductID: 1, Type: warranty.TypeExtended, StartDate: date(2027, 1, 1), EndDate: *date(2030, 1, 1)},
+				{ID: 6, ProductID: 1, Type: warranty.TypeManufacturer, EndDate: *date(2027, 1, 1)},
+				{ID: 5, ProductID: 1, Type: warranty.TypeInsurance, EndDate: *date(2026, 5, 1)},
+			},
+		}), testLocations, testCategories)
+
+		// The stored end date is superseded by the records
+		mockRepo.On("GetByID", ctx, 1).Return(&Product{ID: 1, UserID: 1, WarrantyEndDate: date(2026, 1, 1)}, nil)
+
+		product, err := service.GetProduct(ctx, 1, 1)
+
+		assert.NoError(t, err)
+		assert.Len(t, product.Warranties, 3)
+		// The extended warranty hasn't started, so the manufacturer one counts
+		assert.Equal(t, 6, *product.Warranty.RecordID)
+		assert.Equal(t, warranty.StatusActive, product.Warranty.Status)
+		assert.Equal(t, date(2027, 1, 1), product.Warranty.EndDate)
+	})
+
+	t.Run("In Listings", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		mockRepo.On("List", ctx, mock.Anything).Return([]*Product{
+			{ID: 1, WarrantyEndDate: date(2027, 10, 17)},
+			{ID: 2, WarrantyEndDate: date(2026, 10, 16)},
+			{ID: 3, PurchaseDate: date(2026, 1, 1)},
+		}, nil)
+
+		page, err := service.ListProducts(ctx, ListQuery{UserID: 1}, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, warranty.StatusActive, page.Products[0].Warranty.Status)
+		assert.Equal(t, warranty.StatusExpired, page.Products[1].Warranty.Status)
+		assert.Equal(t, -1, *page.Products[1].Warranty.DaysRemaining)
+		assert.Equal(t, warranty.StatusUnknown, page.Products[2].Warranty.Status)
+		assert.Nil(t, page.Products[2].Warranty.DaysRemaining)
+	})
+
+	t.Run("End Date Cursor", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		// No stored end date: the cursor holds the inferred one, which is
+		// what the repository sorts on
+		query := ListQuery{UserID: 1, Sort: SortWarrantyEnd, Limit: 1}
+		mockRepo.On("List", ctx, mock.MatchedBy(func(q ListQuery) bool { return q.After == nil })).
+			Return([]*Product{{ID: 1, PurchaseDate: date(2025, 11, 1), DefaultWarrantyMonths: months(12)}, {ID: 2}}, nil)
+		mockRepo.On("List", ctx, mock.MatchedBy(func(q ListQuery) bool {
+			return q.After != nil && q.After.Value == *date(2026, 11, 1) && q.After.ID == 1
+		})).Return([]*Product{{ID: 2}}, nil)
+
+		page, err := service.ListProducts(ctx, query, "")
+		assert.NoError(t, err)
+		_, err = service.ListProducts(ctx, query, page.NextCursor)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestListExpiringProducts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Within Window", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil, testWarranties, testLocations, testCategories)
+
+		end := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+		today := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+		mockRepo.On("ListExpiring", ctx, 1, today, today.AddDate(0, 0, 60)).
+			Return([]*Product{{ID: 4, UserID: 1, WarrantyEndDate: &end}}, nil)
+
+		products, err := service.ListExpiringProducts(ctx, 1, 60)
+
+		assert.NoError(t, err)
+		assert.Len(t, products, 1)
+		// 45 days out is expiring within the requested 60, not just the default 30
+		assert.Equal(t, warranty.StatusExpiring, products[0].Warranty.Status)
+		assert.Equal(t, 45, *products[0].Warranty.DaysRemaining)
+	})
+
+	t.Run("Window Too Large", func(t *testing.T) {
+		service := NewService(new(MockRepo), nil, testWarranties, testLocations, testCategories)
+
+		_, err := service.ListExpiringProducts(ctx, 1, MaxExpiringWithin+1)
+
+		assert.ErrorIs(t, err, ErrInvalidQuery)
 	})
 }
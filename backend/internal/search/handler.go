@@ -0,0 +1,36 @@
+package search
+
+import (
+	"encoding/json"
+	"keepsy-backend/internal/services/auth"
+	"net/http"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Search handles GET /search?q=...
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	results, err := h.service.Search(r.Context(), userID, r.URL.Query().Get("q"))
+	if err != nil {
+		if err == ErrEmptyQuery {
+			http.Error(w, "Missing search query", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
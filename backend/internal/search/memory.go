@@ -0,0 +1,116 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Document is something MemoryIndex can find, with the same fields the
+// MySQL index searches for its type.
+type Document struct {
+	Type      string
+	ID        int
+	ProductID int
+	UserID    int
+	Title     string
+	Fields    map[string]string
+}
+
+// MemoryIndex is an in-process Index for tests and small setups. Documents
+// have to be added (and replaced) explicitly.
+type MemoryIndex struct {
+	mu   sync.RWMutex
+	docs map[string]map[int]*Document // type -> id -> document
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{docs: make(map[string]map[int]*Document)}
+}
+
+// Put adds the document, replacing one with the same type and ID.
+func (i *MemoryIndex) Put(doc Document) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.docs[doc.Type] == nil {
+		i.docs[doc.Type] = make(map[int]*Document)
+	}
+	i.docs[doc.Type][doc.ID] = &doc
+}
+
+func (i *MemoryIndex) Remove(typ string, id int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.docs[typ], id)
+}
+
+func (i *MemoryIndex) Search(ctx context.Context, userID int, terms []string, limit int) ([]*Hit, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var hits []*Hit
+	for typ, docs := range i.docs {
+		var typeHits []*Hit
+		for _, doc := range docs {
+			if doc.UserID != userID {
+				continue
+			}
+			if score := scoreDocument(doc, terms); score > 0 {
+				fields := make(map[string]string, len(doc.Fields))
+				for name, value := range doc.Fields {
+					fields[name] = value
+				}
+				typeHits = append(typeHits, &Hit{
+					Type:      typ,
+					ID:        doc.ID,
+					ProductID: doc.ProductID,
+					Title:     doc.Title,
+					Score:     score,
+					Fields:    fields,
+				})
+			}
+		}
+
+		sort.Slice(typeHits, func(a, b int) bool {
+			if typeHits[a].Score != typeHits[b].Score {
+				return typeHits[a].Score > typeHits[b].Score
+			}
+			return typeHits[a].ID > typeHits[b].ID
+		})
+		if limit > 0 && len(typeHits) > limit {
+			typeHits = typeHits[:limit]
+		}
+		hits = append(hits, typeHits...)
+	}
+	return hits, nil
+}
+
+// scoreDocument counts the words of doc matching each term, a whole word
+// counting twice as much as a prefix. It's 0 unless every term matches.
+func scoreDocument(doc *Document, terms []string) float64 {
+	var words []string
+	for _, value := range doc.Fields {
+		words = append(words, Terms(value)...)
+	}
+
+	total := 0.0
+	for _, term := range terms {
+		score := 0.0
+		for _, word := range words {
+			switch {
+			case word == term:
+				score += 2
+			case strings.HasPrefix(word, term):
+				score++
+			}
+		}
+		if score == 0 {
+			return 0
+		}
+		total += score
+	}
+	return total
+}
@@ -0,0 +1,43 @@
+package search
+
+import (
+	"context"
+)
+
+// Result types. Each hit belongs to the user's product it came from.
+const (
+	TypeProduct  = "product"  // name, brand, model, location
+	TypePurchase = "purchase" // shop name, order ID
+	TypeBill     = "bill"     // text extracted from the bill file
+)
+
+// Hit is one matching product, purchase or bill.
+type Hit struct {
+	Type      string  `json:"type"`
+	ID        int     `json:"id"` // Product ID, or bill ID for bills
+	ProductID int     `json:"product_id"`
+	Title     string  `json:"title"` // Product name
+	Score     float64 `json:"score"`
+	// Fields holds the searchable fields of the hit; Highlights only those
+	// that matched, with the matches marked.
+	Fields     map[string]string `json:"-"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type Group struct {
+	Type string `json:"type"`
+	Hits []*Hit `json:"hits"`
+}
+
+// Results are grouped by type: products, then purchases, then bills.
+type Results struct {
+	Query  string   `json:"query"`
+	Groups []*Group `json:"groups"`
+}
+
+// Index finds a user's products, purchases and bills matching all terms
+// (each term also matches as a word prefix), best match first and at most
+// limit hits per type.
+type Index interface {
+	Search(ctx context.Context, userID int, terms []string, limit int) ([]*Hit, error)
+}
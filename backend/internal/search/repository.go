@@ -0,0 +1,145 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MySQLIndex searches the product, purchase details and bill tables through
+// their FULLTEXT indexes (migration 000013), so there is nothing to keep in
+// sync.
+type MySQLIndex struct {
+	db *sql.DB
+}
+
+func NewMySQLIndex(db *sql.DB) *MySQLIndex {
+	return &MySQLIndex{db: db}
+}
+
+// minTokenSize is InnoDB's default innodb_ft_min_token_size. Shorter words
+// aren't in the FULLTEXT indexes, so terms like "od" or "lg" are matched
+// with LIKE instead.
+const minTokenSize = 3
+
+// searches run one query per type. Each selects id, product_id, title and
+// score, followed by columns, the searchable fields named in fields. match
+// is the FULLTEXT index over columns.
+var searches = []struct {
+	typ     string
+	fields  []string
+	columns []string
+	match   string
+	id      string
+	from    string
+}{
+	{
+		typ:     TypeProduct,
+		fields:  []string{"name", "brand", "model", "location"},
+		columns: []string{"p.name", "COALESCE(p.brand, '')", "COALESCE(p.model, '')", "COALESCE(p.location, '')"},
+		match:   "MATCH(p.name, p.brand, p.model, p.location)",
+		id:      "p.id",
+		from:    "keepsy_products p",
+	},
+	{
+		typ:     TypePurchase,
+		fields:  []string{"shop_name", "order_id"},
+		columns: []string{"COALESCE(d.shop_name, '')", "COALESCE(d.order_id, '')"},
+		match:   "MATCH(d.shop_name, d.order_id)",
+		id:      "p.id",
+		from:    "keepsy_product_purchase_details d JOIN keepsy_products p ON p.id = d.product_id",
+	},
+	{
+		typ:     TypeBill,
+		fields:  []string{"text"},
+		columns: []string{"COALESCE(b.extracted_text, '')"},
+		match:   "MATCH(b.extracted_text)",
+		id:      "b.id",
+		from:    "keepsy_bills b JOIN keepsy_products p ON p.id = b.product_id",
+	},
+}
+
+func (i *MySQLIndex) Search(ctx context.Context, userID int, terms []string, limit int) ([]*Hit, error) {
+	var hits []*Hit
+	for _, search := range searches {
+		query, args := searchQuery(search.columns, search.match, search.id, search.from, userID, terms, limit)
+		rows, err := i.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %ss: %w", search.typ, err)
+		}
+
+		for rows.Next() {
+			hit := &Hit{Type: search.typ, Fields: make(map[string]string, len(search.fields))}
+			values := make([]string, len(search.fields))
+			dest := []any{&hit.ID, &hit.ProductID, &hit.Title, &hit.Score}
+			for j := range values {
+				dest = append(dest, &values[j])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s hit: %w", search.typ, err)
+			}
+			for j, field := range search.fields {
+				hit.Fields[field] = values[j]
+			}
+			hits = append(hits, hit)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %ss: %w", search.typ, err)
+		}
+	}
+	return hits, nil
+}
+
+// searchQuery builds the query for one type. Terms of at least minTokenSize
+// go through the FULLTEXT index, which also gives the score; shorter ones
+// must start a word of one of the columns. Without long terms every hit
+// scores 0.
+func searchQuery(columns []string, match, id, from string, userID int, terms []string, limit int) (string, []any) {
+	var long, short []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= minTokenSize {
+			long = append(long, term)
+		} else {
+			short = append(short, term)
+		}
+	}
+
+	score := "0"
+	var scoreArgs []any
+	where := []string{"p.user_id = ?"}
+	whereArgs := []any{userID}
+	if len(long) > 0 {
+		against := booleanQuery(long)
+		score = match + " AGAINST (? IN BOOLEAN MODE)"
+		scoreArgs = append(scoreArgs, against)
+		where = append(where, score)
+		whereArgs = append(whereArgs, against)
+	}
+	// A leading space lets "% od%" match at the start of the text too
+	text := "CONCAT(' ', CONCAT_WS(' ', " + strings.Join(columns, ", ") + "))"
+	for _, term := range short {
+		where = append(where, text+" LIKE ?")
+		whereArgs = append(whereArgs, "% "+term+"%")
+	}
+
+	query := fmt.Sprintf(`SELECT %s, p.id, p.name, %s AS score, %s FROM %s WHERE %s ORDER BY score DESC, %s DESC LIMIT ?`,
+		id, score, strings.Join(columns, ", "), from, strings.Join(where, " AND "), id)
+	args := append(append(scoreArgs, whereArgs...), limit)
+	return query, args
+}
+
+// booleanQuery requires every term, as a word or word prefix:
+// ["croma", "od12"] becomes "+croma* +od12*". Terms only contain letters
+// and digits, so they can't inject boolean operators.
+func booleanQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = "+" + term + "*"
+	}
+	return strings.Join(parts, " ")
+}
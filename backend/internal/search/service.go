@@ -0,0 +1,204 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrEmptyQuery = errors.New("search query is empty")
+
+const (
+	maxTerms    = 8
+	hitsPerType = 20
+	// Longer fields (bill text) are cut to about this many bytes around
+	// the first match.
+	snippetLength = 160
+)
+
+type Service interface {
+	// Search finds the user's products, purchases and bills matching every
+	// word of query, ranked, highlighted and grouped by type.
+	Search(ctx context.Context, userID int, query string) (*Results, error)
+}
+
+type service struct {
+	index Index
+}
+
+func NewService(index Index) Service {
+	return &service{index: index}
+}
+
+func (s *service) Search(ctx context.Context, userID int, query string) (*Results, error) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if len(terms) > maxTerms {
+		terms = terms[:maxTerms]
+	}
+
+	hits, err := s.index.Search(ctx, userID, terms, hitsPerType)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{Query: query, Groups: []*Group{}}
+	groups := make(map[string]*Group)
+	for _, hit := range hits {
+		hit.Highlights = make(map[string]string)
+		for field, value := range hit.Fields {
+			if marked, ok := highlight(snippet(value, terms), terms); ok {
+				hit.Highlights[field] = marked
+			}
+		}
+
+		group, ok := groups[hit.Type]
+		if !ok {
+			group = &Group{Type: hit.Type}
+			groups[hit.Type] = group
+			results.Groups = append(results.Groups, group)
+		}
+		group.Hits = append(group.Hits, hit)
+	}
+
+	for _, group := range results.Groups {
+		sort.SliceStable(group.Hits, func(a, b int) bool { return group.Hits[a].Score > group.Hits[b].Score })
+	}
+	// Scores from different FULLTEXT indexes aren't comparable, so groups
+	// come in a fixed order.
+	sort.SliceStable(results.Groups, func(a, b int) bool {
+		return typeRank(results.Groups[a].Type) < typeRank(results.Groups[b].Type)
+	})
+	return results, nil
+}
+
+// typeRank is the position of a group of type typ in the results.
+func typeRank(typ string) int {
+	switch typ {
+	case TypeProduct:
+		return 0
+	case TypePurchase:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Terms splits text into lower-cased words of letters and digits, without
+// duplicates: "OD-1234 Croma croma" gives ["od", "1234", "croma"].
+func Terms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// highlight HTML-escapes text and wraps the parts of words matching a term
+// in <mark>. ok is false when nothing matched.
+func highlight(text string, terms []string) (marked string, ok bool) {
+	var b strings.Builder
+	last := 0
+	for _, span := range wordSpans(text) {
+		n := matchLength(text[span[0]:span[1]], terms)
+		if n == 0 {
+			continue
+		}
+		ok = true
+		b.WriteString(html.EscapeString(text[last:span[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[span[0] : span[0]+n]))
+		b.WriteString("</mark>")
+		last = span[0] + n
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), ok
+}
+
+// snippet cuts long text down to the part around its first match.
+func snippet(text string, terms []string) string {
+	if len(text) <= snippetLength {
+		return text
+	}
+
+	start := 0
+	for _, span := range wordSpans(text) {
+		if matchLength(text[span[0]:span[1]], terms) > 0 {
+			start = span[0]
+			break
+		}
+	}
+	start = max(0, start-snippetLength/4)
+	end := min(len(text), start+snippetLength)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	cut := strings.TrimSpace(text[start:end])
+	if start > 0 {
+		cut = "…" + cut
+	}
+	if end < len(text) {
+		cut += "…"
+	}
+	return cut
+}
+
+// wordSpans returns the [start, end) byte offsets of the words in text.
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		switch {
+		case !isSeparator(r) && start < 0:
+			start = i
+		case isSeparator(r) && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// matchLength is how many bytes at the start of word the longest matching
+// term covers, 0 if no term is a prefix of word.
+func matchLength(word string, terms []string) int {
+	best := 0
+	for _, term := range terms {
+		i := 0
+		rest := term
+		for rest != "" && i < len(word) {
+			r, size := utf8.DecodeRuneInString(word[i:])
+			t, tsize := utf8.DecodeRuneInString(rest)
+			if unicode.ToLower(r) != t {
+				break
+			}
+			i += size
+			rest = rest[tsize:]
+		}
+		if rest == "" && i > best {
+			best = i
+		}
+	}
+	return best
+}
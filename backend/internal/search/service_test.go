@@ -0,0 +1,177 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"keepsy-backend/internal/bills"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIndex() *MemoryIndex {
+	index := NewMemoryIndex()
+	index.Put(Document{
+		Type: TypeProduct, ID: 1, ProductID: 1, UserID: 1, Title: "Fridge",
+		Fields: map[string]string{"name": "Fridge", "brand": "Samsung", "model": "RT28", "location": "Kitchen"},
+	})
+	index.Put(Document{
+		Type: TypeProduct, ID: 2, ProductID: 2, UserID: 1, Title: "Croma Kettle",
+		Fields: map[string]string{"name": "Croma Kettle", "brand": "Croma", "model": "", "location": "Kitchen"},
+	})
+	index.Put(Document{
+		Type: TypePurchase, ID: 1, ProductID: 1, UserID: 1, Title: "Fridge",
+		Fields: map[string]string{"shop_name": "Croma", "order_id": "OD-4411"},
+	})
+	index.Put(Document{
+		Type: TypeBill, ID: 9, ProductID: 1, UserID: 1, Title: "Fridge",
+		Fields: map[string]string{"text": strings.Repeat("Tax invoice line. ", 20) + "Sold by Croma Retail, Andheri <West>. " + strings.Repeat("Terms apply. ", 20)},
+	})
+	// Another user's purchase from the same shop
+	index.Put(Document{
+		Type: TypePurchase, ID: 3, ProductID: 3, UserID: 2, Title: "TV",
+		Fields: map[string]string{"shop_name": "Croma", "order_id": "OD-9999"},
+	})
+	return index
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Grouped And Ranked", func(t *testing.T) {
+		service := NewService(newTestIndex())
+
+		results, err := service.Search(ctx, 1, "croma")
+
+		assert.NoError(t, err)
+		assert.Len(t, results.Groups, 3)
+		assert.Equal(t, TypeProduct, results.Groups[0].Type)
+		assert.Equal(t, TypePurchase, results.Groups[1].Type)
+		assert.Equal(t, TypeBill, results.Groups[2].Type)
+		assert.Len(t, results.Groups[0].Hits, 1)
+		assert.Equal(t, 2, results.Groups[0].Hits[0].ID)
+		assert.Equal(t, "<mark>Croma</mark> Kettle", results.Groups[0].Hits[0].Highlights["name"])
+		assert.Equal(t, "<mark>Croma</mark>", results.Groups[0].Hits[0].Highlights["brand"])
+		assert.NotContains(t, results.Groups[0].Hits[0].Highlights, "location")
+
+		for _, group := range results.Groups {
+			for _, hit := range group.Hits {
+				assert.NotEqual(t, 3, hit.ProductID, "other user's purchase")
+			}
+		}
+	})
+
+	t.Run("Order ID Prefix", func(t *testing.T) {
+		service := NewService(newTestIndex())
+
+		results, err := service.Search(ctx, 1, "od-44")
+
+		assert.NoError(t, err)
+		assert.Len(t, results.Groups, 1)
+		hit := results.Groups[0].Hits[0]
+		assert.Equal(t, TypePurchase, hit.Type)
+		assert.Equal(t, "Fridge", hit.Title)
+		assert.Equal(t, "<mark>OD</mark>-<mark>44</mark>11", hit.Highlights["order_id"])
+	})
+
+	t.Run("All Terms Must Match", func(t *testing.T) {
+		service := NewService(newTestIndex())
+
+		results, err := service.Search(ctx, 1, "croma samsung")
+
+		assert.NoError(t, err)
+		assert.Empty(t, results.Groups)
+	})
+
+	t.Run("Bill Text Snippet", func(t *testing.T) {
+		service := NewService(newTestIndex())
+
+		results, err := service.Search(ctx, 1, "andheri")
+
+		assert.NoError(t, err)
+		assert.Len(t, results.Groups, 1)
+		text := results.Groups[0].Hits[0].Highlights["text"]
+		assert.Contains(t, text, "Croma Retail, <mark>Andheri</mark> &lt;West&gt;.")
+		assert.True(t, strings.HasPrefix(text, "…"))
+		assert.True(t, strings.HasSuffix(text, "…"))
+		assert.Less(t, len(text), snippetLength+50)
+	})
+
+	t.Run("Empty Query", func(t *testing.T) {
+		service := NewService(newTestIndex())
+
+		_, err := service.Search(ctx, 1, " - ")
+
+		assert.Equal(t, ErrEmptyQuery, err)
+	})
+}
+
+// indexedBillRepo stands in for keepsy_bills, whose extracted_text column
+// is what the MySQL index searches: stored text goes straight to the index.
+type indexedBillRepo struct {
+	bills.Repository
+	bill  *bills.Bill
+	index *MemoryIndex
+}
+
+func (r indexedBillRepo) GetByID(ctx context.Context, id int) (*bills.Bill, error) {
+	if id != r.bill.ID {
+		return nil, bills.ErrBillNotFound
+	}
+	return r.bill, nil
+}
+
+func (r indexedBillRepo) UpdateExtractedText(ctx context.Context, id int, text string) error {
+	r.index.Put(Document{
+		Type: TypeBill, ID: id, ProductID: r.bill.ProductID, UserID: r.bill.UserID, Title: "Fridge",
+		Fields: map[string]string{"text": text},
+	})
+	return nil
+}
+
+func TestSearchStoredBillText(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	repo := indexedBillRepo{bill: &bills.Bill{ID: 4, UserID: 1, ProductID: 1}, index: index}
+	billsService := bills.NewService(repo, nil, nil, nil)
+	service := NewService(index)
+
+	results, err := service.Search(ctx, 1, "vijay sales")
+	assert.NoError(t, err)
+	assert.Empty(t, results.Groups)
+
+	err = billsService.UpdateBillText(ctx, 4, 1, "TAX INVOICE\nVijay Sales, Pune\nRefrigerator 1 x 32,990.00")
+	assert.NoError(t, err)
+
+	results, err = service.Search(ctx, 1, "vijay sales")
+
+	assert.NoError(t, err)
+	assert.Len(t, results.Groups, 1)
+	assert.Equal(t, TypeBill, results.Groups[0].Type)
+	assert.Equal(t, 4, results.Groups[0].Hits[0].ID)
+	assert.Contains(t, results.Groups[0].Hits[0].Highlights["text"], "<mark>Vijay</mark> <mark>Sales</mark>")
+}
+
+func TestSearchQuery(t *testing.T) {
+	search := searches[1]
+
+	query, args := searchQuery(search.columns, search.match, search.id, search.from, 1, []string{"od", "1234"}, 20)
+
+	// "od" is below the FULLTEXT minimum token size
+	assert.Contains(t, query, "MATCH(d.shop_name, d.order_id) AGAINST (? IN BOOLEAN MODE) AS score")
+	assert.Contains(t, query, "LIKE ?")
+	assert.Equal(t, []any{"+1234*", 1, "+1234*", "% od%", 20}, args)
+
+	query, args = searchQuery(search.columns, search.match, search.id, search.from, 1, []string{"lg"}, 20)
+
+	assert.NotContains(t, query, "MATCH")
+	assert.Contains(t, query, "0 AS score")
+	assert.Equal(t, []any{1, "% lg%", 20}, args)
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"od", "1234", "croma"}, Terms("OD-1234 Croma croma"))
+	assert.Equal(t, "+od* +1234*", booleanQuery(Terms("OD-1234")))
+	assert.Empty(t, Terms("+*()"))
+}
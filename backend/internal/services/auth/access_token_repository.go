@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrAccessTokenNotFound = errors.New("access token not found")
+
+type AccessTokenRepository interface {
+	Create(ctx context.Context, token *AccessTokenInfo) error
+	GetByHash(ctx context.Context, tokenHash string) (*AccessTokenInfo, error)
+	// ListActiveByUserID returns the user's tokens that are neither revoked nor expired.
+	ListActiveByUserID(ctx context.Context, userID int) ([]*AccessTokenInfo, error)
+	Touch(ctx context.Context, id int, usedAt time.Time) error
+	// Revoke revokes one of the user's tokens. It returns false if the user has no such active token.
+	Revoke(ctx context.Context, userID, id int) (bool, error)
+}
+
+type MySQLAccessTokenRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLAccessTokenRepository(db *sql.DB) *MySQLAccessTokenRepository {
+	return &MySQLAccessTokenRepository{db: db}
+}
+
+const accessTokenColumns = `id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func (r *MySQLAccessTokenRepository) Create(ctx context.Context, token *AccessTokenInfo) error {
+	query := `
+		INSERT INTO keepsy_access_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	token.CreatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx, query,
+		token.UserID, token.Name, token.Prefix, token.TokenHash, strings.Join(token.Scopes, " "), token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	token.ID = int(id)
+	return nil
+}
+
+func (r *MySQLAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*AccessTokenInfo, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM keepsy_access_tokens WHERE token_hash = ?`
+	token, err := scanAccessToken(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccessTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *MySQLAccessTokenRepository) ListActiveByUserID(ctx context.Context, userID int) ([]*AccessTokenInfo, error) {
+	query := `
+		SELECT ` + accessTokenColumns + ` FROM keepsy_access_tokens
+		WHERE user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*AccessTokenInfo
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *MySQLAccessTokenRepository) Touch(ctx context.Context, id int, usedAt time.Time) error {
+	query := `UPDATE keepsy_access_tokens SET last_used_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, usedAt, id); err != nil {
+		return fmt.Errorf("failed to touch access token: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLAccessTokenRepository) Revoke(ctx context.Context, userID, id int) (bool, error) {
+	query := `UPDATE keepsy_access_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return n == 1, nil
+}
+
+func scanAccessToken(row rowScanner) (*AccessTokenInfo, error) {
+	var token AccessTokenInfo
+	var scopes string
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.TokenHash, &scopes,
+		&token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	return &token, nil
+}
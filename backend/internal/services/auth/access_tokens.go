@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// accessTokenPrefix marks personal access tokens, so Authenticate can tell
+// them from session JWTs without trying both.
+const accessTokenPrefix = "kpat_"
+
+// scopeResources are the API areas a token can be given access to, as
+// "<resource>:read", "<resource>:write" or "<resource>:*".
+var scopeResources = map[string]bool{
+	"products":   true,
+	"bills":      true,
+	"reminders":  true,
+	"categories": true,
+	"locations":  true,
+	"tags":       true,
+}
+
+var (
+	ErrAccessTokenNameRequired = errors.New("token name is required")
+	ErrInvalidScope            = errors.New("invalid scope")
+	ErrInvalidExpiry           = errors.New("expiry must be in the future")
+)
+
+func (s *service) CreateAccessToken(ctx context.Context, userID int, req CreateAccessTokenRequest) (*CreatedAccessToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrAccessTokenNameRequired
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	raw := accessTokenPrefix + secret
+
+	token := &AccessTokenInfo{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:len(accessTokenPrefix)+6],
+		TokenHash: hashResetToken(raw),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.accessTokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordEvent(ctx, EventAccessTokenCreated, &userID, req.ClientInfo, token.Prefix); err != nil {
+		return nil, err
+	}
+	return &CreatedAccessToken{AccessTokenInfo: token, Token: raw}, nil
+}
+
+func (s *service) ListAccessTokens(ctx context.Context, userID int) ([]*AccessTokenInfo, error) {
+	return s.accessTokenRepo.ListActiveByUserID(ctx, userID)
+}
+
+func (s *service) RevokeAccessToken(ctx context.Context, userID, id int) error {
+	revoked, err := s.accessTokenRepo.Revoke(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAccessTokenNotFound
+	}
+	return s.recordEvent(ctx, EventAccessTokenRevoked, &userID, ClientInfo{}, "")
+}
+
+// authenticateAccessToken resolves a personal access token to its principal.
+func (s *service) authenticateAccessToken(ctx context.Context, raw string) (*Principal, error) {
+	token, err := s.accessTokenRepo.GetByHash(ctx, hashResetToken(raw))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && !now.Before(*token.ExpiresAt)) {
+		return nil, ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > touchInterval {
+		// Best effort, a failed write shouldn't fail the request
+		_ = s.accessTokenRepo.Touch(ctx, token.ID, now)
+	}
+
+	return &Principal{UserID: token.UserID, AccessTokenID: token.ID, Scopes: token.Scopes}, nil
+}
+
+// normalizeScopes validates the requested scopes and drops duplicates.
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		resource, action, _ := strings.Cut(scope, ":")
+		if !scopeResources[resource] || (action != "read" && action != "write" && action != "*") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			out = append(out, scope)
+		}
+	}
+	return out, nil
+}
@@ -2,16 +2,27 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
+	"keepsy-backend/internal/users"
+	"log"
+	"math"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
 )
 
 type Handler struct {
-	service Service
+	service        Service
+	trustedProxies []netip.Prefix
 }
 
-func NewHandler(service Service) *Handler {
+// NewHandler creates the auth handler. X-Forwarded-For is only believed on
+// requests that come from trustedProxies, such as the load balancer.
+func NewHandler(service Service, trustedProxies []netip.Prefix) *Handler {
 	return &Handler{
-		service: service,
+		service:        service,
+		trustedProxies: trustedProxies,
 	}
 }
 
@@ -21,9 +32,22 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	req.ClientInfo = h.clientInfo(r, req.DeviceName)
 
-	user, err := h.service.Register(r.Context(), req)
+	resp, err := h.service.Register(r.Context(), req)
 	if err != nil {
+		if errors.Is(err, ErrWeakPassword) {
+			writeError(w, http.StatusBadRequest, "weak_password", err.Error())
+			return
+		}
+		if errors.Is(err, users.ErrEmailTaken) {
+			writeError(w, http.StatusConflict, "email_taken", users.ErrEmailTaken.Error())
+			return
+		}
+		if errors.Is(err, users.ErrPhoneTaken) {
+			writeError(w, http.StatusConflict, "phone_taken", users.ErrPhoneTaken.Error())
+			return
+		}
 		// In a real app we'd want to distinguish between 400 (validation) and 500 (server)
 		// For now keeping it simple as per original implementation, but maybe slightly better error msg
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -31,7 +55,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -40,20 +64,665 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	req.ClientInfo = h.clientInfo(r, req.DeviceName)
 
-	user, err := h.service.Login(r.Context(), req)
+	resp, err := h.service.Login(r.Context(), req)
 	if err != nil {
+		if writeLocked(w, err) {
+			return
+		}
 		if err == ErrUserNotFound {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":   "user_not_found",
-				"message": "User not found. Please sign up.",
-			})
+			writeError(w, http.StatusNotFound, "user_not_found", "User not found. Please sign up.")
+			return
+		}
+		if err == ErrIdentifierNotVerified {
+			writeError(w, http.StatusForbidden, "identifier_not_verified", "Please verify your email or phone before signing in.")
 			return
 		}
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) RequestLoginCode(w http.ResponseWriter, r *http.Request) {
+	var req LoginCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RequestLoginCode(r.Context(), req); err != nil {
+		switch err {
+		case ErrPhoneRequired:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case ErrUserNotFound:
+			writeError(w, http.StatusNotFound, "user_not_found", "User not found. Please sign up.")
+		case ErrIdentifierNotVerified:
+			writeError(w, http.StatusForbidden, "identifier_not_verified", "Please verify your phone before signing in with a code.")
+		case ErrTooManyRequests:
+			writeError(w, http.StatusTooManyRequests, "too_many_requests", "Too many codes requested. Please try again later.")
+		default:
+			http.Error(w, "Failed to send login code", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Login code sent.",
+	})
+}
+
+func (h *Handler) LoginWithCode(w http.ResponseWriter, r *http.Request) {
+	var req LoginWithCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.ClientInfo = h.clientInfo(r, req.DeviceName)
+
+	resp, err := h.service.LoginWithCode(r.Context(), req)
+	if err != nil {
+		if writeLocked(w, err) {
+			return
+		}
+		switch err {
+		case ErrInvalidCode:
+			writeError(w, http.StatusUnauthorized, "invalid_code", "The code is invalid or has expired.")
+		case ErrTooManyAttempts:
+			writeError(w, http.StatusTooManyRequests, "too_many_attempts", "Too many attempts. Please try again later.")
+		case ErrIdentifierNotVerified:
+			writeError(w, http.StatusForbidden, "identifier_not_verified", "Please verify your phone before signing in with a code.")
+		default:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// LoginSecondFactor finishes a login that returned a "second_factor" challenge.
+func (h *Handler) LoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var req SecondFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.ClientInfo = h.clientInfo(r, req.DeviceName)
+
+	resp, err := h.service.LoginSecondFactor(r.Context(), req)
+	if err != nil {
+		if writeLocked(w, err) {
+			return
+		}
+		switch err {
+		case ErrInvalidToken:
+			writeError(w, http.StatusUnauthorized, "invalid_token", "The login challenge is invalid or has expired. Please sign in again.")
+		case ErrInvalidCode:
+			writeError(w, http.StatusUnauthorized, "invalid_code", "The code is invalid.")
+		default:
+			http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// StartOIDCLogin returns the provider URL to open for signing in. The client
+// keeps "state" and posts it back with the code to LoginWithOIDC.
+func (h *Handler) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authz, err := h.service.StartOIDCLogin(r.Context(), r.PathValue("provider"))
+	if err != nil {
+		if err == ErrUnknownProvider {
+			http.Error(w, "Unknown identity provider", http.StatusNotFound)
+			return
+		}
+		log.Printf("start oidc login: %v", err)
+		http.Error(w, "Failed to start sign in", http.StatusBadGateway)
+		return
+	}
+
+	json.NewEncoder(w).Encode(authz)
+}
+
+func (h *Handler) LoginWithOIDC(w http.ResponseWriter, r *http.Request) {
+	var req OIDCLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.Provider = r.PathValue("provider")
+	req.ClientInfo = h.clientInfo(r, req.DeviceName)
+
+	resp, err := h.service.LoginWithOIDC(r.Context(), req)
+	if err != nil {
+		switch {
+		case err == ErrUnknownProvider:
+			http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		case err == ErrInvalidOIDCState:
+			writeError(w, http.StatusBadRequest, "invalid_state", "The sign in attempt is invalid or has expired. Please try again.")
+		case err == ErrOIDCEmailRequired:
+			writeError(w, http.StatusForbidden, "email_required", "Your account at the provider has no verified email address.")
+		case err == ErrAccountNotLinkable:
+			writeError(w, http.StatusConflict, "account_not_linkable", "An account with this email exists. Verify its email first, then sign in with the provider again.")
+		case errors.Is(err, ErrInvalidIDToken), errors.Is(err, ErrOIDCExchange):
+			log.Printf("oidc login: %v", err)
+			writeError(w, http.StatusUnauthorized, "provider_login_failed", "Sign in with the provider failed. Please try again.")
+		default:
+			log.Printf("oidc login: %v", err)
+			http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListIdentities lists the identity provider logins linked to the current user.
+func (h *Handler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	identities, err := h.service.ListIdentities(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to list identities", http.StatusInternalServerError)
+		return
+	}
+
+	if identities == nil {
+		identities = []*UserIdentity{}
+	}
+	json.NewEncoder(w).Encode(identities)
+}
+
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.service.Refresh(r.Context(), req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid_token", "Refresh token is invalid or expired.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RevokeSession(r.Context(), principal.UserID, principal.SessionID); err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll revokes every session of the current user, including this one.
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RevokeAllSessions(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), principal)
+	if err != nil {
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if sessions == nil {
+		sessions = []*Session{}
+	}
+	json.NewEncoder(w).Encode(sessions)
+}
+
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RevokeSession(r.Context(), userID, r.PathValue("id")); err != nil {
+		if err == ErrSessionNotFound {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateAccessToken creates a personal access token. The token is only shown in this response.
+func (h *Handler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.ClientInfo = h.clientInfo(r, "")
+
+	token, err := h.service.CreateAccessToken(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidScope), errors.Is(err, ErrAccessTokenNameRequired), errors.Is(err, ErrInvalidExpiry):
+			writeError(w, http.StatusBadRequest, "invalid_token_request", err.Error())
+		default:
+			http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
+
+func (h *Handler) ListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.service.ListAccessTokens(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to list tokens", http.StatusInternalServerError)
+		return
+	}
+
+	if tokens == nil {
+		tokens = []*AccessTokenInfo{}
+	}
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *Handler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid token id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RevokeAccessToken(r.Context(), userID, id); err != nil {
+		if err == ErrAccessTokenNotFound {
+			http.Error(w, "Token not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.ChangePassword(r.Context(), principal, req); err != nil {
+		switch {
+		case errors.Is(err, ErrWeakPassword):
+			writeError(w, http.StatusBadRequest, "weak_password", err.Error())
+		case err == ErrInvalidCurrentPassword:
+			writeError(w, http.StatusBadRequest, "invalid_current_password", "Current password is incorrect.")
+		case err == ErrNoPassword:
+			writeError(w, http.StatusConflict, "no_password", "This account has no password yet. Use forgot password to set one.")
+		case err == ErrPasswordRequired:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	status, err := h.service.TwoFactorStatus(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get two-factor status", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+// EnrollTOTP starts (or restarts) authenticator app setup.
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	setup, err := h.service.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		h.twoFactorError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(setup)
+}
+
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.secondFactorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(r.Context(), userID, req)
+	if err != nil {
+		h.twoFactorError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(codes)
+}
+
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.secondFactorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DisableTOTP(r.Context(), userID, req); err != nil {
+		h.twoFactorError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.secondFactorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(r.Context(), userID, req)
+	if err != nil {
+		h.twoFactorError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(codes)
+}
+
+func (h *Handler) secondFactorRequest(w http.ResponseWriter, r *http.Request) (int, SecondFactorRequest, bool) {
+	var req SecondFactorRequest
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return 0, req, false
+	}
+	req.ClientInfo = h.clientInfo(r, "")
+	return userID, req, true
+}
+
+func (h *Handler) twoFactorError(w http.ResponseWriter, err error) {
+	if writeLocked(w, err) {
+		return
+	}
+	switch err {
+	case ErrInvalidCode:
+		writeError(w, http.StatusBadRequest, "invalid_code", "The code is invalid.")
+	case ErrTwoFactorEnabled:
+		writeError(w, http.StatusConflict, "two_factor_enabled", "Two-factor authentication is already enabled.")
+	case ErrTwoFactorNotEnabled:
+		writeError(w, http.StatusConflict, "two_factor_not_enabled", "Two-factor authentication is not enabled.")
+	case ErrTOTPNotEnrolled:
+		writeError(w, http.StatusConflict, "two_factor_not_enrolled", "Start two-factor setup first.")
+	default:
+		http.Error(w, "Failed to update two-factor authentication", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.ForgotPassword(r.Context(), req); err != nil {
+		if err == ErrEmailRequired {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Don't reveal delivery problems (or whether the account exists) to the caller
+		log.Printf("forgot password: %v", err)
+	}
+
+	// Same response whether or not the email is registered
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If an account exists for that email, a reset link has been sent.",
+	})
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.ClientInfo = h.clientInfo(r, "")
+
+	if err := h.service.ResetPassword(r.Context(), req); err != nil {
+		if err == ErrInvalidResetToken {
+			writeError(w, http.StatusBadRequest, "invalid_token", "Reset link is invalid or has expired.")
+			return
+		}
+		if errors.Is(err, ErrWeakPassword) {
+			writeError(w, http.StatusBadRequest, "weak_password", err.Error())
+			return
+		}
+		if err == ErrPasswordRequired {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail sends a verification code when no code is given, otherwise checks it.
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req EmailVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.Code == "" {
+		h.sendCode(w, h.service.SendEmailVerification(r.Context(), req.Email))
+		return
+	}
+	h.checkCode(w, h.service.VerifyEmail(r.Context(), req.Email, req.Code))
+}
+
+// VerifyPhone sends a verification code when no code is given, otherwise checks it.
+func (h *Handler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
+	var req PhoneVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.Code == "" {
+		h.sendCode(w, h.service.SendPhoneVerification(r.Context(), req.Phone))
+		return
+	}
+	h.checkCode(w, h.service.VerifyPhone(r.Context(), req.Phone, req.Code))
+}
+
+func (h *Handler) sendCode(w http.ResponseWriter, err error) {
+	if err != nil {
+		if err == ErrEmailRequired || err == ErrPhoneRequired {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("send verification code: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the account needs verification, a code has been sent.",
+	})
+}
+
+func (h *Handler) checkCode(w http.ResponseWriter, err error) {
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case ErrInvalidCode:
+		writeError(w, http.StatusBadRequest, "invalid_code", "The code is invalid or has expired.")
+	case ErrTooManyAttempts:
+		writeError(w, http.StatusTooManyRequests, "too_many_attempts", "Too many attempts. Please try again later.")
+	default:
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+	}
+}
+
+// clientInfo captures where a login request came from.
+func (h *Handler) clientInfo(r *http.Request, deviceName string) ClientInfo {
+	return ClientInfo{
+		DeviceName: deviceName,
+		IP:         h.clientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+}
+
+// clientIP is the address the request came from. Behind trusted proxies it
+// is the last X-Forwarded-For entry they didn't add themselves: the client
+// can put anything in the header, so entries left of that are never used.
+func (h *Handler) clientIP(r *http.Request) string {
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	ip := remote.Addr().Unmap()
+	if !h.trustedProxy(ip) {
+		return ip.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = hop.Unmap()
+		if !h.trustedProxy(ip) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+func (h *Handler) trustedProxy(ip netip.Addr) bool {
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLocked answers with 429 and Retry-After if err is a lockout.
+func writeLocked(w http.ResponseWriter, err error) bool {
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(locked.RetryAfter.Seconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":       "account_locked",
+		"message":     "Too many failed attempts. Please try again later.",
+		"retry_after": retryAfter, // Seconds
+	})
+	return true
+}
+
+// writeError sends a JSON error body with a machine-readable code.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   code,
+		"message": message,
+	})
 }
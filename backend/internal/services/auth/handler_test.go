@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	handler := NewHandler(nil, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"Direct", "203.0.113.7:5123", nil, "203.0.113.7"},
+		{"Forged Header From Client", "203.0.113.7:5123", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"Behind Proxy", "10.0.0.2:443", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"Forged Entry Behind Proxy", "10.0.0.2:443", []string{"1.2.3.4, 203.0.113.7"}, "203.0.113.7"},
+		{"Proxy Chain", "10.0.0.2:443", []string{"203.0.113.7, 10.0.0.9"}, "203.0.113.7"},
+		{"Split Headers", "10.0.0.2:443", []string{"1.2.3.4", "203.0.113.7"}, "203.0.113.7"},
+		{"Proxy Without Header", "10.0.0.2:443", nil, "10.0.0.2"},
+		{"Garbage Entry", "10.0.0.2:443", []string{"203.0.113.7, not-an-ip"}, "10.0.0.2"},
+		{"IPv6", "[2001:db8::1]:443", nil, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/auth/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			assert.Equal(t, tt.want, handler.clientIP(r))
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrIdentityNotFound  = errors.New("identity not found")
+	ErrOIDCStateNotFound = errors.New("oidc state not found")
+)
+
+// IdentityRepository persists external (OpenID Connect) logins and the
+// state of logins in progress.
+type IdentityRepository interface {
+	GetIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *UserIdentity) error
+	TouchIdentity(ctx context.Context, id int, at time.Time) error
+	ListIdentities(ctx context.Context, userID int) ([]*UserIdentity, error)
+
+	CreateState(ctx context.Context, state *OIDCState) error
+	GetState(ctx context.Context, stateHash string) (*OIDCState, error)
+	// ConsumeState marks a state as used. It returns false if it was already used.
+	ConsumeState(ctx context.Context, stateHash string) (bool, error)
+}
+
+type MySQLIdentityRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLIdentityRepository(db *sql.DB) *MySQLIdentityRepository {
+	return &MySQLIdentityRepository{db: db}
+}
+
+const identityColumns = `id, user_id, provider, subject, email, created_at, last_login_at`
+
+func (r *MySQLIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM keepsy_user_identities WHERE provider = ? AND subject = ?`
+	identity, err := scanIdentity(r.db.QueryRowContext(ctx, query, provider, subject))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	return identity, nil
+}
+
+func (r *MySQLIdentityRepository) CreateIdentity(ctx context.Context, identity *UserIdentity) error {
+	query := `
+		INSERT INTO keepsy_user_identities (user_id, provider, subject, email, created_at, last_login_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	identity.CreatedAt = time.Now()
+	identity.LastLoginAt = identity.CreatedAt
+
+	result, err := r.db.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt, identity.LastLoginAt)
+	if err != nil {
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	identity.ID = int(id)
+	return nil
+}
+
+func (r *MySQLIdentityRepository) TouchIdentity(ctx context.Context, id int, at time.Time) error {
+	query := `UPDATE keepsy_user_identities SET last_login_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, at, id); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLIdentityRepository) ListIdentities(ctx context.Context, userID int) ([]*UserIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM keepsy_user_identities WHERE user_id = ? ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*UserIdentity
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (r *MySQLIdentityRepository) CreateState(ctx context.Context, state *OIDCState) error {
+	query := `
+		INSERT INTO keepsy_oidc_states (state_hash, provider, code_verifier, nonce, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	state.CreatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.ExpiresAt, state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create oidc state: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLIdentityRepository) GetState(ctx context.Context, stateHash string) (*OIDCState, error) {
+	query := `
+		SELECT state_hash, provider, code_verifier, nonce, expires_at, consumed_at, created_at
+		FROM keepsy_oidc_states WHERE state_hash = ?
+	`
+	var state OIDCState
+	err := r.db.QueryRowContext(ctx, query, stateHash).Scan(
+		&state.StateHash, &state.Provider, &state.CodeVerifier, &state.Nonce,
+		&state.ExpiresAt, &state.ConsumedAt, &state.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOIDCStateNotFound
+		}
+		return nil, fmt.Errorf("failed to get oidc state: %w", err)
+	}
+	return &state, nil
+}
+
+func (r *MySQLIdentityRepository) ConsumeState(ctx context.Context, stateHash string) (bool, error) {
+	query := `UPDATE keepsy_oidc_states SET consumed_at = ? WHERE state_hash = ? AND consumed_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), stateHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume oidc state: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume oidc state: %w", err)
+	}
+	return n == 1, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanIdentity(row rowScanner) (*UserIdentity, error) {
+	var identity UserIdentity
+	var email sql.NullString
+	err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &email, &identity.CreatedAt, &identity.LastLoginAt)
+	if err != nil {
+		return nil, err
+	}
+	identity.Email = email.String
+	return &identity, nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrTooManyRequests = errors.New("too many requests, try again later")
+
+// RequestLoginCode texts a one-time login code to a verified phone number.
+func (s *service) RequestLoginCode(ctx context.Context, req LoginCodeRequest) error {
+	if req.Phone == "" {
+		return ErrPhoneRequired
+	}
+
+	user, err := s.userRepo.GetByPhone(ctx, req.Phone)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if user.PhoneVerifiedAt == nil {
+		return ErrIdentifierNotVerified
+	}
+
+	// Every code costs us an SMS, so cap how many can be requested
+	if err := s.checkSendLimit(ctx, user.ID, PurposeLogin); err != nil {
+		return err
+	}
+
+	code, err := s.issueOTP(ctx, user.ID, PurposeLogin, user.Phone)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Your Keepsy login code is %s. It expires in %s. Don't share it with anyone.", code, s.opts.OTPTTL)
+	if err := s.sms.Send(ctx, user.Phone, body); err != nil {
+		return fmt.Errorf("failed to send login code: %w", err)
+	}
+	return nil
+}
+
+// LoginWithCode checks a code from RequestLoginCode and starts a session,
+// exactly like a successful password Login (including the 2FA challenge).
+// Wrong codes count towards the same lockout as wrong passwords.
+func (s *service) LoginWithCode(ctx context.Context, req LoginWithCodeRequest) (*AuthResponse, error) {
+	if req.Phone == "" || req.Code == "" {
+		return nil, errors.New("phone and code are required")
+	}
+
+	if err := s.checkThrottle(ctx, req.Phone, req.IP); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByPhone(ctx, req.Phone)
+	if err != nil {
+		if ferr := s.recordLoginFailure(ctx, nil, req.ClientInfo, req.Phone); ferr != nil {
+			return nil, ferr
+		}
+		return nil, ErrInvalidCode
+	}
+	if user.PhoneVerifiedAt == nil {
+		return nil, ErrIdentifierNotVerified
+	}
+
+	if err := s.checkOTP(ctx, user.ID, PurposeLogin, user.Phone, req.Code); err != nil {
+		if err == ErrInvalidCode || err == ErrTooManyAttempts {
+			if ferr := s.recordLoginFailure(ctx, &user.ID, req.ClientInfo, req.Phone); ferr != nil {
+				return nil, ferr
+			}
+		}
+		return nil, err
+	}
+
+	challenge, err := s.secondFactorChallenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		if err := s.unlock(ctx, user.ID, req.ClientInfo, req.Phone); err != nil {
+			return nil, err
+		}
+		return challenge, nil
+	}
+
+	if err := s.recordLoginSuccess(ctx, user.ID, req.ClientInfo, req.Phone); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, req.ClientInfo)
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated caller, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// UserIDFromContext returns the authenticated user's ID, if any.
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	return p.UserID, true
+}
+
+type Middleware struct {
+	service Service
+}
+
+func NewMiddleware(service Service) *Middleware {
+	return &Middleware{service: service}
+}
+
+// RequireAuth rejects requests without a valid bearer access token and
+// puts the authenticated principal into the request context. Personal access
+// tokens are refused: routes that accept them are registered with RequireScope.
+func (m *Middleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return m.authenticate(func(p *Principal) bool { return p.AccessTokenID == 0 }, next)
+}
+
+// RequireScope is RequireAuth that also accepts personal access tokens
+// carrying scope (or "<resource>:*").
+func (m *Middleware) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return m.authenticate(func(p *Principal) bool { return p.HasScope(scope) }, next)
+}
+
+// RequireAdmin is RequireAuth for users with the admin role. The role is
+// looked up on every request, so revoking it takes effect immediately.
+func (m *Middleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return m.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		admin, err := m.service.IsAdmin(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !admin {
+			writeError(w, http.StatusForbidden, "forbidden", "This requires an admin account.")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (m *Middleware) authenticate(allowed func(*Principal) bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Missing bearer token.")
+			return
+		}
+
+		principal, err := m.service.Authenticate(r.Context(), token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Invalid or expired token.")
+			return
+		}
+		if !allowed(principal) {
+			writeError(w, http.StatusForbidden, "insufficient_scope", "This token can't be used for this request.")
+			return
+		}
+
+		next(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -1,6 +1,10 @@
 package auth
 
-import "time"
+import (
+	"keepsy-backend/internal/users"
+	"strings"
+	"time"
+)
 
 type UserCredentials struct {
 	UserID       int       `json:"user_id"`
@@ -13,9 +17,289 @@ type RegisterRequest struct {
 	Email    string `json:"email"`
 	Phone    string `json:"phone,omitempty"`
 	Password string `json:"password"`
+	ClientInfo
 }
 
 type LoginRequest struct {
 	Identifier string `json:"identifier"` // Email or Phone
 	Password   string `json:"password"`
+	ClientInfo
+}
+
+// ClientInfo describes the device a session is opened from.
+type ClientInfo struct {
+	DeviceName string `json:"device_name,omitempty"`
+	IP         string `json:"-"` // From request
+	UserAgent  string `json:"-"` // From request
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// AuthResponse is returned by Register and Login. When the user has two-factor
+// authentication on, Login returns only SecondFactor and no user or tokens.
+type AuthResponse struct {
+	User *users.User `json:"user,omitempty"`
+	*TokenPair
+	SecondFactor *SecondFactorChallenge `json:"second_factor,omitempty"`
+}
+
+// Principal is the authenticated caller attached to the request context.
+// Exactly one of SessionID and AccessTokenID is set.
+type Principal struct {
+	UserID        int
+	SessionID     string
+	AccessTokenID int
+	Scopes        []string // Only for access tokens, sessions may do everything
+}
+
+// HasScope reports whether the caller may use a route that requires scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p.AccessTokenID == 0 {
+		return true
+	}
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, s := range p.Scopes {
+		if s == scope || s == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"-"`
+	DeviceName string     `json:"device_name,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current"` // True for the session making the request
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+	ClientInfo
+}
+
+// PasswordReset is a single-use reset token. Only the sha256 of the token is stored.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type OTPPurpose string
+
+const (
+	PurposeVerifyEmail OTPPurpose = "verify_email"
+	PurposeVerifyPhone OTPPurpose = "verify_phone"
+	PurposeLogin       OTPPurpose = "login"
+)
+
+// OTP is a short numeric code sent by email or SMS. Only its hash is stored.
+type OTP struct {
+	ID         int
+	UserID     int
+	Purpose    OTPPurpose
+	Target     string // Email or phone the code was sent to
+	CodeHash   string
+	Attempts   int
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type EmailVerificationRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code,omitempty"` // Empty to (re)send a code
+}
+
+type PhoneVerificationRequest struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code,omitempty"` // Empty to (re)send a code
+}
+
+type LoginCodeRequest struct {
+	Phone string `json:"phone"`
+}
+
+type LoginWithCodeRequest struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+	ClientInfo
+}
+
+type ThrottleScope string
+
+const (
+	ThrottleIdentifier ThrottleScope = "identifier"
+	ThrottleIP         ThrottleScope = "ip"
+)
+
+// LoginThrottle tracks recent failed logins for one identifier or IP.
+type LoginThrottle struct {
+	Scope         ThrottleScope
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+type SecurityEventType string
+
+const (
+	EventLoginSucceeded  SecurityEventType = "login_succeeded"
+	EventAccountLocked   SecurityEventType = "account_locked"
+	EventAccountUnlocked SecurityEventType = "account_unlocked"
+
+	EventTwoFactorEnabled  SecurityEventType = "two_factor_enabled"
+	EventTwoFactorDisabled SecurityEventType = "two_factor_disabled"
+	EventRecoveryCodeUsed  SecurityEventType = "recovery_code_used"
+
+	EventAccessTokenCreated SecurityEventType = "access_token_created"
+	EventAccessTokenRevoked SecurityEventType = "access_token_revoked"
+)
+
+type SecurityEvent struct {
+	ID         int
+	UserID     *int // Nil when the identifier doesn't match an account
+	Type       SecurityEventType
+	Identifier string
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// TOTPCredential is a user's authenticator app secret.
+type TOTPCredential struct {
+	UserID       int
+	Secret       string     // Base32
+	ConfirmedAt  *time.Time // Nil while enrollment is pending
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// TOTPSetup is shown once when enrolling, as text and as a QR code of URI.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// SecondFactorRequest carries an authenticator code, or a recovery code in its place.
+type SecondFactorRequest struct {
+	Code string `json:"code"`
+	ClientInfo
+}
+
+// RecoveryCodes are returned in clear text only when generated.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// SecondFactorChallenge is returned by Login instead of tokens for users with 2FA.
+// The token is exchanged for a session together with a code.
+type SecondFactorChallenge struct {
+	Token     string   `json:"token"`
+	Methods   []string `json:"methods"`
+	ExpiresIn int      `json:"expires_in"` // Seconds
+}
+
+type SecondFactorLoginRequest struct {
+	Token string `json:"token"`
+	Code  string `json:"code"` // Authenticator or recovery code
+	ClientInfo
+}
+
+// UserIdentity links an account to a login at an external OpenID Connect provider.
+type UserIdentity struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"-"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"-"` // The provider's stable user ID ("sub")
+	Email       string    `json:"email,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// OIDCState remembers a started provider login until its callback. Only the
+// sha256 of the state parameter is stored.
+type OIDCState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string // PKCE, never leaves the server
+	Nonce        string
+	ExpiresAt    time.Time
+	ConsumedAt   *time.Time
+	CreatedAt    time.Time
+}
+
+// OIDCAuthorization is where the client sends the user to sign in with a provider.
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// OIDCLoginRequest carries the parameters the provider redirected back with.
+type OIDCLoginRequest struct {
+	Provider string `json:"-"` // From path
+	Code     string `json:"code"`
+	State    string `json:"state"`
+	ClientInfo
+}
+
+// AccessTokenInfo describes a personal access token. Only the sha256 of the token is stored.
+type AccessTokenInfo struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // First characters of the token, to tell tokens apart
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // Nil for tokens that don't expire
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAccessTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`               // e.g. ["products:read", "bills:write", "reminders:*"]
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Optional
+	ClientInfo
+}
+
+// CreatedAccessToken is returned once, on creation. The token can't be shown again.
+type CreatedAccessToken struct {
+	*AccessTokenInfo
+	Token string `json:"token"`
 }
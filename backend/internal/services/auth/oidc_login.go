@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/users"
+	"strings"
+	"time"
+)
+
+// oidcStateTTL is how long the user has to finish signing in at the provider.
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider    = errors.New("unknown identity provider")
+	ErrInvalidOIDCState   = errors.New("invalid or expired login state")
+	ErrOIDCEmailRequired  = errors.New("identity provider did not return a verified email")
+	ErrAccountNotLinkable = errors.New("an account with this email exists but its email is not verified")
+)
+
+// StartOIDCLogin prepares a provider login: it stores the PKCE verifier and
+// nonce under a fresh state and returns the URL to send the user to.
+func (s *service) StartOIDCLogin(ctx context.Context, providerName string) (*OIDCAuthorization, error) {
+	provider, ok := s.opts.OIDCProviders[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.CreateState(ctx, &OIDCState{
+		StateHash:    hashResetToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, pkceChallenge(verifier), nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCAuthorization{AuthorizationURL: authURL, State: state}, nil
+}
+
+// LoginWithOIDC finishes a provider login. The external identity is matched to
+// a linked account first, then to an account with the same verified email,
+// and otherwise a new account is created.
+func (s *service) LoginWithOIDC(ctx context.Context, req OIDCLoginRequest) (*AuthResponse, error) {
+	provider, ok := s.opts.OIDCProviders[req.Provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if req.Code == "" || req.State == "" {
+		return nil, ErrInvalidOIDCState
+	}
+
+	// 1. The state must be one we issued for this provider, and only once
+	stateHash := hashResetToken(req.State)
+	state, err := s.identityRepo.GetState(ctx, stateHash)
+	if err != nil {
+		if err == ErrOIDCStateNotFound {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if state.Provider != req.Provider || state.ConsumedAt != nil || !time.Now().Before(state.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	consumed, err := s.identityRepo.ConsumeState(ctx, stateHash)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrInvalidOIDCState
+	}
+
+	// 2. Trade the code for an ID token and verify it
+	rawIDToken, err := provider.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Find or create the account
+	user, err := s.oidcUser(ctx, req.Provider, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Same as any other login from here on
+	challenge, err := s.secondFactorChallenge(ctx, user)
+	if err != nil || challenge != nil {
+		return challenge, err
+	}
+
+	if err := s.recordLoginSuccess(ctx, user.ID, req.ClientInfo, req.Provider+":"+claims.Subject); err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, user, req.ClientInfo)
+}
+
+func (s *service) ListIdentities(ctx context.Context, userID int) ([]*UserIdentity, error) {
+	return s.identityRepo.ListIdentities(ctx, userID)
+}
+
+func (s *service) oidcUser(ctx context.Context, provider string, claims *IDTokenClaims) (*users.User, error) {
+	identity, err := s.identityRepo.GetIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		// Best effort, only informational
+		_ = s.identityRepo.TouchIdentity(ctx, identity.ID, time.Now())
+		return s.userRepo.GetByID(ctx, identity.UserID)
+	}
+	if err != ErrIdentityNotFound {
+		return nil, err
+	}
+
+	// Without a verified email we'd have no safe way to match or create an account
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		// Anyone could have registered an unverified address. Linking to such an
+		// account would let them keep a password on the provider user's account.
+		if user.EmailVerifiedAt == nil {
+			return nil, ErrAccountNotLinkable
+		}
+	case err.Error() == "user not found":
+		if user, err = s.createOIDCUser(ctx, claims); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.identityRepo.CreateIdentity(ctx, &UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createOIDCUser creates a password-less account. A password can be added
+// later through the forgot password flow.
+func (s *service) createOIDCUser(ctx context.Context, claims *IDTokenClaims) (*users.User, error) {
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	userUUID, err := users.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &users.User{
+		UUID:  userUUID,
+		Name:  name,
+		Email: claims.Email,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// The provider has verified the address for us
+	if err := s.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	verifiedAt := time.Now()
+	user.EmailVerifiedAt = &verifiedAt
+	return user, nil
+}
+
+// pkceChallenge derives the S256 code challenge for a verifier (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
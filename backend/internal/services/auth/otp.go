@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const otpDigits = 6
+
+var (
+	ErrInvalidCode     = errors.New("invalid or expired code")
+	ErrTooManyAttempts = errors.New("too many attempts, try again later")
+)
+
+// issueOTP stores a new code for the user and returns it in clear text for delivery.
+func (s *service) issueOTP(ctx context.Context, userID int, purpose OTPPurpose, target string) (string, error) {
+	code, err := generateCode(otpDigits)
+	if err != nil {
+		return "", err
+	}
+
+	// Codes are short-lived, so the cheapest cost is plenty
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.MinCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash code: %w", err)
+	}
+
+	otp := &OTP{
+		UserID:    userID,
+		Purpose:   purpose,
+		Target:    target,
+		CodeHash:  string(hash),
+		ExpiresAt: time.Now().Add(s.opts.OTPTTL),
+	}
+	if err := s.otpRepo.Create(ctx, otp); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// sendLimit returns how many codes for purpose may be sent per window. The
+// window also bounds the wrong guesses, so a new code doesn't bring new
+// attempts.
+func (s *service) sendLimit(purpose OTPPurpose) (int, time.Duration) {
+	if purpose == PurposeLogin {
+		return s.opts.LoginCodeLimit, s.opts.LoginCodeWindow
+	}
+	return s.opts.VerifyCodeLimit, s.opts.VerifyCodeWindow
+}
+
+// checkSendLimit returns ErrTooManyRequests once the user has been sent the
+// most codes for purpose allowed in the window.
+func (s *service) checkSendLimit(ctx context.Context, userID int, purpose OTPPurpose) error {
+	limit, window := s.sendLimit(purpose)
+	sent, err := s.otpRepo.CountSince(ctx, userID, purpose, time.Now().Add(-window))
+	if err != nil {
+		return err
+	}
+	if sent >= limit {
+		return ErrTooManyRequests
+	}
+	return nil
+}
+
+// checkOTP verifies and consumes the latest code for the user and purpose.
+// Guesses count towards the attempt limit across all codes for the purpose
+// in the send window, not just this one. Each guess reserves its attempt
+// before the code is compared, so parallel guesses can't exceed the limit.
+func (s *service) checkOTP(ctx context.Context, userID int, purpose OTPPurpose, target, code string) error {
+	otp, err := s.otpRepo.GetLatest(ctx, userID, purpose)
+	if err != nil {
+		if err == ErrOTPNotFound {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	if otp.Target != target || !time.Now().Before(otp.ExpiresAt) {
+		return ErrInvalidCode
+	}
+	_, window := s.sendLimit(purpose)
+	since := time.Now().Add(-window)
+	if otp.CreatedAt.Before(since) {
+		since = otp.CreatedAt
+	}
+	reserved, err := s.otpRepo.ReserveAttempt(ctx, otp, since, s.opts.OTPMaxAttempts)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return ErrTooManyAttempts
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(otp.CodeHash), []byte(code)) != nil {
+		return ErrInvalidCode
+	}
+
+	consumed, err := s.otpRepo.Consume(ctx, otp.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func generateCode(digits int) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
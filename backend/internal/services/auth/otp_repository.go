@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrOTPNotFound = errors.New("otp not found")
+
+type OTPRepository interface {
+	Create(ctx context.Context, otp *OTP) error
+	// GetLatest returns the most recent unconsumed code for the user and purpose.
+	GetLatest(ctx context.Context, userID int, purpose OTPPurpose) (*OTP, error)
+	// ReserveAttempt counts a guess on the code before it is checked. It
+	// returns false, without counting it, if the user's codes for the
+	// purpose issued since the given time already have max attempts between
+	// them. Concurrent reservations for the same user are serialized.
+	ReserveAttempt(ctx context.Context, otp *OTP, since time.Time, max int) (bool, error)
+	// Consume marks a code as used and gives back the attempt reserved for
+	// the right guess. It returns false if it was already used.
+	Consume(ctx context.Context, id int) (bool, error)
+	// CountSince returns how many codes were issued to the user for purpose since the given time.
+	CountSince(ctx context.Context, userID int, purpose OTPPurpose, since time.Time) (int, error)
+}
+
+type MySQLOTPRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLOTPRepository(db *sql.DB) *MySQLOTPRepository {
+	return &MySQLOTPRepository{db: db}
+}
+
+func (r *MySQLOTPRepository) Create(ctx context.Context, otp *OTP) error {
+	query := `
+		INSERT INTO keepsy_otp_codes (user_id, purpose, target, code_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	otp.CreatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx, query, otp.UserID, otp.Purpose, otp.Target, otp.CodeHash, otp.ExpiresAt, otp.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create otp: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	otp.ID = int(id)
+	return nil
+}
+
+func (r *MySQLOTPRepository) GetLatest(ctx context.Context, userID int, purpose OTPPurpose) (*OTP, error) {
+	query := `
+		SELECT id, user_id, purpose, target, code_hash, attempts, expires_at, consumed_at, created_at
+		FROM keepsy_otp_codes
+		WHERE user_id = ? AND purpose = ? AND consumed_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+	var otp OTP
+	err := r.db.QueryRowContext(ctx, query, userID, purpose).Scan(
+		&otp.ID, &otp.UserID, &otp.Purpose, &otp.Target, &otp.CodeHash,
+		&otp.Attempts, &otp.ExpiresAt, &otp.ConsumedAt, &otp.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOTPNotFound
+		}
+		return nil, fmt.Errorf("failed to get otp: %w", err)
+	}
+	return &otp, nil
+}
+
+func (r *MySQLOTPRepository) ReserveAttempt(ctx context.Context, otp *OTP, since time.Time, max int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the codes makes parallel guesses wait for each other's count
+	query := `
+		SELECT COALESCE(SUM(attempts), 0) FROM keepsy_otp_codes
+		WHERE user_id = ? AND purpose = ? AND (created_at >= ? OR id = ?)
+		FOR UPDATE`
+	var attempts int
+	if err := tx.QueryRowContext(ctx, query, otp.UserID, otp.Purpose, since, otp.ID).Scan(&attempts); err != nil {
+		return false, fmt.Errorf("failed to count otp attempts: %w", err)
+	}
+	if attempts >= max {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE keepsy_otp_codes SET attempts = attempts + 1 WHERE id = ?`, otp.ID); err != nil {
+		return false, fmt.Errorf("failed to record otp attempt: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+func (r *MySQLOTPRepository) Consume(ctx context.Context, id int) (bool, error) {
+	query := `UPDATE keepsy_otp_codes SET consumed_at = ?, attempts = GREATEST(attempts - 1, 0) WHERE id = ? AND consumed_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume otp: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume otp: %w", err)
+	}
+	return n == 1, nil
+}
+
+func (r *MySQLOTPRepository) CountSince(ctx context.Context, userID int, purpose OTPPurpose, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM keepsy_otp_codes WHERE user_id = ? AND purpose = ? AND created_at >= ?`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID, purpose, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count otps: %w", err)
+	}
+	return count, nil
+}
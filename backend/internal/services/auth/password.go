@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+
+// ChangePassword replaces the caller's password after checking the current one.
+// Every other session is logged out; the one making the change stays signed in.
+func (s *service) ChangePassword(ctx context.Context, principal *Principal, req ChangePasswordRequest) error {
+	if req.CurrentPassword == "" {
+		return ErrPasswordRequired
+	}
+	if err := s.opts.PasswordPolicy.Validate(req.NewPassword); err != nil {
+		return err
+	}
+
+	hash, err := s.authRepo.GetPasswordHash(ctx, principal.UserID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.CurrentPassword)); err != nil {
+		return ErrInvalidCurrentPassword
+	}
+
+	newHash, err := s.hashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.authRepo.UpdatePassword(ctx, principal.UserID, newHash); err != nil {
+		return err
+	}
+
+	return s.sessionRepo.RevokeOthers(ctx, principal.UserID, principal.SessionID)
+}
+
+func (s *service) hashPassword(password string) (string, error) {
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), s.opts.BcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hashedBytes), nil
+}
+
+// rehashIfNeeded upgrades a stored hash made with a lower cost than we use now.
+// It is called right after a successful login, the only time we see the plain password.
+func (s *service) rehashIfNeeded(ctx context.Context, userID int, hash, password string) error {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil || cost >= s.opts.BcryptCost {
+		return nil
+	}
+
+	newHash, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.authRepo.UpdatePassword(ctx, userID, newHash)
+}
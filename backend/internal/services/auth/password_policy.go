@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// bcrypt ignores everything past 72 bytes, so longer passwords would silently be truncated.
+const maxPasswordBytes = 72
+
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// PasswordPolicy decides which new passwords are acceptable.
+// The zero value only rejects empty and over-long passwords.
+type PasswordPolicy struct {
+	MinLength int
+	denylist  map[string]struct{}
+}
+
+// LoadPasswordPolicy builds a policy with a common-password denylist read from path
+// (one password per line, '#' comments allowed). An empty path disables the denylist.
+func LoadPasswordPolicy(minLength int, path string) (PasswordPolicy, error) {
+	policy := PasswordPolicy{MinLength: minLength}
+	if path == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return policy, fmt.Errorf("failed to open password denylist: %w", err)
+	}
+	defer f.Close()
+
+	policy.denylist = make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return policy, fmt.Errorf("failed to read password denylist: %w", err)
+	}
+
+	return policy, nil
+}
+
+// Validate returns an error wrapping ErrWeakPassword if password is not allowed.
+func (p PasswordPolicy) Validate(password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: must be at most %d bytes", ErrWeakPassword, maxPasswordBytes)
+	}
+	if _, found := p.denylist[strings.ToLower(password)]; found {
+		return fmt.Errorf("%w: too common, choose something harder to guess", ErrWeakPassword)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/services/mail"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	ErrEmailRequired     = errors.New("email is required")
+)
+
+// ForgotPassword emails a reset link if the address belongs to an account.
+// It never reports whether the account exists.
+func (s *service) ForgotPassword(ctx context.Context, req ForgotPasswordRequest) error {
+	if req.Email == "" {
+		return ErrEmailRequired
+	}
+
+	// Unverified addresses might not belong to the account holder
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil || user.EmailVerifiedAt == nil {
+		return nil
+	}
+
+	token, tokenHash, err := newResetToken()
+	if err != nil {
+		return err
+	}
+
+	reset := &PasswordReset{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.opts.PasswordResetTTL),
+	}
+	if err := s.authRepo.CreatePasswordReset(ctx, reset); err != nil {
+		return err
+	}
+
+	link := s.opts.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Keepsy password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+			user.Name, s.opts.PasswordResetTTL, link),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	if req.Token == "" {
+		return ErrInvalidResetToken
+	}
+	if err := s.opts.PasswordPolicy.Validate(req.NewPassword); err != nil {
+		return err
+	}
+
+	reset, err := s.authRepo.GetPasswordResetByHash(ctx, hashResetToken(req.Token))
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	if reset.UsedAt != nil || !time.Now().Before(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	consumed, err := s.authRepo.ConsumePasswordReset(ctx, reset.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+
+	hash, err := s.hashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authRepo.UpdatePassword(ctx, reset.UserID, hash); err != nil {
+		return err
+	}
+
+	// A successful reset proves ownership, so lift any login lockout
+	user, err := s.userRepo.GetByID(ctx, reset.UserID)
+	if err != nil {
+		return err
+	}
+	for _, identifier := range []string{user.Email, user.Phone} {
+		if identifier == "" {
+			continue
+		}
+		if err := s.unlock(ctx, user.ID, req.ClientInfo, identifier); err != nil {
+			return err
+		}
+	}
+
+	// Whoever had the old password shouldn't stay logged in
+	return s.sessionRepo.RevokeAllByUserID(ctx, reset.UserID)
+}
+
+// newResetToken returns a random URL-safe token and the hash we store for it.
+func newResetToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashResetToken(token), nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
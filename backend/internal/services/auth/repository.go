@@ -3,12 +3,24 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ErrNoPassword is returned for accounts created through an identity
+// provider, which have no password until they set one with a reset.
+var ErrNoPassword = errors.New("no password set, use forgot password to create one")
+
 type Repository interface {
 	CreatePassword(ctx context.Context, userID int, hash string) error
 	GetPasswordHash(ctx context.Context, userID int) (string, error)
+	UpdatePassword(ctx context.Context, userID int, hash string) error
+
+	CreatePasswordReset(ctx context.Context, reset *PasswordReset) error
+	GetPasswordResetByHash(ctx context.Context, tokenHash string) (*PasswordReset, error)
+	// ConsumePasswordReset marks a reset as used. It returns false if it was already used.
+	ConsumePasswordReset(ctx context.Context, id int) (bool, error)
 }
 
 type MySQLRepository struct {
@@ -34,9 +46,68 @@ func (r *MySQLRepository) GetPasswordHash(ctx context.Context, userID int) (stri
 	err := r.db.QueryRowContext(ctx, query, userID).Scan(&hash)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("credentials not found")
+			return "", ErrNoPassword
 		}
 		return "", fmt.Errorf("failed to get password hash: %w", err)
 	}
 	return hash, nil
 }
+
+// UpdatePassword sets a new password hash. Accounts created through an
+// identity provider have no credentials yet, so one is created if needed.
+func (r *MySQLRepository) UpdatePassword(ctx context.Context, userID int, hash string) error {
+	query := `
+		INSERT INTO keepsy_user_credentials (user_id, password_hash) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE password_hash = VALUES(password_hash)
+	`
+	if _, err := r.db.ExecContext(ctx, query, userID, hash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	query := `INSERT INTO keepsy_password_resets (user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)`
+	reset.CreatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx, query, reset.UserID, reset.TokenHash, reset.ExpiresAt, reset.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	reset.ID = int(id)
+	return nil
+}
+
+func (r *MySQLRepository) GetPasswordResetByHash(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	query := `SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM keepsy_password_resets WHERE token_hash = ?`
+	var reset PasswordReset
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&reset.ID, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.UsedAt, &reset.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, fmt.Errorf("failed to get password reset: %w", err)
+	}
+	return &reset, nil
+}
+
+func (r *MySQLRepository) ConsumePasswordReset(ctx context.Context, id int) (bool, error) {
+	// The used_at guard makes this safe against two concurrent resets with the same token
+	query := `UPDATE keepsy_password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume password reset: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume password reset: %w", err)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SecurityRepository persists login throttling state and the security event log.
+type SecurityRepository interface {
+	// GetThrottle returns nil when the key has no recorded failures.
+	GetThrottle(ctx context.Context, scope ThrottleScope, key string) (*LoginThrottle, error)
+	// RecordFailure counts a failed attempt and returns the new failure count.
+	// Failures older than window are forgotten first.
+	RecordFailure(ctx context.Context, scope ThrottleScope, key string, at time.Time, window time.Duration) (int, error)
+	LockThrottle(ctx context.Context, scope ThrottleScope, key string, until time.Time) error
+	ResetThrottle(ctx context.Context, scope ThrottleScope, key string) error
+
+	RecordEvent(ctx context.Context, event *SecurityEvent) error
+}
+
+type MySQLSecurityRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLSecurityRepository(db *sql.DB) *MySQLSecurityRepository {
+	return &MySQLSecurityRepository{db: db}
+}
+
+func (r *MySQLSecurityRepository) GetThrottle(ctx context.Context, scope ThrottleScope, key string) (*LoginThrottle, error) {
+	query := `
+		SELECT scope, throttle_key, failures, last_failure_at, locked_until
+		FROM keepsy_login_throttles WHERE scope = ? AND throttle_key = ?
+	`
+	var t LoginThrottle
+	err := r.db.QueryRowContext(ctx, query, scope, key).Scan(&t.Scope, &t.Key, &t.Failures, &t.LastFailureAt, &t.LockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *MySQLSecurityRepository) RecordFailure(ctx context.Context, scope ThrottleScope, key string, at time.Time, window time.Duration) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO keepsy_login_throttles (scope, throttle_key, failures, last_failure_at)
+		VALUES (?, ?, 1, ?)
+		ON DUPLICATE KEY UPDATE
+			failures = IF(last_failure_at < ?, 1, failures + 1),
+			last_failure_at = VALUES(last_failure_at)
+	`
+	if _, err := tx.ExecContext(ctx, query, scope, key, at, at.Add(-window)); err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	var failures int
+	countQuery := `SELECT failures FROM keepsy_login_throttles WHERE scope = ? AND throttle_key = ?`
+	if err := tx.QueryRowContext(ctx, countQuery, scope, key).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("failed to read login failures: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *MySQLSecurityRepository) LockThrottle(ctx context.Context, scope ThrottleScope, key string, until time.Time) error {
+	query := `UPDATE keepsy_login_throttles SET locked_until = ? WHERE scope = ? AND throttle_key = ?`
+	if _, err := r.db.ExecContext(ctx, query, until, scope, key); err != nil {
+		return fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSecurityRepository) ResetThrottle(ctx context.Context, scope ThrottleScope, key string) error {
+	query := `DELETE FROM keepsy_login_throttles WHERE scope = ? AND throttle_key = ?`
+	if _, err := r.db.ExecContext(ctx, query, scope, key); err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSecurityRepository) RecordEvent(ctx context.Context, event *SecurityEvent) error {
+	query := `
+		INSERT INTO keepsy_security_events (user_id, event_type, identifier, ip, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	event.CreatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx, query, event.UserID, event.Type, event.Identifier, event.IP, event.UserAgent, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record security event: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	event.ID = int(id)
+	return nil
+}
@@ -4,41 +4,130 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"keepsy-backend/internal/services/mail"
+	"keepsy-backend/internal/services/sms"
 	"keepsy-backend/internal/users"
+	"time"
 
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Service interface {
-	Register(ctx context.Context, req RegisterRequest) (*users.User, error)
-	Login(ctx context.Context, req LoginRequest) (*users.User, error)
+	Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error)
+	Login(ctx context.Context, req LoginRequest) (*AuthResponse, error)
+	// RequestLoginCode and LoginWithCode are the passwordless alternative to Login.
+	RequestLoginCode(ctx context.Context, req LoginCodeRequest) error
+	LoginWithCode(ctx context.Context, req LoginWithCodeRequest) (*AuthResponse, error)
+	Refresh(ctx context.Context, req RefreshRequest) (*TokenPair, error)
+	// Authenticate resolves a bearer access token to the calling principal.
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+	// IsAdmin reports whether the user currently has the admin role.
+	IsAdmin(ctx context.Context, userID int) (bool, error)
+
+	ListSessions(ctx context.Context, principal *Principal) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID string) error
+	RevokeAllSessions(ctx context.Context, userID int) error
+
+	ChangePassword(ctx context.Context, principal *Principal, req ChangePasswordRequest) error
+	ForgotPassword(ctx context.Context, req ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) error
+
+	SendEmailVerification(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, email, code string) error
+	SendPhoneVerification(ctx context.Context, phone string) error
+	VerifyPhone(ctx context.Context, phone, code string) error
+
+	// Two-factor authentication with an authenticator app (TOTP)
+	TwoFactorStatus(ctx context.Context, userID int) (*TwoFactorStatus, error)
+	EnrollTOTP(ctx context.Context, userID int) (*TOTPSetup, error)
+	ConfirmTOTP(ctx context.Context, userID int, req SecondFactorRequest) (*RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, userID int, req SecondFactorRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int, req SecondFactorRequest) (*RecoveryCodes, error)
+	// LoginSecondFactor exchanges the challenge returned by Login and a code for a session.
+	LoginSecondFactor(ctx context.Context, req SecondFactorLoginRequest) (*AuthResponse, error)
+
+	// OpenID Connect sign-in (authorization code + PKCE)
+	StartOIDCLogin(ctx context.Context, provider string) (*OIDCAuthorization, error)
+	LoginWithOIDC(ctx context.Context, req OIDCLoginRequest) (*AuthResponse, error)
+	ListIdentities(ctx context.Context, userID int) ([]*UserIdentity, error)
+
+	// Personal access tokens for scripts. The token itself is only returned on creation.
+	CreateAccessToken(ctx context.Context, userID int, req CreateAccessTokenRequest) (*CreatedAccessToken, error)
+	ListAccessTokens(ctx context.Context, userID int) ([]*AccessTokenInfo, error)
+	RevokeAccessToken(ctx context.Context, userID, id int) error
+}
+
+// Options holds tunable auth settings.
+type Options struct {
+	BcryptCost     int // Stored hashes below this cost are upgraded on login
+	PasswordPolicy PasswordPolicy
+
+	PasswordResetURL string // Link sent in reset emails, the token is appended as ?token=
+	PasswordResetTTL time.Duration
+
+	OTPTTL         time.Duration
+	OTPMaxAttempts int // Wrong guesses allowed per user and purpose within the code window
+
+	LoginCodeLimit  int // Login codes a user may request per LoginCodeWindow
+	LoginCodeWindow time.Duration
+
+	VerifyCodeLimit  int // Email or phone verification codes sent per VerifyCodeWindow
+	VerifyCodeWindow time.Duration
+
+	LoginMaxFailures      int           // Failed password logins per identifier before lockout
+	LoginMaxFailuresPerIP int           // Failed password logins per IP before lockout
+	LoginBackoffBase      time.Duration // Wait after the first failure, doubled for each further one
+	LoginLockout          time.Duration // First lockout, doubled for each further failure
+
+	TOTPIssuer      string        // Account name prefix shown in authenticator apps
+	SecondFactorTTL time.Duration // How long a login challenge can be completed
+
+	OIDCProviders map[string]*OIDCProvider // Keyed by provider name
 }
 
 type service struct {
-	authRepo Repository
-	userRepo users.Repository
+	authRepo        Repository
+	userRepo        users.Repository
+	sessionRepo     SessionRepository
+	otpRepo         OTPRepository
+	securityRepo    SecurityRepository
+	twoFactorRepo   TwoFactorRepository
+	identityRepo    IdentityRepository
+	accessTokenRepo AccessTokenRepository
+	tokens          *TokenManager
+	mailer          mail.Mailer
+	sms             sms.Sender
+	opts            Options
 }
 
-func NewService(authRepo Repository, userRepo users.Repository) Service {
+func NewService(authRepo Repository, userRepo users.Repository, sessionRepo SessionRepository, otpRepo OTPRepository, securityRepo SecurityRepository, twoFactorRepo TwoFactorRepository, identityRepo IdentityRepository, accessTokenRepo AccessTokenRepository, tokens *TokenManager, mailer mail.Mailer, smsSender sms.Sender, opts Options) Service {
 	return &service{
-		authRepo: authRepo,
-		userRepo: userRepo,
+		authRepo:        authRepo,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		otpRepo:         otpRepo,
+		securityRepo:    securityRepo,
+		twoFactorRepo:   twoFactorRepo,
+		identityRepo:    identityRepo,
+		accessTokenRepo: accessTokenRepo,
+		tokens:          tokens,
+		mailer:          mailer,
+		sms:             smsSender,
+		opts:            opts,
 	}
 }
 
-func (s *service) Register(ctx context.Context, req RegisterRequest) (*users.User, error) {
-	if req.Password == "" {
-		return nil, errors.New("password is required")
+func (s *service) Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error) {
+	if err := s.opts.PasswordPolicy.Validate(req.Password); err != nil {
+		return nil, err
 	}
 
-	// 1. Generate UUID v5 (Namespace: Name + Phone)
-	// Using a custom namespace UUID or just URL namespace for now.
-	// Since we want it unique based on Name+Phone, we can concat them.
-	// Note: Phone is optional, but for v5 we need data.
-	// If phone is empty, we just use Name.
-	data := req.Name + req.Phone
-	userUUID := uuid.NewSHA1(uuid.NameSpaceURL, []byte(data)).String()
+	// 1. Generate a random UUID. It used to be derived from Name + Phone,
+	// which collided for people sharing a name.
+	userUUID, err := users.NewUUID()
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. Create User
 	user := &users.User{
@@ -53,26 +142,41 @@ func (s *service) Register(ctx context.Context, req RegisterRequest) (*users.Use
 	}
 
 	// 2. Hash Password
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hash, err := s.hashPassword(req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return nil, err
 	}
 
 	// 3. Save Credentials
-	if err := s.authRepo.CreatePassword(ctx, user.ID, string(hashedBytes)); err != nil {
+	if err := s.authRepo.CreatePassword(ctx, user.ID, hash); err != nil {
 		return nil, fmt.Errorf("failed to save credentials: %w", err)
 	}
 
-	return user, nil
+	// 4. Send verification codes. Best effort, the user can ask for a new code later.
+	_ = s.sendEmailCode(ctx, user)
+	if user.Phone != "" {
+		_ = s.sendPhoneCode(ctx, user)
+	}
+
+	return s.startSession(ctx, user, req.ClientInfo)
 }
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrPasswordRequired = errors.New("password is required")
+	ErrPhoneRequired    = errors.New("phone is required")
+)
 
-func (s *service) Login(ctx context.Context, req LoginRequest) (*users.User, error) {
+func (s *service) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
 	if req.Identifier == "" || req.Password == "" {
 		return nil, errors.New("identifier and password are required")
 	}
 
+	// 0. Refuse early while the identifier or IP is locked out
+	if err := s.checkThrottle(ctx, req.Identifier, req.IP); err != nil {
+		return nil, err
+	}
+
 	// 1. Find User by Email OR Phone
 	var user *users.User
 	var err error
@@ -83,6 +187,10 @@ func (s *service) Login(ctx context.Context, req LoginRequest) (*users.User, err
 		// Try by Phone
 		user, err = s.userRepo.GetByPhone(ctx, req.Identifier)
 		if err != nil {
+			// Unknown identifiers count as failures too, so they can't be probed quickly
+			if ferr := s.recordLoginFailure(ctx, nil, req.ClientInfo, req.Identifier); ferr != nil {
+				return nil, ferr
+			}
 			// If both fail, and it's because user wasn't found, return specific error
 			if err.Error() == "user not found" {
 				return nil, ErrUserNotFound
@@ -99,8 +207,38 @@ func (s *service) Login(ctx context.Context, req LoginRequest) (*users.User, err
 
 	// 3. Compare Password
 	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)); err != nil {
+		if ferr := s.recordLoginFailure(ctx, &user.ID, req.ClientInfo, req.Identifier); ferr != nil {
+			return nil, ferr
+		}
 		return nil, errors.New("invalid credentials")
 	}
 
-	return user, nil
+	// 4. Only verified identifiers can be used to sign in
+	if !identifierVerified(user, req.Identifier) {
+		return nil, ErrIdentifierNotVerified
+	}
+
+	// 5. Upgrade the stored hash if the configured cost was raised. Best effort,
+	// the old hash still works.
+	_ = s.rehashIfNeeded(ctx, user.ID, hash, req.Password)
+
+	// 6. Users with 2FA get a challenge instead of a session. The password was
+	// right, so its failures are cleared, but the login only counts once the
+	// second factor checks out.
+	challenge, err := s.secondFactorChallenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		if err := s.unlock(ctx, user.ID, req.ClientInfo, req.Identifier); err != nil {
+			return nil, err
+		}
+		return challenge, nil
+	}
+
+	if err := s.recordLoginSuccess(ctx, user.ID, req.ClientInfo, req.Identifier); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, req.ClientInfo)
 }
@@ -3,8 +3,20 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
+	"keepsy-backend/internal/services/auth/fakeidp"
+	"keepsy-backend/internal/services/mail"
+	"keepsy-backend/internal/services/sms"
 	"keepsy-backend/internal/users"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -26,6 +38,190 @@ func (m *MockAuthRepo) GetPasswordHash(ctx context.Context, userID int) (string,
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockAuthRepo) UpdatePassword(ctx context.Context, userID int, hash string) error {
+	args := m.Called(ctx, userID, hash)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepo) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	args := m.Called(ctx, reset)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepo) GetPasswordResetByHash(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PasswordReset), args.Error(1)
+}
+
+func (m *MockAuthRepo) ConsumePasswordReset(ctx context.Context, id int) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockOTPRepo struct {
+	mock.Mock
+}
+
+func (m *MockOTPRepo) Create(ctx context.Context, otp *OTP) error {
+	args := m.Called(ctx, otp)
+	return args.Error(0)
+}
+
+func (m *MockOTPRepo) GetLatest(ctx context.Context, userID int, purpose OTPPurpose) (*OTP, error) {
+	args := m.Called(ctx, userID, purpose)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*OTP), args.Error(1)
+}
+
+func (m *MockOTPRepo) ReserveAttempt(ctx context.Context, otp *OTP, since time.Time, max int) (bool, error) {
+	args := m.Called(ctx, otp.ID, since, max)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOTPRepo) Consume(ctx context.Context, id int) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOTPRepo) CountSince(ctx context.Context, userID int, purpose OTPPurpose, since time.Time) (int, error) {
+	args := m.Called(ctx, userID, purpose, since)
+	return args.Int(0), args.Error(1)
+}
+
+type MockAccessTokenRepo struct {
+	mock.Mock
+}
+
+func (m *MockAccessTokenRepo) Create(ctx context.Context, token *AccessTokenInfo) error {
+	args := m.Called(ctx, token)
+	if args.Error(0) == nil {
+		token.ID = 1
+	}
+	return args.Error(0)
+}
+
+func (m *MockAccessTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*AccessTokenInfo, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*AccessTokenInfo), args.Error(1)
+}
+
+func (m *MockAccessTokenRepo) ListActiveByUserID(ctx context.Context, userID int) ([]*AccessTokenInfo, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*AccessTokenInfo), args.Error(1)
+}
+
+func (m *MockAccessTokenRepo) Touch(ctx context.Context, id int, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func (m *MockAccessTokenRepo) Revoke(ctx context.Context, userID, id int) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockSecurityRepo struct {
+	mock.Mock
+}
+
+func (m *MockSecurityRepo) GetThrottle(ctx context.Context, scope ThrottleScope, key string) (*LoginThrottle, error) {
+	args := m.Called(ctx, scope, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*LoginThrottle), args.Error(1)
+}
+
+func (m *MockSecurityRepo) RecordFailure(ctx context.Context, scope ThrottleScope, key string, at time.Time, window time.Duration) (int, error) {
+	args := m.Called(ctx, scope, key, at, window)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSecurityRepo) LockThrottle(ctx context.Context, scope ThrottleScope, key string, until time.Time) error {
+	args := m.Called(ctx, scope, key, until)
+	return args.Error(0)
+}
+
+func (m *MockSecurityRepo) ResetThrottle(ctx context.Context, scope ThrottleScope, key string) error {
+	args := m.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (m *MockSecurityRepo) RecordEvent(ctx context.Context, event *SecurityEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(ctx context.Context, msg mail.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+type MockSessionRepo struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepo) Create(ctx context.Context, session *Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepo) GetByID(ctx context.Context, id string) (*Session, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Session), args.Error(1)
+}
+
+func (m *MockSessionRepo) ListActiveByUserID(ctx context.Context, userID int) ([]*Session, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Session), args.Error(1)
+}
+
+func (m *MockSessionRepo) Touch(ctx context.Context, id string, seenAt time.Time) error {
+	args := m.Called(ctx, id, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepo) Renew(ctx context.Context, id string, expiresAt time.Time) error {
+	args := m.Called(ctx, id, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepo) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepo) RevokeAllByUserID(ctx context.Context, userID int) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepo) RevokeOthers(ctx context.Context, userID int, keepID string) error {
+	args := m.Called(ctx, userID, keepID)
+	return args.Error(0)
+}
+
 type MockUserRepo struct {
 	mock.Mock
 }
@@ -71,11 +267,158 @@ func (m *MockUserRepo) GetByUUID(ctx context.Context, uuid string) (*users.User,
 	return args.Get(0).(*users.User), args.Error(1)
 }
 
+func (m *MockUserRepo) MarkEmailVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) MarkPhoneVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateProfile(ctx context.Context, user *users.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateAvatar(ctx context.Context, id int, avatarURL string) error {
+	args := m.Called(ctx, id, avatarURL)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateRole(ctx context.Context, id int, role string) error {
+	args := m.Called(ctx, id, role)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateUUID(ctx context.Context, id int, uuid string) error {
+	args := m.Called(ctx, id, uuid)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) ListAfter(ctx context.Context, afterID, limit int) ([]*users.User, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*users.User), args.Error(1)
+}
+
+type MockTwoFactorRepo struct {
+	mock.Mock
+}
+
+func (m *MockTwoFactorRepo) GetTOTP(ctx context.Context, userID int) (*TOTPCredential, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*TOTPCredential), args.Error(1)
+}
+
+func (m *MockTwoFactorRepo) SaveTOTP(ctx context.Context, cred *TOTPCredential) error {
+	args := m.Called(ctx, cred)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepo) ConfirmTOTP(ctx context.Context, userID int) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepo) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTwoFactorRepo) DeleteTOTP(ctx context.Context, userID int) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	args := m.Called(ctx, userID, hashes)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	args := m.Called(ctx, userID, hash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTwoFactorRepo) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+type MockIdentityRepo struct {
+	mock.Mock
+}
+
+func (m *MockIdentityRepo) GetIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	args := m.Called(ctx, provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UserIdentity), args.Error(1)
+}
+
+func (m *MockIdentityRepo) CreateIdentity(ctx context.Context, identity *UserIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepo) TouchIdentity(ctx context.Context, id int, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepo) ListIdentities(ctx context.Context, userID int) ([]*UserIdentity, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*UserIdentity), args.Error(1)
+}
+
+func (m *MockIdentityRepo) CreateState(ctx context.Context, state *OIDCState) error {
+	args := m.Called(ctx, state)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepo) GetState(ctx context.Context, stateHash string) (*OIDCState, error) {
+	args := m.Called(ctx, stateHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*OIDCState), args.Error(1)
+}
+
+func (m *MockIdentityRepo) ConsumeState(ctx context.Context, stateHash string) (bool, error) {
+	args := m.Called(ctx, stateHash)
+	return args.Bool(0), args.Error(1)
+}
+
+// noTwoFactor is a TwoFactorRepository for users without 2FA.
+func noTwoFactor() *MockTwoFactorRepo {
+	m := new(MockTwoFactorRepo)
+	m.On("GetTOTP", mock.Anything, mock.Anything).Return(nil, ErrTOTPNotFound).Maybe()
+	return m
+}
+
+func newTestTokens() *TokenManager {
+	return NewTokenManager("test-secret", 15*time.Minute, time.Hour)
+}
+
 func TestRegister(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		mockAuthRepo := new(MockAuthRepo)
 		mockUserRepo := new(MockUserRepo)
-		service := NewService(mockAuthRepo, mockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		mockMailer := new(MockMailer)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), mockMailer, nil, Options{VerifyCodeLimit: 3, VerifyCodeWindow: time.Hour})
 
 		req := RegisterRequest{
 			Name:     "Test User",
@@ -84,64 +427,134 @@ func TestRegister(t *testing.T) {
 		}
 
 		mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(u *users.User) bool {
-			return u.Name == req.Name && u.Email == req.Email
+			return u.Name == req.Name && u.Email == req.Email && u.UUID != "" && !users.IsLegacyUUID(u.UUID)
 		})).Return(nil)
 
 		mockAuthRepo.On("CreatePassword", mock.Anything, 1, mock.AnythingOfType("string")).Return(nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.AnythingOfType("*auth.Session")).Return(nil)
+		mockOTPRepo.On("CountSince", mock.Anything, 1, PurposeVerifyEmail, mock.Anything).Return(0, nil)
+		mockOTPRepo.On("Create", mock.Anything, mock.MatchedBy(func(o *OTP) bool {
+			return o.Purpose == PurposeVerifyEmail && o.Target == req.Email
+		})).Return(nil)
+		mockMailer.On("Send", mock.Anything, mock.MatchedBy(func(m mail.Message) bool {
+			return m.To == req.Email
+		})).Return(nil)
 
-		user, err := service.Register(context.Background(), req)
+		resp, err := service.Register(context.Background(), req)
 
 		assert.NoError(t, err)
-		assert.NotNil(t, user)
-		assert.Equal(t, 1, user.ID)
+		assert.NotNil(t, resp)
+		assert.Equal(t, 1, resp.User.ID)
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.NotEmpty(t, resp.RefreshToken)
 
 		mockUserRepo.AssertExpectations(t)
 		mockAuthRepo.AssertExpectations(t)
+		mockOTPRepo.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("SameNameDistinctUUIDs", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		mockMailer := new(MockMailer)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), mockMailer, nil, Options{VerifyCodeLimit: 3, VerifyCodeWindow: time.Hour})
+
+		var uuids []string
+		mockUserRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			uuids = append(uuids, args.Get(1).(*users.User).UUID)
+		}).Return(nil)
+		mockAuthRepo.On("CreatePassword", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockOTPRepo.On("CountSince", mock.Anything, mock.Anything, PurposeVerifyEmail, mock.Anything).Return(0, nil)
+		mockOTPRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockMailer.On("Send", mock.Anything, mock.Anything).Return(nil)
+
+		for _, email := range []string{"anil@example.com", "anil.k@example.com"} {
+			_, err := service.Register(context.Background(), RegisterRequest{Name: "Anil", Email: email, Password: "password123"})
+			assert.NoError(t, err)
+		}
+
+		assert.Len(t, uuids, 2)
+		assert.NotEqual(t, uuids[0], uuids[1])
 	})
 
 	t.Run("MissingPassword", func(t *testing.T) {
-		service := NewService(nil, nil)
+		service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, Options{})
 		_, err := service.Register(context.Background(), RegisterRequest{Name: "User", Email: "e"})
 		assert.Error(t, err)
 		assert.Equal(t, "password is required", err.Error())
 	})
 }
 
+var loginOpts = Options{
+	LoginMaxFailures:      5,
+	LoginMaxFailuresPerIP: 20,
+	LoginBackoffBase:      time.Second,
+	LoginLockout:          15 * time.Minute,
+}
+
 func TestLogin(t *testing.T) {
 	t.Run("SuccessEmail", func(t *testing.T) {
 		mockAuthRepo := new(MockAuthRepo)
 		mockUserRepo := new(MockUserRepo)
-		service := NewService(mockAuthRepo, mockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
 
 		password := "password123"
 		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
-		user := &users.User{ID: 1, Email: "test@example.com"}
+		verifiedAt := time.Now()
+		user := &users.User{ID: 1, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}
 
 		mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
 		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.MatchedBy(func(s *Session) bool {
+			return s.UserID == 1 && s.DeviceName == "Pixel 8" && s.IP == "10.0.0.1"
+		})).Return(nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventLoginSucceeded && *e.UserID == 1 && e.IP == "10.0.0.1"
+		})).Return(nil)
 
-		loggedInUser, err := service.Login(context.Background(), LoginRequest{
+		resp, err := service.Login(context.Background(), LoginRequest{
 			Identifier: "test@example.com",
 			Password:   password,
+			ClientInfo: ClientInfo{DeviceName: "Pixel 8", IP: "10.0.0.1"},
 		})
 
 		assert.NoError(t, err)
-		assert.Equal(t, user, loggedInUser)
+		assert.Equal(t, user, resp.User)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.NotEmpty(t, resp.AccessToken)
+		mockSecurityRepo.AssertExpectations(t)
 	})
 
 	t.Run("WrongPassword", func(t *testing.T) {
 		mockAuthRepo := new(MockAuthRepo)
 		mockUserRepo := new(MockUserRepo)
-		service := NewService(mockAuthRepo, mockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
 
 		password := "password123"
 		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
-		user := &users.User{ID: 1, Email: "test@example.com"}
+		verifiedAt := time.Now()
+		user := &users.User{ID: 1, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}
 
 		mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
 		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "test@example.com").Return(nil, nil)
+		mockSecurityRepo.On("RecordFailure", mock.Anything, ThrottleIdentifier, "test@example.com", mock.Anything, maxLockout).Return(2, nil)
+		// Second failure: wait 2s before the next attempt
+		mockSecurityRepo.On("LockThrottle", mock.Anything, ThrottleIdentifier, "test@example.com", mock.MatchedBy(func(until time.Time) bool {
+			d := time.Until(until)
+			return d > time.Second && d <= 2*time.Second
+		})).Return(nil)
 
 		_, err := service.Login(context.Background(), LoginRequest{
 			Identifier: "test@example.com",
@@ -150,22 +563,1645 @@ func TestLogin(t *testing.T) {
 
 		assert.Error(t, err)
 		assert.Equal(t, "invalid credentials", err.Error())
+		mockSecurityRepo.AssertExpectations(t)
+		mockSecurityRepo.AssertNotCalled(t, "RecordEvent")
 	})
 
-	t.Run("UserNotFound", func(t *testing.T) {
+	t.Run("LockoutAtThreshold", func(t *testing.T) {
 		mockAuthRepo := new(MockAuthRepo)
 		mockUserRepo := new(MockUserRepo)
-		service := NewService(mockAuthRepo, mockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
 
-		mockUserRepo.On("GetByEmail", mock.Anything, "unknown@example.com").Return(nil, errors.New("user not found"))
-		mockUserRepo.On("GetByPhone", mock.Anything, "unknown@example.com").Return(nil, errors.New("user not found"))
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		user := &users.User{ID: 1, Email: "test@example.com"}
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordFailure", mock.Anything, ThrottleIdentifier, "test@example.com", mock.Anything, mock.Anything).Return(5, nil)
+		mockSecurityRepo.On("RecordFailure", mock.Anything, ThrottleIP, "10.0.0.1", mock.Anything, mock.Anything).Return(5, nil)
+		mockSecurityRepo.On("LockThrottle", mock.Anything, ThrottleIdentifier, "test@example.com", mock.MatchedBy(func(until time.Time) bool {
+			return time.Until(until) > 14*time.Minute
+		})).Return(nil)
+		mockSecurityRepo.On("LockThrottle", mock.Anything, ThrottleIP, "10.0.0.1", mock.Anything).Return(nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventAccountLocked && e.Identifier == "test@example.com"
+		})).Return(nil).Once()
 
 		_, err := service.Login(context.Background(), LoginRequest{
-			Identifier: "unknown@example.com",
-			Password:   "password",
+			Identifier: "test@example.com",
+			Password:   "wrongpassword",
+			ClientInfo: ClientInfo{IP: "10.0.0.1"},
 		})
 
 		assert.Error(t, err)
-		assert.Equal(t, ErrUserNotFound, err)
+		mockSecurityRepo.AssertExpectations(t)
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(nil, mockUserRepo, nil, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
+
+		until := time.Now().Add(10 * time.Minute)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "test@example.com").Return(&LoginThrottle{Failures: 5, LockedUntil: &until}, nil)
+
+		_, err := service.Login(context.Background(), LoginRequest{
+			Identifier: "Test@Example.com ",
+			Password:   "password123",
+		})
+
+		var locked *LockedError
+		assert.True(t, errors.As(err, &locked))
+		assert.True(t, errors.Is(err, ErrAccountLocked))
+		assert.InDelta(t, (10 * time.Minute).Seconds(), locked.RetryAfter.Seconds(), 2)
+		// The password is never even checked
+		mockUserRepo.AssertNotCalled(t, "GetByEmail")
 	})
+
+	t.Run("SuccessUnlocks", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
+
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		verifiedAt := time.Now()
+		user := &users.User{ID: 1, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}
+
+		// Lockout has expired, but the failures are still on record
+		expired := time.Now().Add(-time.Minute)
+		throttle := &LoginThrottle{Failures: 6, LockedUntil: &expired}
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "test@example.com").Return(throttle, nil)
+		mockSecurityRepo.On("ResetThrottle", mock.Anything, ThrottleIdentifier, "test@example.com").Return(nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventAccountUnlocked
+		})).Return(nil).Once()
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventLoginSucceeded
+		})).Return(nil).Once()
+
+		_, err := service.Login(context.Background(), LoginRequest{
+			Identifier: "test@example.com",
+			Password:   "password123",
+		})
+
+		assert.NoError(t, err)
+		mockSecurityRepo.AssertExpectations(t)
+	})
+
+	t.Run("UnverifiedPhone", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
+
+		password := "password123"
+		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+		// Email verified, phone not
+		verifiedAt := time.Now()
+		user := &users.User{ID: 1, Email: "test@example.com", Phone: "9999999999", EmailVerifiedAt: &verifiedAt}
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "9999999999").Return(nil, errors.New("user not found"))
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "9999999999").Return(nil, nil)
+
+		_, err := service.Login(context.Background(), LoginRequest{
+			Identifier: "9999999999",
+			Password:   password,
+		})
+
+		assert.Equal(t, ErrIdentifierNotVerified, err)
+		mockSessionRepo.AssertNotCalled(t, "Create")
+	})
+
+	t.Run("UserNotFound", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "unknown@example.com").Return(nil, errors.New("user not found"))
+		mockUserRepo.On("GetByPhone", mock.Anything, "unknown@example.com").Return(nil, errors.New("user not found"))
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "unknown@example.com").Return(nil, nil)
+		mockSecurityRepo.On("RecordFailure", mock.Anything, ThrottleIdentifier, "unknown@example.com", mock.Anything, mock.Anything).Return(1, nil)
+		mockSecurityRepo.On("LockThrottle", mock.Anything, ThrottleIdentifier, "unknown@example.com", mock.Anything).Return(nil)
+
+		_, err := service.Login(context.Background(), LoginRequest{
+			Identifier: "unknown@example.com",
+			Password:   "password",
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+}
+
+// memorySecurityRepo keeps throttles the way the MySQL repository does,
+// with a clock that tests can move forward.
+type memorySecurityRepo struct {
+	SecurityRepository
+	throttles map[string]*LoginThrottle
+}
+
+func (r *memorySecurityRepo) GetThrottle(ctx context.Context, scope ThrottleScope, key string) (*LoginThrottle, error) {
+	return r.throttles[string(scope)+":"+key], nil
+}
+
+func (r *memorySecurityRepo) RecordFailure(ctx context.Context, scope ThrottleScope, key string, at time.Time, window time.Duration) (int, error) {
+	throttle := r.throttles[string(scope)+":"+key]
+	if throttle == nil {
+		throttle = &LoginThrottle{Scope: scope, Key: key}
+		r.throttles[string(scope)+":"+key] = throttle
+	}
+	if throttle.LastFailureAt.Before(at.Add(-window)) {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = at
+	return throttle.Failures, nil
+}
+
+func (r *memorySecurityRepo) LockThrottle(ctx context.Context, scope ThrottleScope, key string, until time.Time) error {
+	r.throttles[string(scope)+":"+key].LockedUntil = &until
+	return nil
+}
+
+func (r *memorySecurityRepo) RecordEvent(ctx context.Context, event *SecurityEvent) error {
+	return nil
+}
+
+// elapse moves every recorded time back by d, as if d had passed.
+func (r *memorySecurityRepo) elapse(d time.Duration) {
+	for _, throttle := range r.throttles {
+		throttle.LastFailureAt = throttle.LastFailureAt.Add(-d)
+		if throttle.LockedUntil != nil {
+			until := throttle.LockedUntil.Add(-d)
+			throttle.LockedUntil = &until
+		}
+	}
+}
+
+func TestLoginLockoutDoubles(t *testing.T) {
+	mockAuthRepo := new(MockAuthRepo)
+	mockUserRepo := new(MockUserRepo)
+	securityRepo := &memorySecurityRepo{throttles: map[string]*LoginThrottle{}}
+	service := NewService(mockAuthRepo, mockUserRepo, nil, nil, securityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&users.User{ID: 1, Email: "test@example.com"}, nil)
+	mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+
+	// failAfterWait waits out the current lock, fails a login and returns the new lock
+	failAfterWait := func() time.Duration {
+		if throttle := securityRepo.throttles["identifier:test@example.com"]; throttle != nil {
+			securityRepo.elapse(time.Until(*throttle.LockedUntil) + time.Second)
+		}
+		_, err := service.Login(context.Background(), LoginRequest{Identifier: "test@example.com", Password: "wrongpassword"})
+		assert.EqualError(t, err, "invalid credentials")
+		return time.Until(*securityRepo.throttles["identifier:test@example.com"].LockedUntil)
+	}
+
+	for i := 1; i < loginOpts.LoginMaxFailures; i++ {
+		failAfterWait()
+	}
+	first := failAfterWait()
+	second := failAfterWait()
+
+	assert.InDelta(t, loginOpts.LoginLockout.Seconds(), first.Seconds(), 2)
+	// Failing again once the lockout is over doubles it instead of starting over
+	assert.InDelta(t, (2 * loginOpts.LoginLockout).Seconds(), second.Seconds(), 2)
+}
+
+func activeSession(id string, userID int) *Session {
+	return &Session{
+		ID:         id,
+		UserID:     userID,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(1, "s1")
+		mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(activeSession("s1", 1), nil)
+		mockSessionRepo.On("Renew", mock.Anything, "s1", mock.Anything).Return(nil)
+
+		refreshed, err := service.Refresh(context.Background(), RefreshRequest{RefreshToken: pair.RefreshToken})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, refreshed.AccessToken)
+		assert.NotEqual(t, pair.RefreshToken, refreshed.RefreshToken)
+		mockSessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("AccessTokenRejected", func(t *testing.T) {
+		tokens := newTestTokens()
+		service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(1, "s1")
+
+		_, err := service.Refresh(context.Background(), RefreshRequest{RefreshToken: pair.AccessToken})
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+
+	t.Run("SessionRevoked", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(1, "s1")
+		revoked := activeSession("s1", 1)
+		now := time.Now()
+		revoked.RevokedAt = &now
+		mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(revoked, nil)
+
+		_, err := service.Refresh(context.Background(), RefreshRequest{RefreshToken: pair.RefreshToken})
+		assert.Equal(t, ErrInvalidToken, err)
+		mockSessionRepo.AssertNotCalled(t, "Renew")
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(42, "s1")
+		mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(activeSession("s1", 42), nil)
+
+		principal, err := service.Authenticate(context.Background(), pair.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, 42, principal.UserID)
+		assert.Equal(t, "s1", principal.SessionID)
+		mockSessionRepo.AssertNotCalled(t, "Touch")
+	})
+
+	t.Run("TouchesStaleSession", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(42, "s1")
+		session := activeSession("s1", 42)
+		session.LastSeenAt = time.Now().Add(-time.Hour)
+		mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(session, nil)
+		mockSessionRepo.On("Touch", mock.Anything, "s1", mock.Anything).Return(nil)
+
+		_, err := service.Authenticate(context.Background(), pair.AccessToken)
+		assert.NoError(t, err)
+		mockSessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("SessionOfOtherUser", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(42, "s1")
+		mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(activeSession("s1", 7), nil)
+
+		_, err := service.Authenticate(context.Background(), pair.AccessToken)
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		tokens := newTestTokens()
+		service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(42, "s1")
+		tokens.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+		_, err := service.Authenticate(context.Background(), pair.AccessToken)
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		tokens := newTestTokens()
+		service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+		pair, _ := tokens.Issue(42, "s1")
+		other := NewTokenManager("other-secret", time.Minute, time.Hour)
+		forged, _ := other.Issue(1, "s1")
+
+		_, err := service.Authenticate(context.Background(), forged.AccessToken)
+		assert.Equal(t, ErrInvalidToken, err)
+
+		_, err = service.Authenticate(context.Background(), pair.AccessToken+"x")
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	t.Run("ListMarksCurrent", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, Options{})
+
+		mockSessionRepo.On("ListActiveByUserID", mock.Anything, 1).Return([]*Session{
+			activeSession("s1", 1), activeSession("s2", 1),
+		}, nil)
+
+		sessions, err := service.ListSessions(context.Background(), &Principal{UserID: 1, SessionID: "s2"})
+		assert.NoError(t, err)
+		assert.False(t, sessions[0].Current)
+		assert.True(t, sessions[1].Current)
+	})
+
+	t.Run("RevokeOwnSession", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, Options{})
+
+		mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(activeSession("s1", 1), nil)
+		mockSessionRepo.On("Revoke", mock.Anything, "s1").Return(nil)
+
+		err := service.RevokeSession(context.Background(), 1, "s1")
+		assert.NoError(t, err)
+		mockSessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("RevokeOtherUsersSession", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, Options{})
+
+		mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(activeSession("s1", 2), nil)
+
+		err := service.RevokeSession(context.Background(), 1, "s1")
+		assert.Equal(t, ErrSessionNotFound, err)
+		mockSessionRepo.AssertNotCalled(t, "Revoke")
+	})
+
+	t.Run("RevokeAll", func(t *testing.T) {
+		mockSessionRepo := new(MockSessionRepo)
+		service := NewService(nil, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, Options{})
+
+		mockSessionRepo.On("RevokeAllByUserID", mock.Anything, 1).Return(nil)
+
+		err := service.RevokeAllSessions(context.Background(), 1)
+		assert.NoError(t, err)
+		mockSessionRepo.AssertExpectations(t)
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	opts := Options{PasswordResetURL: "keepsy://reset-password", PasswordResetTTL: time.Hour}
+
+	t.Run("SendsResetLink", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockMailer := new(MockMailer)
+		service := NewService(mockAuthRepo, mockUserRepo, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), mockMailer, nil, opts)
+
+		verifiedAt := time.Now()
+		user := &users.User{ID: 1, Name: "Anil", Email: "anil@example.com", EmailVerifiedAt: &verifiedAt}
+		mockUserRepo.On("GetByEmail", mock.Anything, "anil@example.com").Return(user, nil)
+
+		var storedHash string
+		mockAuthRepo.On("CreatePasswordReset", mock.Anything, mock.MatchedBy(func(r *PasswordReset) bool {
+			storedHash = r.TokenHash
+			return r.UserID == 1 && r.ExpiresAt.After(time.Now())
+		})).Return(nil)
+
+		var sent mail.Message
+		mockMailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(mail.Message)
+		}).Return(nil)
+
+		err := service.ForgotPassword(context.Background(), ForgotPasswordRequest{Email: "anil@example.com"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "anil@example.com", sent.To)
+
+		// The emailed token must hash to what we stored, and never be stored raw
+		_, token, found := strings.Cut(sent.Body, "keepsy://reset-password?token=")
+		assert.True(t, found)
+		token = strings.Fields(token)[0]
+		assert.Equal(t, storedHash, hashResetToken(token))
+		assert.NotEqual(t, token, storedHash)
+	})
+
+	t.Run("UnknownEmailIsSilent", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockMailer := new(MockMailer)
+		service := NewService(nil, mockUserRepo, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), mockMailer, nil, opts)
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, errors.New("user not found"))
+
+		err := service.ForgotPassword(context.Background(), ForgotPasswordRequest{Email: "nobody@example.com"})
+
+		assert.NoError(t, err)
+		mockMailer.AssertNotCalled(t, "Send")
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, loginOpts)
+
+		reset := &PasswordReset{ID: 5, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+		mockAuthRepo.On("GetPasswordResetByHash", mock.Anything, hashResetToken("tok")).Return(reset, nil)
+		mockAuthRepo.On("ConsumePasswordReset", mock.Anything, 5).Return(true, nil)
+		mockAuthRepo.On("UpdatePassword", mock.Anything, 1, mock.MatchedBy(func(hash string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) == nil
+		})).Return(nil)
+		mockSessionRepo.On("RevokeAllByUserID", mock.Anything, 1).Return(nil)
+		mockUserRepo.On("GetByID", mock.Anything, 1).Return(&users.User{ID: 1, Email: "a@example.com"}, nil)
+		locked := time.Now().Add(time.Hour)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "a@example.com").Return(&LoginThrottle{Failures: 7, LockedUntil: &locked}, nil)
+		mockSecurityRepo.On("ResetThrottle", mock.Anything, ThrottleIdentifier, "a@example.com").Return(nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventAccountUnlocked && *e.UserID == 1
+		})).Return(nil)
+
+		err := service.ResetPassword(context.Background(), ResetPasswordRequest{Token: "tok", NewPassword: "new-password"})
+
+		assert.NoError(t, err)
+		mockAuthRepo.AssertExpectations(t)
+		mockSessionRepo.AssertExpectations(t)
+		mockSecurityRepo.AssertExpectations(t)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		service := NewService(mockAuthRepo, nil, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, Options{})
+
+		reset := &PasswordReset{ID: 5, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}
+		mockAuthRepo.On("GetPasswordResetByHash", mock.Anything, hashResetToken("tok")).Return(reset, nil)
+
+		err := service.ResetPassword(context.Background(), ResetPasswordRequest{Token: "tok", NewPassword: "new-password"})
+
+		assert.Equal(t, ErrInvalidResetToken, err)
+		mockAuthRepo.AssertNotCalled(t, "UpdatePassword")
+	})
+
+	t.Run("AlreadyUsed", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		service := NewService(mockAuthRepo, nil, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, Options{})
+
+		reset := &PasswordReset{ID: 5, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+		mockAuthRepo.On("GetPasswordResetByHash", mock.Anything, hashResetToken("tok")).Return(reset, nil)
+		// Lost the race against a concurrent reset
+		mockAuthRepo.On("ConsumePasswordReset", mock.Anything, 5).Return(false, nil)
+
+		err := service.ResetPassword(context.Background(), ResetPasswordRequest{Token: "tok", NewPassword: "new-password"})
+
+		assert.Equal(t, ErrInvalidResetToken, err)
+		mockAuthRepo.AssertNotCalled(t, "UpdatePassword")
+	})
+}
+
+func TestVerification(t *testing.T) {
+	opts := Options{OTPTTL: 10 * time.Minute, OTPMaxAttempts: 3, VerifyCodeLimit: 3, VerifyCodeWindow: 15 * time.Minute}
+	codeHash := func(code string) string {
+		hash, _ := bcrypt.GenerateFromPassword([]byte(code), bcrypt.MinCost)
+		return string(hash)
+	}
+
+	t.Run("SendPhoneCode", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		fakeSMS := sms.NewFakeSender()
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, fakeSMS, opts)
+
+		user := &users.User{ID: 1, Phone: "9999999999"}
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockOTPRepo.On("CountSince", mock.Anything, 1, PurposeVerifyPhone, mock.Anything).Return(2, nil)
+
+		var stored *OTP
+		mockOTPRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*OTP)
+		}).Return(nil)
+
+		err := service.SendPhoneVerification(context.Background(), "9999999999")
+
+		assert.NoError(t, err)
+		sent := fakeSMS.Sent()
+		assert.Len(t, sent, 1)
+		assert.Equal(t, "9999999999", sent[0].To)
+
+		// The code in the SMS matches the stored hash
+		code := strings.Fields(strings.TrimPrefix(sent[0].Body, "Your Keepsy verification code is "))[0]
+		code = strings.TrimSuffix(code, ".")
+		assert.Len(t, code, 6)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(stored.CodeHash), []byte(code)))
+		assert.Equal(t, PurposeVerifyPhone, stored.Purpose)
+	})
+
+	t.Run("ResendRateLimited", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		fakeSMS := sms.NewFakeSender()
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, fakeSMS, opts)
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(&users.User{ID: 1, Phone: "9999999999"}, nil)
+		mockOTPRepo.On("CountSince", mock.Anything, 1, PurposeVerifyPhone, mock.Anything).Return(3, nil)
+
+		err := service.SendPhoneVerification(context.Background(), "9999999999")
+
+		assert.Equal(t, ErrTooManyRequests, err)
+		assert.Empty(t, fakeSMS.Sent())
+		mockOTPRepo.AssertNotCalled(t, "Create")
+	})
+
+	t.Run("VerifyEmailSuccess", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		user := &users.User{ID: 1, Email: "a@example.com"}
+		otp := &OTP{ID: 9, UserID: 1, Purpose: PurposeVerifyEmail, Target: "a@example.com", CodeHash: codeHash("123456"), ExpiresAt: time.Now().Add(time.Minute)}
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "a@example.com").Return(user, nil)
+		mockOTPRepo.On("GetLatest", mock.Anything, 1, PurposeVerifyEmail).Return(otp, nil)
+		mockOTPRepo.On("ReserveAttempt", mock.Anything, 9, mock.Anything, 3).Return(true, nil)
+		mockOTPRepo.On("Consume", mock.Anything, 9).Return(true, nil)
+		mockUserRepo.On("MarkEmailVerified", mock.Anything, 1).Return(nil)
+
+		err := service.VerifyEmail(context.Background(), "a@example.com", "123456")
+
+		assert.NoError(t, err)
+		mockUserRepo.AssertExpectations(t)
+		mockOTPRepo.AssertExpectations(t)
+	})
+
+	t.Run("WrongCodeCountsAttempt", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		user := &users.User{ID: 1, Email: "a@example.com"}
+		otp := &OTP{ID: 9, UserID: 1, Target: "a@example.com", CodeHash: codeHash("123456"), ExpiresAt: time.Now().Add(time.Minute)}
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "a@example.com").Return(user, nil)
+		mockOTPRepo.On("GetLatest", mock.Anything, 1, PurposeVerifyEmail).Return(otp, nil)
+		mockOTPRepo.On("ReserveAttempt", mock.Anything, 9, mock.Anything, 3).Return(true, nil)
+
+		err := service.VerifyEmail(context.Background(), "a@example.com", "000000")
+
+		assert.Equal(t, ErrInvalidCode, err)
+		mockOTPRepo.AssertExpectations(t)
+		mockUserRepo.AssertNotCalled(t, "MarkEmailVerified")
+	})
+
+	t.Run("AttemptLimit", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		user := &users.User{ID: 1, Phone: "9999999999"}
+		otp := &OTP{ID: 9, UserID: 1, Target: "9999999999", CodeHash: codeHash("123456"), ExpiresAt: time.Now().Add(time.Minute)}
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockOTPRepo.On("GetLatest", mock.Anything, 1, PurposeVerifyPhone).Return(otp, nil)
+		// The wrong guesses were on earlier codes: a resend doesn't reset them
+		mockOTPRepo.On("ReserveAttempt", mock.Anything, 9, mock.Anything, 3).Return(false, nil)
+
+		// Even the right code is refused once the limit is hit
+		err := service.VerifyPhone(context.Background(), "9999999999", "123456")
+
+		assert.Equal(t, ErrTooManyAttempts, err)
+		mockOTPRepo.AssertNotCalled(t, "Consume")
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		user := &users.User{ID: 1, Phone: "9999999999"}
+		otp := &OTP{ID: 9, UserID: 1, Target: "9999999999", CodeHash: codeHash("123456"), ExpiresAt: time.Now().Add(-time.Second)}
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockOTPRepo.On("GetLatest", mock.Anything, 1, PurposeVerifyPhone).Return(otp, nil)
+
+		err := service.VerifyPhone(context.Background(), "9999999999", "123456")
+
+		assert.Equal(t, ErrInvalidCode, err)
+	})
+}
+
+func TestLoginWithCode(t *testing.T) {
+	opts := loginOpts
+	opts.OTPTTL = 5 * time.Minute
+	opts.OTPMaxAttempts = 3
+	opts.LoginCodeLimit = 3
+	opts.LoginCodeWindow = 15 * time.Minute
+	verifiedAt := time.Now()
+	user := &users.User{ID: 1, Phone: "9999999999", PhoneVerifiedAt: &verifiedAt}
+
+	t.Run("RequestSendsCode", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		fakeSMS := sms.NewFakeSender()
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, fakeSMS, opts)
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockOTPRepo.On("CountSince", mock.Anything, 1, PurposeLogin, mock.Anything).Return(2, nil)
+		mockOTPRepo.On("Create", mock.Anything, mock.MatchedBy(func(o *OTP) bool {
+			return o.Purpose == PurposeLogin && o.Target == "9999999999"
+		})).Return(nil)
+
+		err := service.RequestLoginCode(context.Background(), LoginCodeRequest{Phone: "9999999999"})
+
+		assert.NoError(t, err)
+		assert.Len(t, fakeSMS.Sent(), 1)
+		mockOTPRepo.AssertExpectations(t)
+	})
+
+	t.Run("RequestRateLimited", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		fakeSMS := sms.NewFakeSender()
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, fakeSMS, opts)
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockOTPRepo.On("CountSince", mock.Anything, 1, PurposeLogin, mock.Anything).Return(3, nil)
+
+		err := service.RequestLoginCode(context.Background(), LoginCodeRequest{Phone: "9999999999"})
+
+		assert.Equal(t, ErrTooManyRequests, err)
+		assert.Empty(t, fakeSMS.Sent())
+		mockOTPRepo.AssertNotCalled(t, "Create")
+	})
+
+	t.Run("RequestUnverifiedPhone", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		service := NewService(nil, mockUserRepo, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "8888888888").Return(&users.User{ID: 2, Phone: "8888888888"}, nil)
+
+		err := service.RequestLoginCode(context.Background(), LoginCodeRequest{Phone: "8888888888"})
+
+		assert.Equal(t, ErrIdentifierNotVerified, err)
+	})
+
+	t.Run("LoginSuccess", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(nil, mockUserRepo, mockSessionRepo, mockOTPRepo, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		hash, _ := bcrypt.GenerateFromPassword([]byte("654321"), bcrypt.MinCost)
+		otp := &OTP{ID: 3, UserID: 1, Purpose: PurposeLogin, Target: "9999999999", CodeHash: string(hash), ExpiresAt: time.Now().Add(time.Minute)}
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockOTPRepo.On("GetLatest", mock.Anything, 1, PurposeLogin).Return(otp, nil)
+		mockOTPRepo.On("ReserveAttempt", mock.Anything, 3, mock.Anything, 3).Return(true, nil)
+		mockOTPRepo.On("Consume", mock.Anything, 3).Return(true, nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventLoginSucceeded && *e.UserID == 1 && e.Identifier == "9999999999"
+		})).Return(nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.MatchedBy(func(s *Session) bool {
+			return s.UserID == 1 && s.DeviceName == "Mom's phone"
+		})).Return(nil)
+
+		resp, err := service.LoginWithCode(context.Background(), LoginWithCodeRequest{
+			Phone:      "9999999999",
+			Code:       "654321",
+			ClientInfo: ClientInfo{DeviceName: "Mom's phone"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, user, resp.User)
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.NotEmpty(t, resp.RefreshToken)
+		mockSessionRepo.AssertExpectations(t)
+		mockSecurityRepo.AssertExpectations(t)
+	})
+
+	t.Run("LoginWrongCode", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(nil, mockUserRepo, mockSessionRepo, mockOTPRepo, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		hash, _ := bcrypt.GenerateFromPassword([]byte("654321"), bcrypt.MinCost)
+		otp := &OTP{ID: 3, UserID: 1, Purpose: PurposeLogin, Target: "9999999999", CodeHash: string(hash), ExpiresAt: time.Now().Add(time.Minute)}
+
+		mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(user, nil)
+		mockOTPRepo.On("GetLatest", mock.Anything, 1, PurposeLogin).Return(otp, nil)
+		mockOTPRepo.On("ReserveAttempt", mock.Anything, 3, mock.Anything, 3).Return(true, nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "9999999999").Return(nil, nil)
+		// A wrong code counts like a wrong password
+		mockSecurityRepo.On("RecordFailure", mock.Anything, ThrottleIdentifier, "9999999999", mock.Anything, mock.Anything).Return(1, nil)
+		mockSecurityRepo.On("LockThrottle", mock.Anything, ThrottleIdentifier, "9999999999", mock.Anything).Return(nil)
+
+		_, err := service.LoginWithCode(context.Background(), LoginWithCodeRequest{Phone: "9999999999", Code: "111111"})
+
+		assert.Equal(t, ErrInvalidCode, err)
+		mockSessionRepo.AssertNotCalled(t, "Create")
+		mockSecurityRepo.AssertExpectations(t)
+	})
+
+	t.Run("LoginLocked", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockOTPRepo := new(MockOTPRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(nil, mockUserRepo, nil, mockOTPRepo, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		until := time.Now().Add(10 * time.Minute)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIdentifier, "9999999999").Return(&LoginThrottle{Failures: 5, LockedUntil: &until}, nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, ThrottleIP, "10.0.0.1").Return(nil, nil)
+
+		_, err := service.LoginWithCode(context.Background(), LoginWithCodeRequest{
+			Phone:      "9999999999",
+			Code:       "654321",
+			ClientInfo: ClientInfo{IP: "10.0.0.1"},
+		})
+
+		assert.True(t, errors.Is(err, ErrAccountLocked))
+		// Locked out, so the code isn't even looked at
+		mockOTPRepo.AssertNotCalled(t, "GetLatest")
+	})
+}
+
+// memoryOTPRepo keeps one user's codes in memory. It locks like the MySQL
+// repository does so parallel guesses race the same way.
+type memoryOTPRepo struct {
+	OTPRepository
+	mu   sync.Mutex
+	otps []*OTP
+}
+
+func (r *memoryOTPRepo) GetLatest(ctx context.Context, userID int, purpose OTPPurpose) (*OTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	otp := *r.otps[len(r.otps)-1]
+	return &otp, nil
+}
+
+func (r *memoryOTPRepo) ReserveAttempt(ctx context.Context, otp *OTP, since time.Time, max int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempts := 0
+	for _, o := range r.otps {
+		attempts += o.Attempts
+	}
+	if attempts >= max {
+		return false, nil
+	}
+	for _, o := range r.otps {
+		if o.ID == otp.ID {
+			o.Attempts++
+		}
+	}
+	return true, nil
+}
+
+func TestCheckOTPConcurrentGuesses(t *testing.T) {
+	opts := Options{OTPTTL: 10 * time.Minute, OTPMaxAttempts: 3, VerifyCodeLimit: 3, VerifyCodeWindow: 15 * time.Minute}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	otpRepo := &memoryOTPRepo{otps: []*OTP{{
+		ID: 9, UserID: 1, Purpose: PurposeVerifyPhone, Target: "9999999999", CodeHash: string(hash),
+		ExpiresAt: time.Now().Add(time.Minute), CreatedAt: time.Now(),
+	}}}
+	mockUserRepo := new(MockUserRepo)
+	mockUserRepo.On("GetByPhone", mock.Anything, "9999999999").Return(&users.User{ID: 1, Phone: "9999999999"}, nil)
+	service := NewService(nil, mockUserRepo, nil, otpRepo, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- service.VerifyPhone(context.Background(), "9999999999", fmt.Sprintf("%06d", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	counts := map[error]int{}
+	for err := range errs {
+		counts[err]++
+	}
+	// Only the allowed number of guesses get compared, however they interleave
+	assert.Equal(t, 3, counts[ErrInvalidCode])
+	assert.Equal(t, 17, counts[ErrTooManyAttempts])
+	assert.Equal(t, 3, otpRepo.otps[0].Attempts)
+	mockUserRepo.AssertNotCalled(t, "MarkPhoneVerified")
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	os.WriteFile(path, []byte("# common\nPassword123\n\nqwertyuiop\n"), 0644)
+
+	policy, err := LoadPasswordPolicy(8, path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ErrPasswordRequired, policy.Validate(""))
+	assert.ErrorIs(t, policy.Validate("short"), ErrWeakPassword)
+	assert.ErrorIs(t, policy.Validate("PASSWORD123"), ErrWeakPassword)
+	assert.ErrorIs(t, policy.Validate(strings.Repeat("a", 73)), ErrWeakPassword)
+	assert.NoError(t, policy.Validate("correct horse battery"))
+
+	_, err = LoadPasswordPolicy(8, filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestChangePassword(t *testing.T) {
+	opts := Options{BcryptCost: bcrypt.MinCost, PasswordPolicy: PasswordPolicy{MinLength: 8}}
+	principal := &Principal{UserID: 1, SessionID: "s1"}
+	currentHash, _ := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+
+	t.Run("Success", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		service := NewService(mockAuthRepo, nil, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(currentHash), nil)
+		mockAuthRepo.On("UpdatePassword", mock.Anything, 1, mock.MatchedBy(func(hash string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) == nil
+		})).Return(nil)
+		mockSessionRepo.On("RevokeOthers", mock.Anything, 1, "s1").Return(nil)
+
+		err := service.ChangePassword(context.Background(), principal, ChangePasswordRequest{
+			CurrentPassword: "old-password",
+			NewPassword:     "new-password",
+		})
+
+		assert.NoError(t, err)
+		mockAuthRepo.AssertExpectations(t)
+		mockSessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("WrongCurrentPassword", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		service := NewService(mockAuthRepo, nil, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(currentHash), nil)
+
+		err := service.ChangePassword(context.Background(), principal, ChangePasswordRequest{
+			CurrentPassword: "guess",
+			NewPassword:     "new-password",
+		})
+
+		assert.Equal(t, ErrInvalidCurrentPassword, err)
+		mockAuthRepo.AssertNotCalled(t, "UpdatePassword")
+	})
+
+	t.Run("NoPasswordYet", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		service := NewService(mockAuthRepo, nil, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		// Signed up with an identity provider
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return("", ErrNoPassword)
+
+		err := service.ChangePassword(context.Background(), principal, ChangePasswordRequest{
+			CurrentPassword: "anything",
+			NewPassword:     "new-password",
+		})
+
+		assert.Equal(t, ErrNoPassword, err)
+		mockAuthRepo.AssertNotCalled(t, "UpdatePassword")
+	})
+
+	t.Run("WeakNewPassword", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		service := NewService(mockAuthRepo, nil, nil, nil, nil, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		err := service.ChangePassword(context.Background(), principal, ChangePasswordRequest{
+			CurrentPassword: "old-password",
+			NewPassword:     "short",
+		})
+
+		assert.ErrorIs(t, err, ErrWeakPassword)
+		mockAuthRepo.AssertNotCalled(t, "GetPasswordHash")
+	})
+}
+
+func TestLoginRehash(t *testing.T) {
+	t.Run("UpgradesLowCostHash", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		opts := loginOpts
+		opts.BcryptCost = bcrypt.MinCost + 1
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		oldHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		verifiedAt := time.Now()
+		user := &users.User{ID: 1, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(oldHash), nil)
+		mockAuthRepo.On("UpdatePassword", mock.Anything, 1, mock.MatchedBy(func(hash string) bool {
+			cost, _ := bcrypt.Cost([]byte(hash))
+			return cost == bcrypt.MinCost+1 && bcrypt.CompareHashAndPassword([]byte(hash), []byte("password123")) == nil
+		})).Return(nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.Anything).Return(nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := service.Login(context.Background(), LoginRequest{Identifier: "test@example.com", Password: "password123"})
+
+		assert.NoError(t, err)
+		mockAuthRepo.AssertExpectations(t)
+	})
+
+	t.Run("KeepsCurrentCostHash", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		opts := loginOpts
+		opts.BcryptCost = bcrypt.MinCost
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, noTwoFactor(), nil, nil, newTestTokens(), nil, nil, opts)
+
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		verifiedAt := time.Now()
+		user := &users.User{ID: 1, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}
+
+		mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.Anything).Return(nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := service.Login(context.Background(), LoginRequest{Identifier: "test@example.com", Password: "password123"})
+
+		assert.NoError(t, err)
+		mockAuthRepo.AssertNotCalled(t, "UpdatePassword")
+	})
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 SHA1 test vectors, truncated to 6 digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := totpCode(secret, totpStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+
+	now := time.Unix(1111111109, 0)
+	current := totpStep(now)
+	previous, _ := totpCode(secret, current-1)
+
+	step, ok := matchTOTP(secret, previous, now, 0)
+	assert.True(t, ok, "codes from the previous step are accepted for clock drift")
+	assert.Equal(t, current-1, step)
+
+	_, ok = matchTOTP(secret, previous, now, current-1)
+	assert.False(t, ok, "used steps can't be replayed")
+
+	old, _ := totpCode(secret, current-2)
+	_, ok = matchTOTP(secret, old, now, 0)
+	assert.False(t, ok)
+
+	uri := totpURI("Keepsy", "test@example.com", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Keepsy:test@example.com?"))
+	assert.Contains(t, uri, "secret=ABC")
+	assert.Contains(t, uri, "issuer=Keepsy")
+}
+
+func TestTwoFactor(t *testing.T) {
+	opts := loginOpts
+	opts.TOTPIssuer = "Keepsy"
+	opts.SecondFactorTTL = 5 * time.Minute
+
+	secret, _ := generateTOTPSecret()
+	confirmedAt := time.Now()
+	enabled := func() *TOTPCredential {
+		return &TOTPCredential{UserID: 1, Secret: secret, ConfirmedAt: &confirmedAt}
+	}
+	currentCode := func() string {
+		code, _ := totpCode(secret, totpStep(time.Now()))
+		return code
+	}
+	verifiedAt := time.Now()
+	user := &users.User{ID: 1, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}
+
+	t.Run("EnrollAndConfirm", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockTwoFactorRepo := new(MockTwoFactorRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(nil, mockUserRepo, nil, nil, mockSecurityRepo, mockTwoFactorRepo, nil, nil, newTestTokens(), nil, nil, opts)
+
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(nil, ErrTOTPNotFound).Once()
+		mockUserRepo.On("GetByID", mock.Anything, 1).Return(user, nil)
+		mockTwoFactorRepo.On("SaveTOTP", mock.Anything, mock.AnythingOfType("*auth.TOTPCredential")).Return(nil)
+
+		setup, err := service.EnrollTOTP(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, setup.Secret)
+		assert.Contains(t, setup.URI, "secret="+setup.Secret)
+
+		pending := &TOTPCredential{UserID: 1, Secret: setup.Secret}
+		code, _ := totpCode(setup.Secret, totpStep(time.Now()))
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(pending, nil)
+		mockTwoFactorRepo.On("UseTOTPStep", mock.Anything, 1, mock.Anything).Return(true, nil)
+		mockTwoFactorRepo.On("ConfirmTOTP", mock.Anything, 1).Return(nil)
+		mockTwoFactorRepo.On("ReplaceRecoveryCodes", mock.Anything, 1, mock.MatchedBy(func(hashes []string) bool {
+			return len(hashes) == recoveryCodeCount
+		})).Return(nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventTwoFactorEnabled
+		})).Return(nil)
+
+		codes, err := service.ConfirmTOTP(context.Background(), 1, SecondFactorRequest{Code: code})
+		assert.NoError(t, err)
+		assert.Len(t, codes.Codes, recoveryCodeCount)
+		mockTwoFactorRepo.AssertExpectations(t)
+	})
+
+	t.Run("EnrollWhenEnabled", func(t *testing.T) {
+		mockTwoFactorRepo := new(MockTwoFactorRepo)
+		service := NewService(nil, nil, nil, nil, nil, mockTwoFactorRepo, nil, nil, newTestTokens(), nil, nil, opts)
+
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(enabled(), nil)
+
+		_, err := service.EnrollTOTP(context.Background(), 1)
+		assert.Equal(t, ErrTwoFactorEnabled, err)
+	})
+
+	t.Run("LoginReturnsChallenge", func(t *testing.T) {
+		mockAuthRepo := new(MockAuthRepo)
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		mockTwoFactorRepo := new(MockTwoFactorRepo)
+		service := NewService(mockAuthRepo, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, mockTwoFactorRepo, nil, nil, newTestTokens(), nil, nil, opts)
+
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		mockAuthRepo.On("GetPasswordHash", mock.Anything, 1).Return(string(hash), nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(enabled(), nil)
+
+		resp, err := service.Login(context.Background(), LoginRequest{Identifier: "test@example.com", Password: "password123"})
+
+		assert.NoError(t, err)
+		assert.Nil(t, resp.User)
+		assert.Nil(t, resp.TokenPair)
+		assert.NotEmpty(t, resp.SecondFactor.Token)
+		assert.Equal(t, 300, resp.SecondFactor.ExpiresIn)
+		mockSessionRepo.AssertNotCalled(t, "Create")
+		mockSecurityRepo.AssertNotCalled(t, "RecordEvent")
+	})
+
+	t.Run("LoginSecondFactorWithTOTP", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		mockTwoFactorRepo := new(MockTwoFactorRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, mockTwoFactorRepo, nil, nil, tokens, nil, nil, opts)
+
+		challenge, _ := tokens.IssueChallenge(1, time.Minute)
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(enabled(), nil)
+		mockTwoFactorRepo.On("UseTOTPStep", mock.Anything, 1, mock.Anything).Return(true, nil)
+		mockUserRepo.On("GetByID", mock.Anything, 1).Return(user, nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventLoginSucceeded
+		})).Return(nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		resp, err := service.LoginSecondFactor(context.Background(), SecondFactorLoginRequest{Token: challenge, Code: currentCode()})
+
+		assert.NoError(t, err)
+		assert.Equal(t, user, resp.User)
+		assert.NotEmpty(t, resp.AccessToken)
+		mockTwoFactorRepo.AssertExpectations(t)
+	})
+
+	t.Run("LoginSecondFactorWrongCode", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		mockTwoFactorRepo := new(MockTwoFactorRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, mockTwoFactorRepo, nil, nil, tokens, nil, nil, opts)
+
+		challenge, _ := tokens.IssueChallenge(1, time.Minute)
+		wrong := "000000"
+		if currentCode() == wrong {
+			wrong = "111111"
+		}
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(enabled(), nil)
+		mockUserRepo.On("GetByID", mock.Anything, 1).Return(user, nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordFailure", mock.Anything, ThrottleIdentifier, "2fa:1", mock.Anything, mock.Anything).Return(1, nil)
+		mockSecurityRepo.On("LockThrottle", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		_, err := service.LoginSecondFactor(context.Background(), SecondFactorLoginRequest{Token: challenge, Code: wrong})
+
+		assert.Equal(t, ErrInvalidCode, err)
+		mockSecurityRepo.AssertExpectations(t)
+		mockSessionRepo.AssertNotCalled(t, "Create")
+	})
+
+	t.Run("LoginSecondFactorWithRecoveryCode", func(t *testing.T) {
+		mockUserRepo := new(MockUserRepo)
+		mockSessionRepo := new(MockSessionRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		mockTwoFactorRepo := new(MockTwoFactorRepo)
+		tokens := newTestTokens()
+		service := NewService(nil, mockUserRepo, mockSessionRepo, nil, mockSecurityRepo, mockTwoFactorRepo, nil, nil, tokens, nil, nil, opts)
+
+		challenge, _ := tokens.IssueChallenge(1, time.Minute)
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(enabled(), nil)
+		mockTwoFactorRepo.On("UseRecoveryCode", mock.Anything, 1, hashRecoveryCode("abcdefghijklmnop")).Return(true, nil)
+		mockUserRepo.On("GetByID", mock.Anything, 1).Return(user, nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.Anything).Return(nil)
+		mockSessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		resp, err := service.LoginSecondFactor(context.Background(), SecondFactorLoginRequest{Token: challenge, Code: "ABCD-EFGH-IJKL-MNOP"})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+		mockTwoFactorRepo.AssertExpectations(t)
+	})
+
+	t.Run("LoginSecondFactorRejectsAccessToken", func(t *testing.T) {
+		tokens := newTestTokens()
+		service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, opts)
+
+		pair, _ := tokens.Issue(1, "s1")
+		_, err := service.LoginSecondFactor(context.Background(), SecondFactorLoginRequest{Token: pair.AccessToken, Code: "123456"})
+
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		mockSecurityRepo := new(MockSecurityRepo)
+		mockTwoFactorRepo := new(MockTwoFactorRepo)
+		service := NewService(nil, nil, nil, nil, mockSecurityRepo, mockTwoFactorRepo, nil, nil, newTestTokens(), nil, nil, opts)
+
+		mockTwoFactorRepo.On("GetTOTP", mock.Anything, 1).Return(enabled(), nil)
+		mockTwoFactorRepo.On("UseTOTPStep", mock.Anything, 1, mock.Anything).Return(true, nil)
+		mockTwoFactorRepo.On("DeleteTOTP", mock.Anything, 1).Return(nil)
+		mockSecurityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		mockSecurityRepo.On("RecordEvent", mock.Anything, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventTwoFactorDisabled
+		})).Return(nil)
+
+		err := service.DisableTOTP(context.Background(), 1, SecondFactorRequest{Code: currentCode()})
+
+		assert.NoError(t, err)
+		mockTwoFactorRepo.AssertExpectations(t)
+	})
+}
+
+// oidcFixture runs logins against a local fake identity provider.
+type oidcFixture struct {
+	idp          *fakeidp.Provider
+	provider     *OIDCProvider
+	identityRepo *MockIdentityRepo
+	userRepo     *MockUserRepo
+	sessionRepo  *MockSessionRepo
+	securityRepo *MockSecurityRepo
+	service      Service
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	idp, srv, err := fakeidp.NewServer("keepsy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	f := &oidcFixture{
+		idp: idp,
+		provider: NewOIDCProvider(OIDCConfig{
+			Name:        "fake",
+			Issuer:      idp.Issuer,
+			ClientID:    "keepsy-test",
+			RedirectURL: "keepsy://oidc/callback",
+		}, srv.Client()),
+		identityRepo: new(MockIdentityRepo),
+		userRepo:     new(MockUserRepo),
+		sessionRepo:  new(MockSessionRepo),
+		securityRepo: new(MockSecurityRepo),
+	}
+	opts := loginOpts
+	opts.OIDCProviders = map[string]*OIDCProvider{"fake": f.provider}
+	f.service = NewService(nil, f.userRepo, f.sessionRepo, nil, f.securityRepo, noTwoFactor(), f.identityRepo, nil, newTestTokens(), nil, nil, opts)
+
+	f.securityRepo.On("GetThrottle", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	f.securityRepo.On("RecordEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	f.sessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return f
+}
+
+// authorize starts a login, signs in at the fake provider and returns the
+// callback request the app would send, along with the stored state.
+func (f *oidcFixture) authorize(t *testing.T, user fakeidp.User) (OIDCLoginRequest, *OIDCState) {
+	f.idp.SetUser(user)
+
+	var state *OIDCState
+	f.identityRepo.On("CreateState", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		state = args.Get(1).(*OIDCState)
+	}).Return(nil).Once()
+
+	authz, err := f.service.StartOIDCLogin(context.Background(), "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authz.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("unexpected authorize response %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	return OIDCLoginRequest{
+		Provider: "fake",
+		Code:     callback.Query().Get("code"),
+		State:    callback.Query().Get("state"),
+	}, state
+}
+
+func (f *oidcFixture) expectState(state *OIDCState) {
+	f.identityRepo.On("GetState", mock.Anything, state.StateHash).Return(state, nil)
+	f.identityRepo.On("ConsumeState", mock.Anything, state.StateHash).Return(true, nil)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	alice := fakeidp.User{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+	t.Run("CreatesAccount", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		req, state := f.authorize(t, alice)
+		f.expectState(state)
+
+		f.identityRepo.On("GetIdentity", mock.Anything, "fake", "alice-1").Return(nil, ErrIdentityNotFound)
+		f.userRepo.On("GetByEmail", mock.Anything, "alice@example.com").Return(nil, errors.New("user not found"))
+		f.userRepo.On("Create", mock.Anything, mock.MatchedBy(func(u *users.User) bool {
+			return u.Name == "Alice" && u.Email == "alice@example.com" && u.UUID != ""
+		})).Return(nil)
+		f.userRepo.On("MarkEmailVerified", mock.Anything, 1).Return(nil)
+		f.identityRepo.On("CreateIdentity", mock.Anything, mock.MatchedBy(func(i *UserIdentity) bool {
+			return i.UserID == 1 && i.Provider == "fake" && i.Subject == "alice-1"
+		})).Return(nil)
+
+		resp, err := f.service.LoginWithOIDC(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "alice@example.com", resp.User.Email)
+		assert.NotNil(t, resp.User.EmailVerifiedAt)
+		assert.NotEmpty(t, resp.AccessToken)
+		f.identityRepo.AssertExpectations(t)
+		f.userRepo.AssertExpectations(t)
+	})
+
+	t.Run("LinksVerifiedAccount", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		req, state := f.authorize(t, alice)
+		f.expectState(state)
+
+		verifiedAt := time.Now()
+		existing := &users.User{ID: 7, Email: "alice@example.com", EmailVerifiedAt: &verifiedAt}
+		f.identityRepo.On("GetIdentity", mock.Anything, "fake", "alice-1").Return(nil, ErrIdentityNotFound)
+		f.userRepo.On("GetByEmail", mock.Anything, "alice@example.com").Return(existing, nil)
+		f.identityRepo.On("CreateIdentity", mock.Anything, mock.MatchedBy(func(i *UserIdentity) bool {
+			return i.UserID == 7
+		})).Return(nil)
+
+		resp, err := f.service.LoginWithOIDC(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, existing, resp.User)
+		f.userRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("KnownIdentity", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		req, state := f.authorize(t, alice)
+		f.expectState(state)
+
+		existing := &users.User{ID: 7, Email: "old@example.com"}
+		f.identityRepo.On("GetIdentity", mock.Anything, "fake", "alice-1").Return(&UserIdentity{ID: 3, UserID: 7}, nil)
+		f.identityRepo.On("TouchIdentity", mock.Anything, 3, mock.Anything).Return(nil)
+		f.userRepo.On("GetByID", mock.Anything, 7).Return(existing, nil)
+
+		resp, err := f.service.LoginWithOIDC(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, existing, resp.User)
+		f.userRepo.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("RefusesUnverifiedAccount", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		req, state := f.authorize(t, alice)
+		f.expectState(state)
+
+		f.identityRepo.On("GetIdentity", mock.Anything, "fake", "alice-1").Return(nil, ErrIdentityNotFound)
+		f.userRepo.On("GetByEmail", mock.Anything, "alice@example.com").Return(&users.User{ID: 7, Email: "alice@example.com"}, nil)
+
+		_, err := f.service.LoginWithOIDC(context.Background(), req)
+
+		assert.Equal(t, ErrAccountNotLinkable, err)
+		f.identityRepo.AssertNotCalled(t, "CreateIdentity", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ProviderEmailUnverified", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		unverified := alice
+		unverified.EmailVerified = false
+		req, state := f.authorize(t, unverified)
+		f.expectState(state)
+
+		f.identityRepo.On("GetIdentity", mock.Anything, "fake", "alice-1").Return(nil, ErrIdentityNotFound)
+
+		_, err := f.service.LoginWithOIDC(context.Background(), req)
+
+		assert.Equal(t, ErrOIDCEmailRequired, err)
+	})
+
+	t.Run("StateUsedTwice", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		req, state := f.authorize(t, alice)
+		f.identityRepo.On("GetState", mock.Anything, state.StateHash).Return(state, nil)
+		f.identityRepo.On("ConsumeState", mock.Anything, state.StateHash).Return(false, nil)
+
+		_, err := f.service.LoginWithOIDC(context.Background(), req)
+
+		assert.Equal(t, ErrInvalidOIDCState, err)
+	})
+
+	t.Run("WrongCodeVerifier", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		req, state := f.authorize(t, alice)
+		tampered := *state
+		tampered.CodeVerifier = "not-the-verifier"
+		f.identityRepo.On("GetState", mock.Anything, state.StateHash).Return(&tampered, nil)
+		f.identityRepo.On("ConsumeState", mock.Anything, state.StateHash).Return(true, nil)
+
+		_, err := f.service.LoginWithOIDC(context.Background(), req)
+
+		assert.ErrorIs(t, err, ErrOIDCExchange)
+	})
+
+	t.Run("UnknownProvider", func(t *testing.T) {
+		f := newOIDCFixture(t)
+
+		_, err := f.service.StartOIDCLogin(context.Background(), "myspace")
+
+		assert.Equal(t, ErrUnknownProvider, err)
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	f := newOIDCFixture(t)
+	now := time.Now()
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":   f.idp.Issuer,
+			"sub":   "alice-1",
+			"aud":   "keepsy-test",
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Minute * 5).Unix(),
+			"nonce": "n-1",
+			"email": "alice@example.com",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	valid, _ := f.idp.SignIDToken(claims(map[string]any{"email_verified": "true", "aud": []string{"keepsy-test", "other"}, "azp": "keepsy-test"}))
+	got, err := f.provider.VerifyIDToken(context.Background(), valid, "n-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice-1", got.Subject)
+	assert.True(t, bool(got.EmailVerified))
+
+	for name, overrides := range map[string]map[string]any{
+		"WrongIssuer":   {"iss": "https://evil.example.com"},
+		"WrongAudience": {"aud": "someone-else"},
+		"Expired":       {"exp": now.Add(-time.Hour).Unix()},
+		"WrongNonce":    {"nonce": "n-2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			token, _ := f.idp.SignIDToken(claims(overrides))
+			_, err := f.provider.VerifyIDToken(context.Background(), token, "n-1")
+			assert.ErrorIs(t, err, ErrInvalidIDToken)
+		})
+	}
+
+	t.Run("TamperedPayload", func(t *testing.T) {
+		parts := strings.Split(valid, ".")
+		other, _ := f.idp.SignIDToken(claims(map[string]any{"sub": "mallory"}))
+		parts[1] = strings.Split(other, ".")[1]
+		_, err := f.provider.VerifyIDToken(context.Background(), strings.Join(parts, "."), "n-1")
+		assert.ErrorIs(t, err, ErrInvalidIDToken)
+	})
+
+	t.Run("UnsignedToken", func(t *testing.T) {
+		parts := strings.Split(valid, ".")
+		parts[0] = "eyJhbGciOiJub25lIiwia2lkIjoiZmFrZWlkcC0xIn0" // {"alg":"none","kid":"fakeidp-1"}
+		_, err := f.provider.VerifyIDToken(context.Background(), strings.Join(parts[:2], ".")+".", "n-1")
+		assert.ErrorIs(t, err, ErrInvalidIDToken)
+	})
+}
+
+func TestAccessTokens(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Create Stores Only The Hash", func(t *testing.T) {
+		mockTokenRepo := new(MockAccessTokenRepo)
+		mockSecurityRepo := new(MockSecurityRepo)
+		service := NewService(nil, nil, nil, nil, mockSecurityRepo, noTwoFactor(), nil, mockTokenRepo, newTestTokens(), nil, nil, Options{})
+
+		var stored *AccessTokenInfo
+		mockTokenRepo.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*AccessTokenInfo)
+		}).Return(nil)
+		mockSecurityRepo.On("RecordEvent", ctx, mock.MatchedBy(func(e *SecurityEvent) bool {
+			return e.Type == EventAccessTokenCreated
+		})).Return(nil)
+
+		expiresAt := time.Now().Add(30 * 24 * time.Hour)
+		created, err := service.CreateAccessToken(ctx, 1, CreateAccessTokenRequest{
+			Name:      "upload script",
+			Scopes:    []string{"Products:read", "bills:write", "reminders:*", "bills:write"},
+			ExpiresAt: &expiresAt,
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(created.Token, accessTokenPrefix))
+		assert.Equal(t, []string{"products:read", "bills:write", "reminders:*"}, created.Scopes)
+		assert.Equal(t, hashResetToken(created.Token), stored.TokenHash)
+		assert.NotContains(t, stored.TokenHash, created.Token)
+		assert.True(t, strings.HasPrefix(created.Token, stored.Prefix))
+	})
+
+	t.Run("Create Validation", func(t *testing.T) {
+		service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, new(MockAccessTokenRepo), newTestTokens(), nil, nil, Options{})
+		past := time.Now().Add(-time.Minute)
+
+		for name, req := range map[string]CreateAccessTokenRequest{
+			"NoName":        {Scopes: []string{"products:read"}},
+			"NoScopes":      {Name: "x"},
+			"UnknownScope":  {Name: "x", Scopes: []string{"users:read"}},
+			"UnknownAction": {Name: "x", Scopes: []string{"products:delete"}},
+			"PastExpiry":    {Name: "x", Scopes: []string{"products:read"}, ExpiresAt: &past},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := service.CreateAccessToken(ctx, 1, req)
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		raw := accessTokenPrefix + "secret"
+		expired := time.Now().Add(-time.Minute)
+		revoked := time.Now().Add(-time.Hour)
+
+		cases := map[string]struct {
+			token *AccessTokenInfo
+			ok    bool
+		}{
+			"Valid":   {&AccessTokenInfo{ID: 3, UserID: 1, Scopes: []string{"products:read"}}, true},
+			"Expired": {&AccessTokenInfo{ID: 3, UserID: 1, ExpiresAt: &expired}, false},
+			"Revoked": {&AccessTokenInfo{ID: 3, UserID: 1, RevokedAt: &revoked}, false},
+		}
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				mockTokenRepo := new(MockAccessTokenRepo)
+				service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, mockTokenRepo, newTestTokens(), nil, nil, Options{})
+				mockTokenRepo.On("GetByHash", ctx, hashResetToken(raw)).Return(c.token, nil)
+				mockTokenRepo.On("Touch", ctx, 3, mock.Anything).Return(nil)
+
+				principal, err := service.Authenticate(ctx, raw)
+
+				if !c.ok {
+					assert.Equal(t, ErrInvalidToken, err)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, &Principal{UserID: 1, AccessTokenID: 3, Scopes: []string{"products:read"}}, principal)
+			})
+		}
+	})
+
+	t.Run("Revoke Other Users Token", func(t *testing.T) {
+		mockTokenRepo := new(MockAccessTokenRepo)
+		service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, mockTokenRepo, newTestTokens(), nil, nil, Options{})
+		mockTokenRepo.On("Revoke", ctx, 1, 9).Return(false, nil)
+
+		err := service.RevokeAccessToken(ctx, 1, 9)
+
+		assert.Equal(t, ErrAccessTokenNotFound, err)
+	})
+}
+
+func TestScopes(t *testing.T) {
+	session := &Principal{UserID: 1, SessionID: "s"}
+	token := &Principal{UserID: 1, AccessTokenID: 3, Scopes: []string{"products:read", "reminders:*"}}
+
+	assert.True(t, session.HasScope("bills:write"))
+	assert.True(t, token.HasScope("products:read"))
+	assert.False(t, token.HasScope("products:write"))
+	assert.True(t, token.HasScope("reminders:write"))
+	assert.False(t, token.HasScope("bills:read"))
+
+	mockTokenRepo := new(MockAccessTokenRepo)
+	service := NewService(nil, nil, nil, nil, nil, noTwoFactor(), nil, mockTokenRepo, newTestTokens(), nil, nil, Options{})
+	mockTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(&AccessTokenInfo{ID: 3, UserID: 1, Scopes: token.Scopes}, nil)
+	mockTokenRepo.On("Touch", mock.Anything, 3, mock.Anything).Return(nil)
+	middleware := NewMiddleware(service)
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	for name, c := range map[string]struct {
+		handler http.HandlerFunc
+		status  int
+	}{
+		"MatchingScope": {middleware.RequireScope("products:read", ok), http.StatusOK},
+		"MissingScope":  {middleware.RequireScope("bills:write", ok), http.StatusForbidden},
+		"SessionOnly":   {middleware.RequireAuth(ok), http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+accessTokenPrefix+"secret")
+			rec := httptest.NewRecorder()
+
+			c.handler(rec, req)
+
+			assert.Equal(t, c.status, rec.Code)
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	for name, c := range map[string]struct {
+		role   string
+		status int
+	}{
+		"Admin": {users.RoleAdmin, http.StatusOK},
+		"User":  {users.RoleUser, http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockSessionRepo := new(MockSessionRepo)
+			mockUserRepo := new(MockUserRepo)
+			tokens := newTestTokens()
+			service := NewService(nil, mockUserRepo, mockSessionRepo, nil, nil, noTwoFactor(), nil, nil, tokens, nil, nil, Options{})
+
+			pair, _ := tokens.Issue(42, "s1")
+			mockSessionRepo.On("GetByID", mock.Anything, "s1").Return(activeSession("s1", 42), nil)
+			mockUserRepo.On("GetByID", mock.Anything, 42).Return(&users.User{ID: 42, Role: c.role}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/categories", nil)
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			rec := httptest.NewRecorder()
+
+			NewMiddleware(service).RequireAdmin(ok)(rec, req)
+
+			assert.Equal(t, c.status, rec.Code)
+		})
+	}
+}
+
+func TestIdentifierVerified(t *testing.T) {
+	verifiedAt := time.Now()
+	user := &users.User{ID: 1, Email: "alice@example.com", Phone: "9999999999", EmailVerifiedAt: &verifiedAt}
+
+	assert.True(t, identifierVerified(user, "alice@example.com"))
+	// GetByEmail ignores case, so the comparison must too
+	assert.True(t, identifierVerified(user, "Alice@Example.com"))
+	assert.False(t, identifierVerified(user, "9999999999"))
 }
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	GetByID(ctx context.Context, id string) (*Session, error)
+	ListActiveByUserID(ctx context.Context, userID int) ([]*Session, error)
+	// Touch records activity on the session.
+	Touch(ctx context.Context, id string, seenAt time.Time) error
+	// Renew pushes out the expiry of an active session (used on token refresh).
+	Renew(ctx context.Context, id string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id string) error
+	RevokeAllByUserID(ctx context.Context, userID int) error
+	// RevokeOthers revokes every session of the user except keepID.
+	RevokeOthers(ctx context.Context, userID int, keepID string) error
+}
+
+type MySQLSessionRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLSessionRepository(db *sql.DB) *MySQLSessionRepository {
+	return &MySQLSessionRepository{db: db}
+}
+
+func (r *MySQLSessionRepository) Create(ctx context.Context, session *Session) error {
+	query := `
+		INSERT INTO keepsy_sessions (id, user_id, device_name, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		session.ID, session.UserID, session.DeviceName, session.IP, session.UserAgent,
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSessionRepository) GetByID(ctx context.Context, id string) (*Session, error) {
+	query := `
+		SELECT id, user_id, device_name, ip, user_agent, created_at, last_seen_at, expires_at, revoked_at
+		FROM keepsy_sessions WHERE id = ?
+	`
+	var s Session
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&s.ID, &s.UserID, &s.DeviceName, &s.IP, &s.UserAgent,
+		&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *MySQLSessionRepository) ListActiveByUserID(ctx context.Context, userID int) ([]*Session, error) {
+	query := `
+		SELECT id, user_id, device_name, ip, user_agent, created_at, last_seen_at, expires_at, revoked_at
+		FROM keepsy_sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY last_seen_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(
+			&s.ID, &s.UserID, &s.DeviceName, &s.IP, &s.UserAgent,
+			&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, nil
+}
+
+func (r *MySQLSessionRepository) Touch(ctx context.Context, id string, seenAt time.Time) error {
+	query := `UPDATE keepsy_sessions SET last_seen_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, seenAt, id); err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSessionRepository) Renew(ctx context.Context, id string, expiresAt time.Time) error {
+	query := `UPDATE keepsy_sessions SET expires_at = ?, last_seen_at = ? WHERE id = ? AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, expiresAt, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to renew session: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSessionRepository) Revoke(ctx context.Context, id string) error {
+	query := `UPDATE keepsy_sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSessionRepository) RevokeAllByUserID(ctx context.Context, userID int) error {
+	query := `UPDATE keepsy_sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSessionRepository) RevokeOthers(ctx context.Context, userID int, keepID string) error {
+	query := `UPDATE keepsy_sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), userID, keepID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"keepsy-backend/internal/users"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// touchInterval limits how often Authenticate writes last-seen times.
+const touchInterval = time.Minute
+
+// startSession records a new login and issues tokens bound to it.
+func (s *service) startSession(ctx context.Context, user *users.User, client ClientInfo) (*AuthResponse, error) {
+	now := time.Now()
+	session := &Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		DeviceName: client.DeviceName,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  s.tokens.RefreshExpiry(),
+	}
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	tokens, err := s.tokens.Issue(user.ID, session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue tokens: %w", err)
+	}
+	return &AuthResponse{User: user, TokenPair: tokens}, nil
+}
+
+// activeSession loads the session a token belongs to and makes sure it is still usable.
+func (s *service) activeSession(ctx context.Context, claims *Claims) (*Session, error) {
+	session, err := s.sessionRepo.GetByID(ctx, claims.SessionID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if session.UserID != claims.Subject || session.RevokedAt != nil || !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return session, nil
+}
+
+func (s *service) Refresh(ctx context.Context, req RefreshRequest) (*TokenPair, error) {
+	claims, err := s.tokens.Verify(req.RefreshToken, RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.activeSession(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.Renew(ctx, session.ID, s.tokens.RefreshExpiry()); err != nil {
+		return nil, err
+	}
+
+	return s.tokens.Issue(session.UserID, session.ID)
+}
+
+func (s *service) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if strings.HasPrefix(token, accessTokenPrefix) {
+		return s.authenticateAccessToken(ctx, token)
+	}
+
+	claims, err := s.tokens.Verify(token, AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.activeSession(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if now := time.Now(); now.Sub(session.LastSeenAt) > touchInterval {
+		// Best effort, a failed write shouldn't fail the request
+		_ = s.sessionRepo.Touch(ctx, session.ID, now)
+	}
+
+	return &Principal{UserID: session.UserID, SessionID: session.ID}, nil
+}
+
+func (s *service) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.Role == users.RoleAdmin, nil
+}
+
+func (s *service) ListSessions(ctx context.Context, principal *Principal) ([]*Session, error) {
+	sessions, err := s.sessionRepo.ListActiveByUserID(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == principal.SessionID
+	}
+	return sessions, nil
+}
+
+func (s *service) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// Don't leak other users' session IDs
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return s.sessionRepo.Revoke(ctx, sessionID)
+}
+
+func (s *service) RevokeAllSessions(ctx context.Context, userID int) error {
+	return s.sessionRepo.RevokeAllByUserID(ctx, userID)
+}
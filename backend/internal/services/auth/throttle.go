@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxLockout caps how far exponential lockouts can grow.
+const maxLockout = 24 * time.Hour
+
+var ErrAccountLocked = errors.New("account temporarily locked")
+
+// LockedError is returned by Login while an identifier or IP is locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrAccountLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
+
+// throttleKeys returns the keys a login attempt is counted against.
+func throttleKeys(identifier, ip string) map[ThrottleScope]string {
+	keys := map[ThrottleScope]string{
+		ThrottleIdentifier: strings.ToLower(strings.TrimSpace(identifier)),
+	}
+	if ip != "" {
+		keys[ThrottleIP] = ip
+	}
+	return keys
+}
+
+// checkThrottle fails with a LockedError if the identifier or IP may not try yet.
+func (s *service) checkThrottle(ctx context.Context, identifier, ip string) error {
+	now := time.Now()
+	var wait time.Duration
+
+	for scope, key := range throttleKeys(identifier, ip) {
+		throttle, err := s.securityRepo.GetThrottle(ctx, scope, key)
+		if err != nil {
+			return err
+		}
+		if throttle != nil && throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			wait = max(wait, throttle.LockedUntil.Sub(now))
+		}
+	}
+
+	if wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt. Each failure doubles the wait
+// before the next attempt; past the threshold the key is locked out, and
+// every further failure doubles the lockout.
+func (s *service) recordLoginFailure(ctx context.Context, userID *int, client ClientInfo, identifier string) error {
+	now := time.Now()
+
+	for scope, key := range throttleKeys(identifier, client.IP) {
+		threshold := s.opts.LoginMaxFailures
+		if scope == ThrottleIP {
+			threshold = s.opts.LoginMaxFailuresPerIP
+		}
+
+		// Failures are remembered for as long as the longest lockout, so
+		// failing again after one runs out doubles it instead of starting over
+		failures, err := s.securityRepo.RecordFailure(ctx, scope, key, now, maxLockout)
+		if err != nil {
+			return err
+		}
+
+		var delay time.Duration
+		if failures >= threshold {
+			delay = backoff(s.opts.LoginLockout, failures-threshold)
+		} else {
+			delay = backoff(s.opts.LoginBackoffBase, failures-1)
+		}
+
+		if err := s.securityRepo.LockThrottle(ctx, scope, key, now.Add(delay)); err != nil {
+			return err
+		}
+
+		if failures == threshold {
+			if err := s.recordEvent(ctx, EventAccountLocked, userID, client, identifier); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recordLoginSuccess clears the identifier's failures and logs the login.
+// The IP counter is left to decay on its own so one valid account can't be
+// used to reset it.
+func (s *service) recordLoginSuccess(ctx context.Context, userID int, client ClientInfo, identifier string) error {
+	if err := s.unlock(ctx, userID, client, identifier); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, EventLoginSucceeded, &userID, client, identifier)
+}
+
+// unlock clears an identifier's failures, logging an unlock if it had been locked out.
+func (s *service) unlock(ctx context.Context, userID int, client ClientInfo, identifier string) error {
+	key := throttleKeys(identifier, "")[ThrottleIdentifier]
+
+	throttle, err := s.securityRepo.GetThrottle(ctx, ThrottleIdentifier, key)
+	if err != nil || throttle == nil {
+		return err
+	}
+
+	if err := s.securityRepo.ResetThrottle(ctx, ThrottleIdentifier, key); err != nil {
+		return err
+	}
+
+	if throttle.Failures >= s.opts.LoginMaxFailures {
+		return s.recordEvent(ctx, EventAccountUnlocked, &userID, client, identifier)
+	}
+	return nil
+}
+
+func (s *service) recordEvent(ctx context.Context, typ SecurityEventType, userID *int, client ClientInfo, identifier string) error {
+	return s.securityRepo.RecordEvent(ctx, &SecurityEvent{
+		UserID:     userID,
+		Type:       typ,
+		Identifier: identifier,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+	})
+}
+
+// backoff returns base * 2^n, capped at maxLockout.
+func backoff(base time.Duration, n int) time.Duration {
+	d := base
+	for i := 0; i < n && d < maxLockout; i++ {
+		d *= 2
+	}
+	return min(d, maxLockout)
+}
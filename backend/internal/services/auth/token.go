@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+	// ChallengeToken proves the password step of a two-factor login. It isn't bound to a session.
+	ChallengeToken TokenType = "2fa"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims is the payload carried by every token we issue.
+// The wire format is a standard HS256 JWT so clients can decode it with any JWT library.
+type Claims struct {
+	Subject   int       `json:"sub"`
+	SessionID string    `json:"sid"`
+	Type      TokenType `json:"typ"`
+	ID        string    `json:"jti"`
+	IssuedAt  int64     `json:"iat"`
+	ExpiresAt int64     `json:"exp"`
+}
+
+// TokenManager issues and verifies signed access and refresh tokens.
+type TokenManager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+func NewTokenManager(secret string, accessTTL, refreshTTL time.Duration) *TokenManager {
+	return &TokenManager{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+	}
+}
+
+// jwtHeader is constant since we only ever sign with HS256.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Issue creates a fresh access/refresh token pair bound to a session.
+func (m *TokenManager) Issue(userID int, sessionID string) (*TokenPair, error) {
+	now := m.now()
+
+	access, err := m.sign(userID, sessionID, AccessToken, now, m.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := m.sign(userID, sessionID, RefreshToken, now, m.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(m.accessTTL.Seconds()),
+	}, nil
+}
+
+// IssueChallenge creates a short-lived token for finishing a two-factor login.
+func (m *TokenManager) IssueChallenge(userID int, ttl time.Duration) (string, error) {
+	return m.sign(userID, "", ChallengeToken, m.now(), ttl)
+}
+
+// RefreshExpiry returns when a refresh token issued now would expire.
+// Sessions share this lifetime.
+func (m *TokenManager) RefreshExpiry() time.Time {
+	return m.now().Add(m.refreshTTL)
+}
+
+func (m *TokenManager) sign(userID int, sessionID string, typ TokenType, now time.Time, ttl time.Duration) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(Claims{
+		Subject:   userID,
+		SessionID: sessionID,
+		Type:      typ,
+		ID:        jti,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + m.signature(unsigned), nil
+}
+
+// Verify checks the signature, expiry and type of a token and returns its claims.
+func (m *TokenManager) Verify(token string, typ TokenType) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, ErrInvalidToken
+	}
+
+	expected := m.signature(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Type != typ || claims.Subject <= 0 {
+		return nil, ErrInvalidToken
+	}
+	if m.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+func (m *TokenManager) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
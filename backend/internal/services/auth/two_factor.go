@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/users"
+	"strings"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrTOTPNotEnrolled     = errors.New("start two-factor enrollment first")
+)
+
+func (s *service) TwoFactorStatus(ctx context.Context, userID int) (*TwoFactorStatus, error) {
+	cred, err := s.confirmedTOTP(ctx, userID)
+	if err != nil || cred == nil {
+		return &TwoFactorStatus{}, err
+	}
+
+	left, err := s.twoFactorRepo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorStatus{Enabled: true, RecoveryCodesLeft: left}, nil
+}
+
+// EnrollTOTP creates a new secret for the user's authenticator app. It only
+// takes effect once ConfirmTOTP has seen a valid code from it.
+func (s *service) EnrollTOTP(ctx context.Context, userID int) (*TOTPSetup, error) {
+	cred, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cred != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.SaveTOTP(ctx, &TOTPCredential{UserID: userID, Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Phone
+	}
+	return &TOTPSetup{Secret: secret, URI: totpURI(s.opts.TOTPIssuer, account, secret)}, nil
+}
+
+// ConfirmTOTP turns two-factor authentication on and returns the user's recovery codes.
+func (s *service) ConfirmTOTP(ctx context.Context, userID int, req SecondFactorRequest) (*RecoveryCodes, error) {
+	cred, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if err == ErrTOTPNotFound {
+			return nil, ErrTOTPNotEnrolled
+		}
+		return nil, err
+	}
+	if cred.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	if err := s.checkTOTP(ctx, cred, normalizeCode(req.Code)); err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.ConfirmTOTP(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.newRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.recordEvent(ctx, EventTwoFactorEnabled, &userID, req.ClientInfo, ""); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off. It needs a current code,
+// so a stolen session alone can't remove the second factor.
+func (s *service) DisableTOTP(ctx context.Context, userID int, req SecondFactorRequest) error {
+	cred, err := s.requireTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifySecondFactor(ctx, cred, req.Code, req.ClientInfo); err != nil {
+		return err
+	}
+
+	if err := s.twoFactorRepo.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, EventTwoFactorDisabled, &userID, req.ClientInfo, "")
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID int, req SecondFactorRequest) (*RecoveryCodes, error) {
+	cred, err := s.requireTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, cred, req.Code, req.ClientInfo); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+// LoginSecondFactor finishes a login that Login answered with a challenge.
+func (s *service) LoginSecondFactor(ctx context.Context, req SecondFactorLoginRequest) (*AuthResponse, error) {
+	claims, err := s.tokens.Verify(req.Token, ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2FA may have been turned off since the challenge was issued
+	cred, err := s.requireTOTP(ctx, claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.verifySecondFactor(ctx, cred, req.Code, req.ClientInfo); err != nil {
+		return nil, err
+	}
+	if err := s.recordLoginSuccess(ctx, user.ID, req.ClientInfo, secondFactorKey(user.ID)); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, req.ClientInfo)
+}
+
+// secondFactorChallenge returns a challenge instead of a session for users
+// with 2FA on, or nil when the first factor is enough.
+func (s *service) secondFactorChallenge(ctx context.Context, user *users.User) (*AuthResponse, error) {
+	cred, err := s.confirmedTOTP(ctx, user.ID)
+	if err != nil || cred == nil {
+		return nil, err
+	}
+
+	token, err := s.tokens.IssueChallenge(user.ID, s.opts.SecondFactorTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue challenge: %w", err)
+	}
+	return &AuthResponse{SecondFactor: &SecondFactorChallenge{
+		Token:     token,
+		Methods:   []string{"totp", "recovery_code"},
+		ExpiresIn: int(s.opts.SecondFactorTTL.Seconds()),
+	}}, nil
+}
+
+// confirmedTOTP returns the user's TOTP credential, or nil if 2FA is off.
+func (s *service) confirmedTOTP(ctx context.Context, userID int) (*TOTPCredential, error) {
+	cred, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if err == ErrTOTPNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if cred.ConfirmedAt == nil {
+		return nil, nil
+	}
+	return cred, nil
+}
+
+func (s *service) requireTOTP(ctx context.Context, userID int) (*TOTPCredential, error) {
+	cred, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cred == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return cred, nil
+}
+
+// verifySecondFactor checks an authenticator or recovery code. Wrong codes
+// count towards the same lockout as failed password logins, keyed by user.
+func (s *service) verifySecondFactor(ctx context.Context, cred *TOTPCredential, code string, client ClientInfo) error {
+	key := secondFactorKey(cred.UserID)
+	if err := s.checkThrottle(ctx, key, client.IP); err != nil {
+		return err
+	}
+
+	code = normalizeCode(code)
+	var err error
+	if isTOTPCode(code) {
+		err = s.checkTOTP(ctx, cred, code)
+	} else {
+		err = s.useRecoveryCode(ctx, cred.UserID, code, client)
+	}
+
+	if err == ErrInvalidCode {
+		if ferr := s.recordLoginFailure(ctx, &cred.UserID, client, key); ferr != nil {
+			return ferr
+		}
+	}
+	return err
+}
+
+func (s *service) checkTOTP(ctx context.Context, cred *TOTPCredential, code string) error {
+	step, ok := matchTOTP(cred.Secret, code, time.Now(), cred.LastUsedStep)
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	used, err := s.twoFactorRepo.UseTOTPStep(ctx, cred.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func (s *service) useRecoveryCode(ctx context.Context, userID int, code string, client ClientInfo) error {
+	if code == "" {
+		return ErrInvalidCode
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return s.recordEvent(ctx, EventRecoveryCodeUsed, &userID, client, "")
+}
+
+func (s *service) newRecoveryCodes(ctx context.Context, userID int) (*RecoveryCodes, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(normalizeCode(code))
+	}
+
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return &RecoveryCodes{Codes: codes}, nil
+}
+
+// generateRecoveryCode returns a code like "k3vd-x9qa-2mfe-7cpn" (80 random bits).
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	raw := strings.ToLower(totpEncoding.EncodeToString(b))
+
+	groups := make([]string, 0, 4)
+	for i := 0; i < len(raw); i += 4 {
+		groups = append(groups, raw[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeCode strips the separators users tend to type along with a code.
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// secondFactorKey is the throttle key for second factor attempts. It can't
+// collide with an email or phone number.
+func secondFactorKey(userID int) string {
+	return fmt.Sprintf("2fa:%d", userID)
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrTOTPNotFound = errors.New("totp not found")
+
+// TwoFactorRepository persists TOTP secrets and recovery codes.
+type TwoFactorRepository interface {
+	// GetTOTP returns ErrTOTPNotFound when the user never started enrollment.
+	GetTOTP(ctx context.Context, userID int) (*TOTPCredential, error)
+	// SaveTOTP stores a new, unconfirmed secret, replacing any previous one.
+	SaveTOTP(ctx context.Context, cred *TOTPCredential) error
+	ConfirmTOTP(ctx context.Context, userID int) error
+	// UseTOTPStep records step as the last accepted time step. It returns false
+	// if that step (or a later one) was already used.
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	// DeleteTOTP removes the secret together with the user's recovery codes.
+	DeleteTOTP(ctx context.Context, userID int) error
+
+	// ReplaceRecoveryCodes discards all previous codes of the user.
+	ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+	// UseRecoveryCode marks a code as used. It returns false if there is no unused code with that hash.
+	UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+}
+
+type MySQLTwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLTwoFactorRepository(db *sql.DB) *MySQLTwoFactorRepository {
+	return &MySQLTwoFactorRepository{db: db}
+}
+
+func (r *MySQLTwoFactorRepository) GetTOTP(ctx context.Context, userID int) (*TOTPCredential, error) {
+	query := `SELECT user_id, secret, confirmed_at, last_used_step, created_at FROM keepsy_user_totp WHERE user_id = ?`
+	var cred TOTPCredential
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&cred.UserID, &cred.Secret, &cred.ConfirmedAt, &cred.LastUsedStep, &cred.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTOTPNotFound
+		}
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+	return &cred, nil
+}
+
+func (r *MySQLTwoFactorRepository) SaveTOTP(ctx context.Context, cred *TOTPCredential) error {
+	query := `
+		INSERT INTO keepsy_user_totp (user_id, secret, confirmed_at, last_used_step, created_at)
+		VALUES (?, ?, NULL, 0, ?)
+		ON DUPLICATE KEY UPDATE secret = VALUES(secret), confirmed_at = NULL, last_used_step = 0, created_at = VALUES(created_at)
+	`
+	cred.CreatedAt = time.Now()
+	cred.ConfirmedAt = nil
+	cred.LastUsedStep = 0
+
+	if _, err := r.db.ExecContext(ctx, query, cred.UserID, cred.Secret, cred.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save totp: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLTwoFactorRepository) ConfirmTOTP(ctx context.Context, userID int) error {
+	query := `UPDATE keepsy_user_totp SET confirmed_at = ? WHERE user_id = ?`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to confirm totp: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLTwoFactorRepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	// The comparison makes this safe against the same code being used twice concurrently
+	query := `UPDATE keepsy_user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?`
+	result, err := r.db.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp step: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp step: %w", err)
+	}
+	return n == 1, nil
+}
+
+func (r *MySQLTwoFactorRepository) DeleteTOTP(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_user_totp WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	query := `INSERT INTO keepsy_recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)`
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, query, userID, hash, now); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	query := `UPDATE keepsy_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return n == 1, nil
+}
+
+func (r *MySQLTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	query := `SELECT COUNT(*) FROM keepsy_recovery_codes WHERE user_id = ? AND used_at IS NULL`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/services/mail"
+	"keepsy-backend/internal/users"
+	"strings"
+)
+
+var ErrIdentifierNotVerified = errors.New("identifier not verified")
+
+// SendEmailVerification sends a code to an account's unverified email, up
+// to VerifyCodeLimit per VerifyCodeWindow. Like ForgotPassword, it never
+// reports whether the account exists.
+func (s *service) SendEmailVerification(ctx context.Context, email string) error {
+	if email == "" {
+		return ErrEmailRequired
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return s.sendEmailCode(ctx, user)
+}
+
+func (s *service) VerifyEmail(ctx context.Context, email, code string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return ErrInvalidCode
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	if err := s.checkOTP(ctx, user.ID, PurposeVerifyEmail, user.Email, code); err != nil {
+		return err
+	}
+	return s.userRepo.MarkEmailVerified(ctx, user.ID)
+}
+
+// SendPhoneVerification sends a code to an account's unverified phone.
+func (s *service) SendPhoneVerification(ctx context.Context, phone string) error {
+	if phone == "" {
+		return ErrPhoneRequired
+	}
+
+	user, err := s.userRepo.GetByPhone(ctx, phone)
+	if err != nil || user.PhoneVerifiedAt != nil {
+		return nil
+	}
+	return s.sendPhoneCode(ctx, user)
+}
+
+func (s *service) VerifyPhone(ctx context.Context, phone, code string) error {
+	user, err := s.userRepo.GetByPhone(ctx, phone)
+	if err != nil {
+		return ErrInvalidCode
+	}
+	if user.PhoneVerifiedAt != nil {
+		return nil
+	}
+
+	if err := s.checkOTP(ctx, user.ID, PurposeVerifyPhone, user.Phone, code); err != nil {
+		return err
+	}
+	return s.userRepo.MarkPhoneVerified(ctx, user.ID)
+}
+
+// identifierVerified reports whether the email or phone the user signed in with has been verified.
+// Emails are matched ignoring case, like GetByEmail finds them.
+func identifierVerified(user *users.User, identifier string) bool {
+	if strings.EqualFold(identifier, user.Email) {
+		return user.EmailVerifiedAt != nil
+	}
+	return user.Phone != "" && identifier == user.Phone && user.PhoneVerifiedAt != nil
+}
+
+func (s *service) sendEmailCode(ctx context.Context, user *users.User) error {
+	if err := s.checkSendLimit(ctx, user.ID, PurposeVerifyEmail); err != nil {
+		return err
+	}
+	code, err := s.issueOTP(ctx, user.ID, PurposeVerifyEmail, user.Email)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Your Keepsy verification code",
+		Body: fmt.Sprintf("Hi %s,\n\nYour Keepsy verification code is %s. It expires in %s.\n",
+			user.Name, code, s.opts.OTPTTL),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+func (s *service) sendPhoneCode(ctx context.Context, user *users.User) error {
+	if err := s.checkSendLimit(ctx, user.ID, PurposeVerifyPhone); err != nil {
+		return err
+	}
+	code, err := s.issueOTP(ctx, user.ID, PurposeVerifyPhone, user.Phone)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Your Keepsy verification code is %s. It expires in %s.", code, s.opts.OTPTTL)
+	if err := s.sms.Send(ctx, user.Phone, body); err != nil {
+		return fmt.Errorf("failed to send verification sms: %w", err)
+	}
+	return nil
+}
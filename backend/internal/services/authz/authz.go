@@ -0,0 +1,26 @@
+package authz
+
+import "errors"
+
+// ErrForbidden is returned when the caller may not access a resource.
+// Handlers map it to 403.
+var ErrForbidden = errors.New("forbidden")
+
+// Owned is a resource that belongs to a single user.
+type Owned interface {
+	OwnerID() int
+}
+
+// Authorize checks that userID may access resource. Every service method that
+// takes a resource by ID goes through here after loading it.
+func Authorize(userID int, resource Owned) error {
+	if userID <= 0 || resource == nil || resource.OwnerID() != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// OwnedBy is an Owned for when only the owner's ID is at hand.
+type OwnedBy int
+
+func (o OwnedBy) OwnerID() int { return int(o) }
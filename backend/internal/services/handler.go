@@ -0,0 +1,146 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/authz"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) ListRecords(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+
+	records, err := h.service.ListRecords(r.Context(), productID, userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to list service records")
+		return
+	}
+
+	json.NewEncoder(w).Encode(records)
+}
+
+func (h *Handler) CreateRecord(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req RecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	record, err := h.service.CreateRecord(r.Context(), productID, userID, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create service record")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(record)
+}
+
+func (h *Handler) UpdateRecord(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("serviceID"))
+	if err != nil {
+		http.Error(w, "Invalid service record ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	record, err := h.service.UpdateRecord(r.Context(), productID, id, userID, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to update service record")
+		return
+	}
+
+	json.NewEncoder(w).Encode(record)
+}
+
+func (h *Handler) DeleteRecord(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("serviceID"))
+	if err != nil {
+		http.Error(w, "Invalid service record ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteRecord(r.Context(), productID, id, userID); err != nil {
+		writeServiceError(w, err, "Failed to delete service record")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CostOfOwnership handles GET /products/{id}/cost.
+func (h *Handler) CostOfOwnership(w http.ResponseWriter, r *http.Request) {
+	userID, productID, ok := productParams(w, r)
+	if !ok {
+		return
+	}
+
+	cost, err := h.service.CostOfOwnership(r.Context(), productID, userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to get cost of ownership")
+		return
+	}
+
+	json.NewEncoder(w).Encode(cost)
+}
+
+// productParams reads the caller and the {id} product path value, writing
+// the error response if either is missing.
+func productParams(w http.ResponseWriter, r *http.Request) (userID, productID int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	productID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, productID, true
+}
+
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrRecordNotFound), errors.Is(err, products.ErrProductNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, ErrInvalidRecord), errors.Is(err, ErrInvoiceNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer is meant for local development.
+// It logs every message and writes it to basePath as a .eml file instead of sending it.
+type FileMailer struct {
+	basePath string
+}
+
+func NewFileMailer(basePath string) (*FileMailer, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileMailer{basePath: basePath}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	// Format: timestamp_recipient.eml
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+
+	if err := os.WriteFile(filepath.Join(m.basePath, name), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+
+	log.Printf("mail: to=%s subject=%q saved=%s", msg.To, msg.Subject, name)
+	return nil
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,17 @@
+package mail
+
+import (
+	"context"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // Plain text
+}
+
+// Mailer defines the interface for sending email.
+// This allows switching between SMTP, a local file drop, or a provider API.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
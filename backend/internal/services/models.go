@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// Service record types
+const (
+	TypeRepair          = "repair"
+	TypeServicing       = "servicing"
+	TypePartReplacement = "part_replacement"
+	TypeVisit           = "technician_visit"
+)
+
+// Record is one entry in a product's maintenance history.
+type Record struct {
+	ID         int       `json:"id"`
+	ProductID  int       `json:"product_id"`
+	Type       string    `json:"type"`
+	Date       time.Time `json:"date"`
+	Vendor     string    `json:"vendor,omitempty"`
+	Cost       *float64  `json:"cost,omitempty"`
+	Notes      string    `json:"notes,omitempty"`
+	InvoiceIDs []int     `json:"invoice_ids"` // Bills of the same product
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// RecordRequest creates or replaces a record.
+type RecordRequest struct {
+	Type       string     `json:"type"`
+	Date       *time.Time `json:"date"`
+	Vendor     string     `json:"vendor,omitempty"`
+	Cost       *float64   `json:"cost,omitempty"`
+	Notes      string     `json:"notes,omitempty"`
+	InvoiceIDs []int      `json:"invoice_ids,omitempty"`
+}
+
+// CostOfOwnership is what a product has cost so far: its purchase price
+// plus everything spent on service.
+type CostOfOwnership struct {
+	ProductID     int     `json:"product_id"`
+	PurchasePrice float64 `json:"purchase_price"`
+	ServiceCost   float64 `json:"service_cost"`
+	ServiceCount  int     `json:"service_count"`
+	Total         float64 `json:"total"`
+}
+
+type Repository interface {
+	// Create saves the record with its invoices.
+	Create(ctx context.Context, record *Record) error
+	GetByID(ctx context.Context, id int) (*Record, error)
+	// ListByProductID returns the product's records, latest first.
+	ListByProductID(ctx context.Context, productID int) ([]*Record, error)
+	// Update saves the record and replaces its invoices.
+	Update(ctx context.Context, record *Record) error
+	Delete(ctx context.Context, id int) error
+	// ServiceCost sums the costs of the product's records.
+	ServiceCost(ctx context.Context, productID int) (total float64, count int, err error)
+}
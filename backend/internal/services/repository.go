@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrRecordNotFound = errors.New("service record not found")
+
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLRepository(db *sql.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+const recordColumns = `id, product_id, type, service_date, COALESCE(vendor, ''), cost, COALESCE(notes, ''), created_at, updated_at`
+
+func (r *MySQLRepository) Create(ctx context.Context, record *Record) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO keepsy_service_records (product_id, type, service_date, vendor, cost, notes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = record.CreatedAt
+
+	result, err := tx.ExecContext(ctx, query,
+		record.ProductID, record.Type, record.Date, record.Vendor, record.Cost, record.Notes,
+		record.CreatedAt, record.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create service record: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	record.ID = int(id)
+
+	if err := saveInvoices(ctx, tx, record); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM keepsy_service_records WHERE id = ?`
+	records, err := r.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return records[0], nil
+}
+
+func (r *MySQLRepository) ListByProductID(ctx context.Context, productID int) ([]*Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM keepsy_service_records WHERE product_id = ? ORDER BY service_date DESC, id DESC`
+	return r.query(ctx, query, productID)
+}
+
+func (r *MySQLRepository) Update(ctx context.Context, record *Record) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE keepsy_service_records
+		SET type = ?, service_date = ?, vendor = ?, cost = ?, notes = ?, updated_at = ?
+		WHERE id = ?
+	`
+	record.UpdatedAt = time.Now()
+
+	_, err = tx.ExecContext(ctx, query,
+		record.Type, record.Date, record.Vendor, record.Cost, record.Notes, record.UpdatedAt, record.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update service record: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_service_record_invoices WHERE service_record_id = ?`, record.ID); err != nil {
+		return fmt.Errorf("failed to delete service record invoices: %w", err)
+	}
+	if err := saveInvoices(ctx, tx, record); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Delete(ctx context.Context, id int) error {
+	// Invoice links cascade; the bills themselves stay with the product
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM keepsy_service_records WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete service record: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) ServiceCost(ctx context.Context, productID int) (float64, int, error) {
+	query := `SELECT COALESCE(SUM(cost), 0), COUNT(*) FROM keepsy_service_records WHERE product_id = ?`
+
+	var total float64
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, productID).Scan(&total, &count); err != nil {
+		return 0, 0, fmt.Errorf("failed to sum service costs: %w", err)
+	}
+	return total, count, nil
+}
+
+// query runs a records query and loads their invoice IDs.
+func (r *MySQLRepository) query(ctx context.Context, query string, args ...any) ([]*Record, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*Record
+	byID := make(map[int]*Record)
+	for rows.Next() {
+		rec := &Record{InvoiceIDs: []int{}}
+		if err := rows.Scan(
+			&rec.ID, &rec.ProductID, &rec.Type, &rec.Date, &rec.Vendor, &rec.Cost, &rec.Notes,
+			&rec.CreatedAt, &rec.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan service record: %w", err)
+		}
+		records = append(records, rec)
+		byID[rec.ID] = rec
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query service records: %w", err)
+	}
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	// All records of a query belong to the same product
+	invoiceQuery := `
+		SELECT i.service_record_id, i.bill_id
+		FROM keepsy_service_record_invoices i
+		JOIN keepsy_service_records s ON s.id = i.service_record_id
+		WHERE s.product_id = ?
+		ORDER BY i.bill_id
+	`
+	invoiceRows, err := r.db.QueryContext(ctx, invoiceQuery, records[0].ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service record invoices: %w", err)
+	}
+	defer invoiceRows.Close()
+
+	for invoiceRows.Next() {
+		var recordID, billID int
+		if err := invoiceRows.Scan(&recordID, &billID); err != nil {
+			return nil, fmt.Errorf("failed to scan service record invoice: %w", err)
+		}
+		if rec, ok := byID[recordID]; ok {
+			rec.InvoiceIDs = append(rec.InvoiceIDs, billID)
+		}
+	}
+	return records, invoiceRows.Err()
+}
+
+func saveInvoices(ctx context.Context, tx *sql.Tx, record *Record) error {
+	for _, billID := range record.InvoiceIDs {
+		query := `INSERT INTO keepsy_service_record_invoices (service_record_id, bill_id) VALUES (?, ?)`
+		if _, err := tx.ExecContext(ctx, query, record.ID, billID); err != nil {
+			return fmt.Errorf("failed to attach invoice: %w", err)
+		}
+	}
+	return nil
+}
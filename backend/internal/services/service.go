@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
+	"strings"
+)
+
+var (
+	ErrInvalidRecord   = errors.New("invalid service record")
+	ErrInvoiceNotFound = errors.New("invoice not found")
+)
+
+type Service interface {
+	ListRecords(ctx context.Context, productID, userID int) ([]*Record, error)
+	CreateRecord(ctx context.Context, productID, userID int, req RecordRequest) (*Record, error)
+	// UpdateRecord replaces all fields of a record, including its invoices.
+	UpdateRecord(ctx context.Context, productID, id, userID int, req RecordRequest) (*Record, error)
+	DeleteRecord(ctx context.Context, productID, id, userID int) error
+	// CostOfOwnership sums the product's purchase price and service costs.
+	CostOfOwnership(ctx context.Context, productID, userID int) (*CostOfOwnership, error)
+}
+
+type service struct {
+	repo        Repository
+	productRepo products.Repository
+	billRepo    bills.Repository
+}
+
+func NewService(repo Repository, productRepo products.Repository, billRepo bills.Repository) Service {
+	return &service{
+		repo:        repo,
+		productRepo: productRepo,
+		billRepo:    billRepo,
+	}
+}
+
+func (s *service) ListRecords(ctx context.Context, productID, userID int) ([]*Record, error) {
+	if _, err := s.ownedProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+	records, err := s.repo.ListByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []*Record{}
+	}
+	return records, nil
+}
+
+func (s *service) CreateRecord(ctx context.Context, productID, userID int, req RecordRequest) (*Record, error) {
+	if _, err := s.ownedProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+
+	record := &Record{ProductID: productID}
+	if err := s.apply(ctx, record, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *service) UpdateRecord(ctx context.Context, productID, id, userID int, req RecordRequest) (*Record, error) {
+	record, err := s.ownedRecord(ctx, productID, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apply(ctx, record, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *service) DeleteRecord(ctx context.Context, productID, id, userID int) error {
+	if _, err := s.ownedRecord(ctx, productID, id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *service) CostOfOwnership(ctx context.Context, productID, userID int) (*CostOfOwnership, error) {
+	product, err := s.ownedProduct(ctx, productID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceCost, count, err := s.repo.ServiceCost(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := &CostOfOwnership{
+		ProductID:    productID,
+		ServiceCost:  serviceCost,
+		ServiceCount: count,
+	}
+	if product.Price != nil {
+		cost.PurchasePrice = *product.Price
+	}
+	cost.Total = cost.PurchasePrice + cost.ServiceCost
+	return cost, nil
+}
+
+func (s *service) ownedProduct(ctx context.Context, productID, userID int) (*products.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(userID, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// ownedRecord loads a record of the product after authorizing userID to
+// access the product.
+func (s *service) ownedRecord(ctx context.Context, productID, id, userID int) (*Record, error) {
+	if _, err := s.ownedProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+	record, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.ProductID != productID {
+		return nil, ErrRecordNotFound
+	}
+	return record, nil
+}
+
+// apply validates req and copies it onto record.
+func (s *service) apply(ctx context.Context, record *Record, req RecordRequest) error {
+	switch req.Type {
+	case TypeRepair, TypeServicing, TypePartReplacement, TypeVisit:
+	default:
+		return fmt.Errorf("%w: type must be repair, servicing, part_replacement or technician_visit", ErrInvalidRecord)
+	}
+	if req.Date == nil {
+		return fmt.Errorf("%w: date is required", ErrInvalidRecord)
+	}
+	if req.Cost != nil && *req.Cost < 0 {
+		return fmt.Errorf("%w: cost can't be negative", ErrInvalidRecord)
+	}
+
+	// Invoices are bills uploaded for the same product
+	invoiceIDs := []int{}
+	seen := make(map[int]bool)
+	for _, id := range req.InvoiceIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		bill, err := s.billRepo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, bills.ErrBillNotFound) {
+				return ErrInvoiceNotFound
+			}
+			return err
+		}
+		if bill.ProductID != record.ProductID {
+			return ErrInvoiceNotFound
+		}
+		invoiceIDs = append(invoiceIDs, id)
+	}
+
+	record.Type = req.Type
+	record.Date = *req.Date
+	record.Vendor = strings.TrimSpace(req.Vendor)
+	record.Cost = req.Cost
+	record.Notes = strings.TrimSpace(req.Notes)
+	record.InvoiceIDs = invoiceIDs
+	return nil
+}
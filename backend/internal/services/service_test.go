@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"keepsy-backend/internal/bills"
+	"keepsy-backend/internal/products"
+	"keepsy-backend/internal/services/authz"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Create(ctx context.Context, record *Record) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetByID(ctx context.Context, id int) (*Record, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Record), args.Error(1)
+}
+
+func (m *MockRepo) ListByProductID(ctx context.Context, productID int) ([]*Record, error) {
+	args := m.Called(ctx, productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Record), args.Error(1)
+}
+
+func (m *MockRepo) Update(ctx context.Context, record *Record) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ServiceCost(ctx context.Context, productID int) (float64, int, error) {
+	args := m.Called(ctx, productID)
+	return args.Get(0).(float64), args.Int(1), args.Error(2)
+}
+
+// productRepo serves a fixed set of products; other methods are unused.
+type productRepo struct {
+	products.Repository
+	byID map[int]*products.Product
+}
+
+func (r productRepo) GetByID(ctx context.Context, id int) (*products.Product, error) {
+	if p, ok := r.byID[id]; ok {
+		return p, nil
+	}
+	return nil, products.ErrProductNotFound
+}
+
+// billRepo serves a fixed set of bills; other methods are unused.
+type billRepo struct {
+	bills.Repository
+	byID map[int]*bills.Bill
+}
+
+func (r billRepo) GetByID(ctx context.Context, id int) (*bills.Bill, error) {
+	if b, ok := r.byID[id]; ok {
+		return b, nil
+	}
+	return nil, bills.ErrBillNotFound
+}
+
+// newTestService returns a service where user 1 owns product 10 (bill 5)
+// and user 2 owns product 20 (bill 6).
+func newTestService(repo Repository) Service {
+	price := 30000.0
+	return NewService(repo,
+		productRepo{byID: map[int]*products.Product{
+			10: {ID: 10, UserID: 1, Price: &price},
+			20: {ID: 20, UserID: 2},
+		}},
+		billRepo{byID: map[int]*bills.Bill{
+			5: {ID: 5, ProductID: 10},
+			6: {ID: 6, ProductID: 20},
+		}},
+	)
+}
+
+func date(y int, m time.Month, d int) *time.Time {
+	t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func cost(v float64) *float64 {
+	return &v
+}
+
+func TestCreateRecord(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("Create", ctx, mock.AnythingOfType("*services.Record")).Return(nil)
+
+		record, err := service.CreateRecord(ctx, 10, 1, RecordRequest{
+			Type: TypeRepair, Date: date(2026, 3, 2), Vendor: " FixIt ", Cost: cost(1500),
+			InvoiceIDs: []int{5, 5},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 10, record.ProductID)
+		assert.Equal(t, "FixIt", record.Vendor)
+		assert.Equal(t, []int{5}, record.InvoiceIDs)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := map[string]RecordRequest{
+			"type":          {Type: "cleaning", Date: date(2026, 3, 2)},
+			"missing date":  {Type: TypeServicing},
+			"negative cost": {Type: TypeServicing, Date: date(2026, 3, 2), Cost: cost(-1)},
+		}
+		for name, req := range cases {
+			service := newTestService(new(MockRepo))
+
+			_, err := service.CreateRecord(ctx, 10, 1, req)
+
+			assert.ErrorIs(t, err, ErrInvalidRecord, name)
+		}
+	})
+
+	t.Run("Invoice Of Another Product", func(t *testing.T) {
+		service := newTestService(new(MockRepo))
+
+		_, err := service.CreateRecord(ctx, 10, 1, RecordRequest{
+			Type: TypeRepair, Date: date(2026, 3, 2), InvoiceIDs: []int{6},
+		})
+
+		assert.ErrorIs(t, err, ErrInvoiceNotFound)
+	})
+
+	t.Run("Other User's Product", func(t *testing.T) {
+		service := newTestService(new(MockRepo))
+
+		_, err := service.CreateRecord(ctx, 20, 1, RecordRequest{Type: TypeRepair, Date: date(2026, 3, 2)})
+
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+	})
+}
+
+func TestUpdateRecord(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("GetByID", ctx, 3).Return(&Record{ID: 3, ProductID: 10, Type: TypeRepair, InvoiceIDs: []int{5}}, nil)
+		repo.On("Update", ctx, mock.AnythingOfType("*services.Record")).Return(nil)
+
+		record, err := service.UpdateRecord(ctx, 10, 3, 1, RecordRequest{Type: TypeServicing, Date: date(2026, 4, 1)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, TypeServicing, record.Type)
+		assert.Empty(t, record.InvoiceIDs)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Record Of Another Product", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("GetByID", ctx, 3).Return(&Record{ID: 3, ProductID: 20}, nil)
+
+		_, err := service.UpdateRecord(ctx, 10, 3, 1, RecordRequest{Type: TypeServicing, Date: date(2026, 4, 1)})
+
+		assert.ErrorIs(t, err, ErrRecordNotFound)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteRecord(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("GetByID", ctx, 3).Return(&Record{ID: 3, ProductID: 10}, nil)
+		repo.On("Delete", ctx, 3).Return(nil)
+
+		err := service.DeleteRecord(ctx, 10, 3, 1)
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Other User", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+
+		err := service.DeleteRecord(ctx, 10, 3, 2)
+
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCostOfOwnership(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Price And Services", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("ServiceCost", ctx, 10).Return(2250.5, 3, nil)
+
+		total, err := service.CostOfOwnership(ctx, 10, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 30000.0, total.PurchasePrice)
+		assert.Equal(t, 2250.5, total.ServiceCost)
+		assert.Equal(t, 3, total.ServiceCount)
+		assert.Equal(t, 32250.5, total.Total)
+	})
+
+	t.Run("Without Price", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("ServiceCost", ctx, 20).Return(0.0, 0, nil)
+
+		total, err := service.CostOfOwnership(ctx, 20, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, total.Total)
+	})
+
+	t.Run("Missing Product", func(t *testing.T) {
+		service := newTestService(new(MockRepo))
+
+		_, err := service.CostOfOwnership(ctx, 99, 1)
+
+		assert.ErrorIs(t, err, products.ErrProductNotFound)
+	})
+}
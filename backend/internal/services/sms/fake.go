@@ -0,0 +1,40 @@
+package sms
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+type Message struct {
+	To   string
+	Body string
+}
+
+// FakeSender is meant for local runs and tests.
+// It logs every message and keeps it in memory instead of sending it.
+type FakeSender struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewFakeSender() *FakeSender {
+	return &FakeSender{}
+}
+
+func (s *FakeSender) Send(ctx context.Context, to, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, Message{To: to, Body: body})
+	log.Printf("sms: to=%s body=%q", to, body)
+	return nil
+}
+
+// Sent returns a copy of every message sent so far.
+func (s *FakeSender) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.sent...)
+}
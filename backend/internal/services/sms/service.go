@@ -0,0 +1,11 @@
+package sms
+
+import (
+	"context"
+)
+
+// Sender defines the interface for sending text messages.
+// This allows switching between a real gateway (Twilio, MSG91, etc.) and the local fake.
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
@@ -2,13 +2,22 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+var (
+	ErrInvalidPath = errors.New("invalid storage path")
+	ErrNotFound    = errors.New("file not found")
+)
+
 type LocalStorage struct {
 	basePath string
 	baseURL  string
@@ -25,15 +34,23 @@ func NewLocalStorage(basePath, baseURL string) (*LocalStorage, error) {
 
 	return &LocalStorage{
 		basePath: basePath,
-		baseURL:  baseURL,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
 	}, nil
 }
 
 func (s *LocalStorage) Upload(ctx context.Context, file io.Reader, filename string) (string, error) {
-	// Generate unique filename to avoid collisions
-	// Format: timestamp_original_filename
-	uniqueName := fmt.Sprintf("%d_%s", time.Now().UnixNano(), filename)
-	filePath := filepath.Join(s.basePath, uniqueName)
+	// Generate unique filename to avoid collisions, keeping the directory
+	// Format: <dir>/timestamp_original_filename
+	dir, name := path.Split(filename)
+	key := fmt.Sprintf("%s%d_%s", dir, time.Now().UnixNano(), name)
+
+	filePath, err := s.filePath(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
 
 	// Create destination file
 	dst, err := os.Create(filePath)
@@ -48,15 +65,14 @@ func (s *LocalStorage) Upload(ctx context.Context, file io.Reader, filename stri
 	}
 
 	// Return public URL (relative or absolute based on baseURL)
-	fileURL := fmt.Sprintf("%s/%s", s.baseURL, uniqueName)
-	return fileURL, nil
+	return s.url(key), nil
 }
 
 func (s *LocalStorage) Delete(ctx context.Context, fileURL string) error {
-	// Extract filename from URL (simple implementation assuming flat structure)
-	// In production, might need more robust parsing logic
-	filename := filepath.Base(fileURL)
-	filePath := filepath.Join(s.basePath, filename)
+	filePath, err := s.filePath(s.key(fileURL))
+	if err != nil {
+		return err
+	}
 
 	if err := os.Remove(filePath); err != nil {
 		if os.IsNotExist(err) {
@@ -68,9 +84,121 @@ func (s *LocalStorage) Delete(ctx context.Context, fileURL string) error {
 	return nil
 }
 
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	dir, _ := path.Split(prefix)
+	root, err := s.filePath(strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // Nothing stored yet
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.basePath, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			urls = append(urls, s.url(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return urls, nil
+}
+
+// Move renames the file on disk. It never overwrites another file, and a file
+// that was already moved counts as success, so an interrupted migration can
+// simply run again.
+func (s *LocalStorage) Move(ctx context.Context, fileURL, filename string) (string, error) {
+	from, err := s.filePath(s.key(fileURL))
+	if err != nil {
+		return "", err
+	}
+	to, err := s.filePath(filename)
+	if err != nil {
+		return "", err
+	}
+	if from == to {
+		return s.url(filename), nil
+	}
+
+	_, fromErr := os.Stat(from)
+	_, toErr := os.Stat(to)
+	switch {
+	case os.IsNotExist(fromErr) && toErr == nil:
+		return s.url(filename), nil
+	case fromErr != nil:
+		return "", fmt.Errorf("failed to find file: %w", fromErr)
+	case toErr == nil:
+		return "", fmt.Errorf("failed to move file: %s already exists", filename)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return "", fmt.Errorf("failed to move file: %w", err)
+	}
+	return s.url(filename), nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, fileURL string) (io.ReadSeekCloser, error) {
+	filePath, err := s.filePath(s.key(fileURL))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only regular files, so a directory can't be listed
+	if info, err := os.Stat(filePath); err != nil || !info.Mode().IsRegular() {
+		if err == nil || os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find file: %w", err)
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
 func (s *LocalStorage) GetDownloadURL(ctx context.Context, fileURL string) (string, error) {
 	// For local storage, the fileURL stored in DB is already the public URL
 	// So we just return it.
 	// If we stored relative paths, we would append baseURL here.
 	return fileURL, nil
 }
+
+func (s *LocalStorage) url(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// key returns the path of a file below basePath from its public URL.
+func (s *LocalStorage) key(fileURL string) string {
+	if key, ok := strings.CutPrefix(fileURL, s.baseURL+"/"); ok {
+		return key
+	}
+	// Not one of our URLs, fall back to the flat layout of old uploads
+	return path.Base(fileURL)
+}
+
+// filePath maps a key to a file on disk, refusing keys that would escape basePath.
+func (s *LocalStorage) filePath(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, key)
+	}
+	return filepath.Join(s.basePath, filepath.FromSlash(clean)), nil
+}
@@ -14,8 +14,20 @@ type Service interface {
 	// Delete removes the file from storage.
 	Delete(ctx context.Context, url string) error
 
+	// List returns the URLs of all files whose path starts with prefix
+	// (e.g. "<uuid>/").
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Move relocates a stored file to filename (a path like Upload takes,
+	// used as is) and returns its new URL.
+	Move(ctx context.Context, url, filename string) (string, error)
+
+	// Open returns the file for reading, or ErrNotFound if nothing is
+	// stored at url.
+	Open(ctx context.Context, url string) (io.ReadSeekCloser, error)
+
 	// GetDownloadURL returns a URL to download the file.
-	// For Local: Returns the public static URL.
+	// For Local: Returns the stored URL, which the API serves to the file's owner.
 	// For S3: Returns a presigned URL.
 	GetDownloadURL(ctx context.Context, url string) (string, error)
 }
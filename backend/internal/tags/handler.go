@@ -0,0 +1,182 @@
+package tags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"keepsy-backend/internal/services/auth"
+	"keepsy-backend/internal/services/authz"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.service.ListTags(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err, "Failed to fetch tags")
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	tag, err := h.service.CreateTag(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create tag")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+func (h *Handler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := tagParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	tag, err := h.service.RenameTag(r.Context(), id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to rename tag")
+		return
+	}
+
+	json.NewEncoder(w).Encode(tag)
+}
+
+// MergeTags handles POST /tags/{id}/merge.
+func (h *Handler) MergeTags(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := tagParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	tag, err := h.service.MergeTags(r.Context(), id, req)
+	if err != nil {
+		writeServiceError(w, err, "Failed to merge tags")
+		return
+	}
+
+	json.NewEncoder(w).Encode(tag)
+}
+
+func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := tagParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteTag(r.Context(), id, userID); err != nil {
+		writeServiceError(w, err, "Failed to delete tag")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AttachTags handles POST /tags/attach.
+func (h *Handler) AttachTags(w http.ResponseWriter, r *http.Request) {
+	h.bulk(w, r, h.service.AttachTags, "Failed to attach tags")
+}
+
+// DetachTags handles POST /tags/detach.
+func (h *Handler) DetachTags(w http.ResponseWriter, r *http.Request) {
+	h.bulk(w, r, h.service.DetachTags, "Failed to detach tags")
+}
+
+func (h *Handler) bulk(w http.ResponseWriter, r *http.Request, apply func(context.Context, BulkRequest) error, fallback string) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.UserID = userID
+
+	if err := apply(r.Context(), req); err != nil {
+		writeServiceError(w, err, fallback)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tagParams reads the caller and the {id} path value, writing the error
+// response if either is missing.
+func tagParams(w http.ResponseWriter, r *http.Request) (userID, id int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrTagNotFound), errors.Is(err, ErrProductNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, ErrTagExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrNameRequired), errors.Is(err, ErrNameTooLong), errors.Is(err, ErrInvalidMerge),
+		errors.Is(err, ErrInvalidBulk):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
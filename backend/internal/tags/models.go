@@ -0,0 +1,64 @@
+package tags
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// MaxNameLength is the longest tag name, in characters.
+	MaxNameLength = 50
+	// MaxBulkProducts is how many products one attach or detach can touch.
+	MaxBulkProducts = 500
+)
+
+type Tag struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"-"`
+	Name         string    `json:"name"`
+	ProductCount int       `json:"product_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OwnerID implements authz.Owned.
+func (t *Tag) OwnerID() int { return t.UserID }
+
+// TagRequest creates or renames a tag.
+type TagRequest struct {
+	UserID int    `json:"-"` // From Context/Auth
+	Name   string `json:"name"`
+}
+
+// MergeRequest moves the products of the source tags to the target tag and
+// deletes the sources.
+type MergeRequest struct {
+	UserID    int   `json:"-"` // From Context/Auth
+	SourceIDs []int `json:"source_ids"`
+}
+
+// BulkRequest attaches or detaches every tag to or from every product.
+type BulkRequest struct {
+	UserID     int   `json:"-"` // From Context/Auth
+	TagIDs     []int `json:"tag_ids"`
+	ProductIDs []int `json:"product_ids"`
+}
+
+type Repository interface {
+	// Create returns ErrTagExists if the user has a tag with the name.
+	Create(ctx context.Context, tag *Tag) error
+	GetByID(ctx context.Context, id int) (*Tag, error)
+	// ListByUserID returns the user's tags by name, with their product
+	// counts.
+	ListByUserID(ctx context.Context, userID int) ([]*Tag, error)
+	// Rename returns ErrTagExists if the user has another tag with the name.
+	Rename(ctx context.Context, id int, name string) error
+	// Merge moves the products of the source tags to the target and deletes
+	// the sources, in one transaction.
+	Merge(ctx context.Context, targetID int, sourceIDs []int) error
+	Delete(ctx context.Context, id int) error
+	// Attach links every tag to every product, skipping existing links.
+	Attach(ctx context.Context, tagIDs, productIDs []int) error
+	Detach(ctx context.Context, tagIDs, productIDs []int) error
+	// ProductOwners returns the user ID of each product that exists.
+	ProductOwners(ctx context.Context, productIDs []int) (map[int]int, error)
+}
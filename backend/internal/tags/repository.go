@@ -0,0 +1,210 @@
+package tags
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrTagNotFound     = errors.New("tag not found")
+	ErrTagExists       = errors.New("a tag with this name already exists")
+	ErrProductNotFound = errors.New("product not found")
+)
+
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLRepository(db *sql.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+const tagColumns = `t.id, t.user_id, t.name, (SELECT COUNT(*) FROM keepsy_product_tags pt WHERE pt.tag_id = t.id), t.created_at`
+
+func scanTag(row interface{ Scan(...any) error }) (*Tag, error) {
+	var t Tag
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.ProductCount, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *MySQLRepository) Create(ctx context.Context, tag *Tag) error {
+	tag.CreatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO keepsy_tags (user_id, name, created_at) VALUES (?, ?, ?)`,
+		tag.UserID, tag.Name, tag.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicate(err) {
+			return ErrTagExists
+		}
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	tag.ID = int(id)
+	return nil
+}
+
+func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM keepsy_tags t WHERE t.id = ?`
+	tag, err := scanTag(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTagNotFound
+		}
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return tag, nil
+}
+
+func (r *MySQLRepository) ListByUserID(ctx context.Context, userID int) ([]*Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM keepsy_tags t WHERE t.user_id = ? ORDER BY t.name ASC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (r *MySQLRepository) Rename(ctx context.Context, id int, name string) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE keepsy_tags SET name = ? WHERE id = ?`, name, id); err != nil {
+		if isDuplicate(err) {
+			return ErrTagExists
+		}
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Merge(ctx context.Context, targetID int, sourceIDs []int) error {
+	if len(sourceIDs) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	in, args := inList(sourceIDs)
+	query := `
+		INSERT IGNORE INTO keepsy_product_tags (product_id, tag_id)
+		SELECT DISTINCT product_id, ? FROM keepsy_product_tags WHERE tag_id IN ` + in
+	if _, err := tx.ExecContext(ctx, query, append([]any{targetID}, args...)...); err != nil {
+		return fmt.Errorf("failed to merge tags: %w", err)
+	}
+
+	// Their links cascade
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keepsy_tags WHERE id IN `+in, args...); err != nil {
+		return fmt.Errorf("failed to delete merged tags: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Delete(ctx context.Context, id int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM keepsy_tags WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Attach(ctx context.Context, tagIDs, productIDs []int) error {
+	if len(tagIDs) == 0 || len(productIDs) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(tagIDs)*len(productIDs))
+	args := make([]any, 0, 2*cap(values))
+	for _, productID := range productIDs {
+		for _, tagID := range tagIDs {
+			values = append(values, "(?, ?)")
+			args = append(args, productID, tagID)
+		}
+	}
+
+	query := `INSERT IGNORE INTO keepsy_product_tags (product_id, tag_id) VALUES ` + strings.Join(values, ", ")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to attach tags: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Detach(ctx context.Context, tagIDs, productIDs []int) error {
+	if len(tagIDs) == 0 || len(productIDs) == 0 {
+		return nil
+	}
+
+	tagsIn, tagArgs := inList(tagIDs)
+	productsIn, productArgs := inList(productIDs)
+	query := `DELETE FROM keepsy_product_tags WHERE tag_id IN ` + tagsIn + ` AND product_id IN ` + productsIn
+	if _, err := r.db.ExecContext(ctx, query, append(tagArgs, productArgs...)...); err != nil {
+		return fmt.Errorf("failed to detach tags: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) ProductOwners(ctx context.Context, productIDs []int) (map[int]int, error) {
+	owners := make(map[int]int, len(productIDs))
+	if len(productIDs) == 0 {
+		return owners, nil
+	}
+
+	in, args := inList(productIDs)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id FROM keepsy_products WHERE id IN `+in, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product owners: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, userID int
+		if err := rows.Scan(&id, &userID); err != nil {
+			return nil, fmt.Errorf("failed to scan product owner: %w", err)
+		}
+		owners[id] = userID
+	}
+	return owners, rows.Err()
+}
+
+// inList returns "(?, ?, ...)" for the IDs, with the IDs as arguments.
+func inList(ids []int) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "(?" + strings.Repeat(", ?", len(ids)-1) + ")", args
+}
+
+// isDuplicate reports a unique key violation; (user_id, name) is the only
+// unique key.
+func isDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -0,0 +1,192 @@
+package tags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/services/authz"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrNameRequired = errors.New("tag name is required")
+	ErrNameTooLong  = fmt.Errorf("tag name can be at most %d characters", MaxNameLength)
+	ErrInvalidMerge = errors.New("source_ids must list other tags")
+	ErrInvalidBulk  = fmt.Errorf("tag_ids and product_ids are required, with at most %d products", MaxBulkProducts)
+)
+
+type Service interface {
+	ListTags(ctx context.Context, userID int) ([]*Tag, error)
+	CreateTag(ctx context.Context, req TagRequest) (*Tag, error)
+	RenameTag(ctx context.Context, id int, req TagRequest) (*Tag, error)
+	// MergeTags merges the source tags into tag id and returns it.
+	MergeTags(ctx context.Context, id int, req MergeRequest) (*Tag, error)
+	DeleteTag(ctx context.Context, id, userID int) error
+	AttachTags(ctx context.Context, req BulkRequest) error
+	DetachTags(ctx context.Context, req BulkRequest) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) ListTags(ctx context.Context, userID int) ([]*Tag, error) {
+	tags, err := s.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		tags = []*Tag{}
+	}
+	return tags, nil
+}
+
+func (s *service) CreateTag(ctx context.Context, req TagRequest) (*Tag, error) {
+	name, err := normalizeName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &Tag{UserID: req.UserID, Name: name}
+	if err := s.repo.Create(ctx, tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *service) RenameTag(ctx context.Context, id int, req TagRequest) (*Tag, error) {
+	tag, err := s.ownedTag(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	name, err := normalizeName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Rename(ctx, id, name); err != nil {
+		return nil, err
+	}
+	tag.Name = name
+	return tag, nil
+}
+
+func (s *service) MergeTags(ctx context.Context, id int, req MergeRequest) (*Tag, error) {
+	if _, err := s.ownedTag(ctx, id, req.UserID); err != nil {
+		return nil, err
+	}
+
+	sourceIDs := unique(req.SourceIDs)
+	if len(sourceIDs) == 0 {
+		return nil, ErrInvalidMerge
+	}
+	for _, sourceID := range sourceIDs {
+		if sourceID == id {
+			return nil, ErrInvalidMerge
+		}
+		if _, err := s.ownedTag(ctx, sourceID, req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Merge(ctx, id, sourceIDs); err != nil {
+		return nil, err
+	}
+	// Reload for the merged product count
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *service) DeleteTag(ctx context.Context, id, userID int) error {
+	if _, err := s.ownedTag(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *service) AttachTags(ctx context.Context, req BulkRequest) error {
+	tagIDs, productIDs, err := s.checkBulk(ctx, req)
+	if err != nil {
+		return err
+	}
+	return s.repo.Attach(ctx, tagIDs, productIDs)
+}
+
+func (s *service) DetachTags(ctx context.Context, req BulkRequest) error {
+	tagIDs, productIDs, err := s.checkBulk(ctx, req)
+	if err != nil {
+		return err
+	}
+	return s.repo.Detach(ctx, tagIDs, productIDs)
+}
+
+// checkBulk authorizes the user to use all tags and products of req and
+// returns their IDs without duplicates.
+func (s *service) checkBulk(ctx context.Context, req BulkRequest) (tagIDs, productIDs []int, err error) {
+	tagIDs, productIDs = unique(req.TagIDs), unique(req.ProductIDs)
+	if len(tagIDs) == 0 || len(productIDs) == 0 || len(productIDs) > MaxBulkProducts {
+		return nil, nil, ErrInvalidBulk
+	}
+
+	for _, id := range tagIDs {
+		if _, err := s.ownedTag(ctx, id, req.UserID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	owners, err := s.repo.ProductOwners(ctx, productIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, id := range productIDs {
+		owner, ok := owners[id]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %d", ErrProductNotFound, id)
+		}
+		if err := authz.Authorize(req.UserID, authz.OwnedBy(owner)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return tagIDs, productIDs, nil
+}
+
+// ownedTag loads a tag and authorizes userID to access it.
+func (s *service) ownedTag(ctx context.Context, id, userID int) (*Tag, error) {
+	tag, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(userID, tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// normalizeName trims the name and collapses runs of whitespace.
+func normalizeName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	switch {
+	case name == "":
+		return "", ErrNameRequired
+	case utf8.RuneCountInString(name) > MaxNameLength:
+		return "", ErrNameTooLong
+	}
+	return name, nil
+}
+
+// unique returns ids without duplicates, in their first order.
+func unique(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	out := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
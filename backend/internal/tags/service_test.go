@@ -0,0 +1,242 @@
+package tags
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"keepsy-backend/internal/services/authz"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Create(ctx context.Context, tag *Tag) error {
+	args := m.Called(ctx, tag)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetByID(ctx context.Context, id int) (*Tag, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Tag), args.Error(1)
+}
+
+func (m *MockRepo) ListByUserID(ctx context.Context, userID int) ([]*Tag, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Tag), args.Error(1)
+}
+
+func (m *MockRepo) Rename(ctx context.Context, id int, name string) error {
+	args := m.Called(ctx, id, name)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Merge(ctx context.Context, targetID int, sourceIDs []int) error {
+	args := m.Called(ctx, targetID, sourceIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Attach(ctx context.Context, tagIDs, productIDs []int) error {
+	args := m.Called(ctx, tagIDs, productIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Detach(ctx context.Context, tagIDs, productIDs []int) error {
+	args := m.Called(ctx, tagIDs, productIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ProductOwners(ctx context.Context, productIDs []int) (map[int]int, error) {
+	args := m.Called(ctx, productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+// mockTags sets up user 1's "gift" (1) and "office" (2), and user 2's
+// "mine" (3).
+func mockTags(repo *MockRepo) {
+	for _, tag := range []*Tag{
+		{ID: 1, UserID: 1, Name: "gift"},
+		{ID: 2, UserID: 1, Name: "office"},
+		{ID: 3, UserID: 2, Name: "mine"},
+	} {
+		repo.On("GetByID", mock.Anything, tag.ID).Return(tag, nil).Maybe()
+	}
+	repo.On("GetByID", mock.Anything, mock.Anything).Return(nil, ErrTagNotFound).Maybe()
+}
+
+func TestCreateTag(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		repo.On("Create", ctx, mock.MatchedBy(func(tag *Tag) bool {
+			return tag.UserID == 1 && tag.Name == "needs repair"
+		})).Return(nil)
+
+		tag, err := service.CreateTag(ctx, TagRequest{UserID: 1, Name: "  needs   repair "})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "needs repair", tag.Name)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Name", func(t *testing.T) {
+		service := NewService(new(MockRepo))
+
+		_, err := service.CreateTag(ctx, TagRequest{UserID: 1, Name: " "})
+		assert.Equal(t, ErrNameRequired, err)
+
+		_, err = service.CreateTag(ctx, TagRequest{UserID: 1, Name: strings.Repeat("é", MaxNameLength+1)})
+		assert.Equal(t, ErrNameTooLong, err)
+	})
+}
+
+func TestRenameTag(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Name Taken", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+		repo.On("Rename", ctx, 1, "office").Return(ErrTagExists)
+
+		_, err := service.RenameTag(ctx, 1, TagRequest{UserID: 1, Name: "office"})
+
+		assert.Equal(t, ErrTagExists, err)
+	})
+
+	t.Run("Other User", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+
+		_, err := service.RenameTag(ctx, 3, TagRequest{UserID: 1, Name: "ours"})
+
+		assert.Equal(t, authz.ErrForbidden, err)
+		repo.AssertNotCalled(t, "Rename", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestMergeTags(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+		repo.On("Merge", ctx, 1, []int{2}).Return(nil)
+
+		_, err := service.MergeTags(ctx, 1, MergeRequest{UserID: 1, SourceIDs: []int{2, 2}})
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Into Itself", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+
+		_, err := service.MergeTags(ctx, 1, MergeRequest{UserID: 1, SourceIDs: []int{2, 1}})
+
+		assert.Equal(t, ErrInvalidMerge, err)
+		repo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Other Users Source", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+
+		_, err := service.MergeTags(ctx, 1, MergeRequest{UserID: 1, SourceIDs: []int{3}})
+
+		assert.Equal(t, authz.ErrForbidden, err)
+	})
+}
+
+func TestAttachTags(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+		repo.On("ProductOwners", ctx, []int{10, 11}).Return(map[int]int{10: 1, 11: 1}, nil)
+		repo.On("Attach", ctx, []int{1, 2}, []int{10, 11}).Return(nil)
+
+		err := service.AttachTags(ctx, BulkRequest{UserID: 1, TagIDs: []int{1, 2, 1}, ProductIDs: []int{10, 11, 10}})
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Other Users Product", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+		repo.On("ProductOwners", ctx, []int{10, 20}).Return(map[int]int{10: 1, 20: 2}, nil)
+
+		err := service.AttachTags(ctx, BulkRequest{UserID: 1, TagIDs: []int{1}, ProductIDs: []int{10, 20}})
+
+		assert.Equal(t, authz.ErrForbidden, err)
+		repo.AssertNotCalled(t, "Attach", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Missing Product", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := NewService(repo)
+		mockTags(repo)
+		repo.On("ProductOwners", ctx, []int{99}).Return(map[int]int{}, nil)
+
+		err := service.AttachTags(ctx, BulkRequest{UserID: 1, TagIDs: []int{1}, ProductIDs: []int{99}})
+
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		service := NewService(new(MockRepo))
+		tooMany := make([]int, MaxBulkProducts+1)
+		for i := range tooMany {
+			tooMany[i] = i + 1
+		}
+
+		for _, req := range []BulkRequest{
+			{UserID: 1, ProductIDs: []int{10}},
+			{UserID: 1, TagIDs: []int{1}},
+			{UserID: 1, TagIDs: []int{1}, ProductIDs: tooMany},
+		} {
+			assert.Equal(t, ErrInvalidBulk, service.AttachTags(ctx, req))
+		}
+	})
+}
+
+func TestDetachTags(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	service := NewService(repo)
+	mockTags(repo)
+
+	err := service.DetachTags(ctx, BulkRequest{UserID: 1, TagIDs: []int{1, 3}, ProductIDs: []int{10}})
+
+	assert.Equal(t, authz.ErrForbidden, err)
+	repo.AssertNotCalled(t, "Detach", mock.Anything, mock.Anything, mock.Anything)
+}
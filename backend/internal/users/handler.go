@@ -0,0 +1,108 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type Handler struct {
+	service Service
+	userID  func(ctx context.Context) (int, bool)
+}
+
+// NewHandler takes the function that reads the signed-in user from the request
+// context (auth.UserIDFromContext); auth imports this package, so we can't.
+func NewHandler(service Service, userID func(ctx context.Context) (int, bool)) *Handler {
+	return &Handler{service: service, userID: userID}
+}
+
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.service.GetProfile(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get profile", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.UpdateProfile(r.Context(), userID, req)
+	if err != nil {
+		switch err {
+		case ErrEmailTaken:
+			writeError(w, http.StatusConflict, "email_taken", err.Error())
+		case ErrPhoneTaken:
+			writeError(w, http.StatusConflict, "phone_taken", err.Error())
+		case ErrNameRequired, ErrEmailRequired, ErrInvalidEmail:
+			writeError(w, http.StatusBadRequest, "invalid_profile", err.Error())
+		default:
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *Handler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Limit upload size to 5MB
+	if err := r.ParseMultipartForm(5 << 20); err != nil {
+		http.Error(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	user, err := h.service.UploadAvatar(r.Context(), userID, file, header.Filename)
+	if err != nil {
+		if err == ErrUnsupportedAvatar {
+			writeError(w, http.StatusBadRequest, "unsupported_avatar", err.Error())
+			return
+		}
+		http.Error(w, "Failed to upload avatar", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(user)
+}
+
+// writeError sends a JSON error body with a machine-readable code.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   code,
+		"message": message,
+	})
+}
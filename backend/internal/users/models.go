@@ -2,23 +2,79 @@ package users
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type User struct {
-	ID        int       `json:"id"`
-	UUID      string    `json:"uuid"` // Unique identifier (v5 UUID specific to Name + Phone)
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone,omitempty"`
+	ID    int    `json:"id"`
+	UUID  string `json:"uuid"` // Random v7 UUID, also the prefix of the user's stored files
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone,omitempty"`
+
+	AvatarURL string `json:"avatar_url,omitempty"`
+	Role      string `json:"role"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var (
+	ErrNotFound   = errors.New("user not found")
+	ErrEmailTaken = errors.New("email is already in use")
+	ErrPhoneTaken = errors.New("phone is already in use")
+)
+
+// UpdateProfileRequest is a partial update: fields left out stay unchanged.
+// An empty phone removes it, name and email can't be empty.
+type UpdateProfileRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+	Phone *string `json:"phone"`
+}
+
 type Repository interface {
 	Create(ctx context.Context, user *User) error
 	GetByID(ctx context.Context, id int) (*User, error)
 	GetByUUID(ctx context.Context, uuid string) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	GetByPhone(ctx context.Context, phone string) (*User, error)
+	MarkEmailVerified(ctx context.Context, id int) error
+	MarkPhoneVerified(ctx context.Context, id int) error
+	// UpdateProfile saves name, email and phone along with their verification
+	// state. It returns ErrEmailTaken or ErrPhoneTaken if another account has them.
+	UpdateProfile(ctx context.Context, user *User) error
+	UpdateAvatar(ctx context.Context, id int, avatarURL string) error
+	UpdateRole(ctx context.Context, id int, role string) error
+	UpdateUUID(ctx context.Context, id int, uuid string) error
+	// ListAfter returns up to limit users with an ID above afterID, by ID.
+	ListAfter(ctx context.Context, afterID, limit int) ([]*User, error)
+}
+
+// NewUUID returns a fresh, random UUID for a new account.
+func NewUUID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate uuid: %w", err)
+	}
+	return id.String(), nil
+}
+
+// IsLegacyUUID reports whether id is one of the name-based (v5) UUIDs early
+// accounts were given. Those can collide and are re-keyed by cmd/rekey-users.
+func IsLegacyUUID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.Version() == 5
 }
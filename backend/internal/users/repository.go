@@ -3,8 +3,12 @@ package users
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type MySQLRepository struct {
@@ -15,16 +19,24 @@ func NewMySQLRepository(db *sql.DB) *MySQLRepository {
 	return &MySQLRepository{db: db}
 }
 
+const userColumns = `id, uuid, name, email, phone, avatar_url, role, email_verified_at, phone_verified_at, created_at, updated_at`
+
 func (r *MySQLRepository) Create(ctx context.Context, user *User) error {
 	query := `
-		INSERT INTO keepsy_users (uuid, name, email, phone, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO keepsy_users (uuid, name, email, phone, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
 
-	result, err := r.db.ExecContext(ctx, query, user.UUID, user.Name, user.Email, user.Phone, user.CreatedAt, user.UpdatedAt)
+	result, err := r.db.ExecContext(ctx, query, user.UUID, user.Name, user.Email, nullString(user.Phone), user.Role, user.CreatedAt, user.UpdatedAt)
 	if err != nil {
+		if taken := duplicateError(err); taken != nil {
+			return taken
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -37,53 +49,145 @@ func (r *MySQLRepository) Create(ctx context.Context, user *User) error {
 }
 
 func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*User, error) {
-	query := `SELECT id, uuid, name, email, phone, created_at, updated_at FROM keepsy_users WHERE id = ?`
-	var user User
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.UUID, &user.Name, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
+	query := `SELECT ` + userColumns + ` FROM keepsy_users WHERE id = ?`
+	return r.getOne(ctx, query, id)
+}
+
+func (r *MySQLRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM keepsy_users WHERE email = ?`
+	return r.getOne(ctx, query, email)
+}
+
+func (r *MySQLRepository) GetByPhone(ctx context.Context, phone string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM keepsy_users WHERE phone = ?`
+	return r.getOne(ctx, query, phone)
+}
+
+func (r *MySQLRepository) GetByUUID(ctx context.Context, uuid string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM keepsy_users WHERE uuid = ?`
+	return r.getOne(ctx, query, uuid)
+}
+
+func (r *MySQLRepository) MarkEmailVerified(ctx context.Context, id int) error {
+	query := `UPDATE keepsy_users SET email_verified_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) MarkPhoneVerified(ctx context.Context, id int) error {
+	query := `UPDATE keepsy_users SET phone_verified_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to mark phone verified: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE keepsy_users
+		SET name = ?, email = ?, phone = ?, email_verified_at = ?, phone_verified_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+	user.UpdatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		user.Name, user.Email, nullString(user.Phone), user.EmailVerifiedAt, user.PhoneVerifiedAt, user.UpdatedAt, user.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		if taken := duplicateError(err); taken != nil {
+			return taken
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return fmt.Errorf("failed to update user: %w", err)
 	}
-	return &user, nil
+	return nil
 }
 
-func (r *MySQLRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, uuid, name, email, phone, created_at, updated_at FROM keepsy_users WHERE email = ?`
-	var user User
-	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.UUID, &user.Name, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
+func (r *MySQLRepository) UpdateAvatar(ctx context.Context, id int, avatarURL string) error {
+	query := `UPDATE keepsy_users SET avatar_url = ?, updated_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, nullString(avatarURL), time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update avatar: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) UpdateRole(ctx context.Context, id int, role string) error {
+	query := `UPDATE keepsy_users SET role = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, role, id); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) UpdateUUID(ctx context.Context, id int, uuid string) error {
+	query := `UPDATE keepsy_users SET uuid = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, uuid, id); err != nil {
+		return fmt.Errorf("failed to update uuid: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) ListAfter(ctx context.Context, afterID, limit int) ([]*User, error) {
+	query := `SELECT ` + userColumns + ` FROM keepsy_users WHERE id > ? ORDER BY id LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		list = append(list, user)
 	}
-	return &user, nil
+	return list, rows.Err()
 }
 
-func (r *MySQLRepository) GetByPhone(ctx context.Context, phone string) (*User, error) {
-	query := `SELECT id, uuid, name, email, phone, created_at, updated_at FROM keepsy_users WHERE phone = ?`
-	var user User
-	err := r.db.QueryRowContext(ctx, query, phone).Scan(&user.ID, &user.UUID, &user.Name, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
+func (r *MySQLRepository) getOne(ctx context.Context, query string, arg any) (*User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, arg))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	return &user, nil
+	return user, nil
 }
 
-func (r *MySQLRepository) GetByUUID(ctx context.Context, uuid string) (*User, error) {
-	query := `SELECT id, uuid, name, email, phone, created_at, updated_at FROM keepsy_users WHERE uuid = ?`
+func scanUser(row interface{ Scan(dest ...any) error }) (*User, error) {
 	var user User
-	err := r.db.QueryRowContext(ctx, query, uuid).Scan(&user.ID, &user.UUID, &user.Name, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
+	var phone, avatarURL sql.NullString
+	err := row.Scan(
+		&user.ID, &user.UUID, &user.Name, &user.Email, &phone, &avatarURL, &user.Role,
+		&user.EmailVerifiedAt, &user.PhoneVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, err
 	}
+	user.Phone = phone.String
+	user.AvatarURL = avatarURL.String
 	return &user, nil
 }
+
+// duplicateError maps a unique key violation to ErrEmailTaken or ErrPhoneTaken.
+func duplicateError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != 1062 {
+		return nil
+	}
+	switch {
+	case strings.Contains(mysqlErr.Message, "uq_keepsy_users_phone"):
+		return ErrPhoneTaken
+	case strings.Contains(mysqlErr.Message, "email"):
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// nullString stores empty values as NULL, so they don't count against unique keys.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,196 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"keepsy-backend/internal/services/storage"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNameRequired      = errors.New("name is required")
+	ErrEmailRequired     = errors.New("email is required")
+	ErrInvalidEmail      = errors.New("invalid email")
+	ErrUnsupportedAvatar = errors.New("avatar must be a JPEG, PNG or WebP image")
+)
+
+// avatarTypes maps the image types accepted as avatars to the extension
+// they are stored with.
+var avatarTypes = map[string]string{"image/jpeg": ".jpg", "image/png": ".png", "image/webp": ".webp"}
+
+// Verifier sends verification codes for a changed email or phone.
+// auth.Service implements it.
+type Verifier interface {
+	SendEmailVerification(ctx context.Context, email string) error
+	SendPhoneVerification(ctx context.Context, phone string) error
+}
+
+type Service interface {
+	GetProfile(ctx context.Context, userID int) (*User, error)
+	// UpdateProfile applies a partial update. A changed email or phone is
+	// unverified until the user enters the code sent to it.
+	UpdateProfile(ctx context.Context, userID int, req UpdateProfileRequest) (*User, error)
+	// UploadAvatar stores the image under <uuid>/avatar/ and replaces the
+	// previous one. The type is detected from the file's contents.
+	UploadAvatar(ctx context.Context, userID int, file io.Reader, filename string) (*User, error)
+	// RelocateAvatar moves the avatar under the user's current UUID prefix
+	// if it isn't there yet, and returns how many files moved.
+	RelocateAvatar(ctx context.Context, user *User) (int, error)
+}
+
+type service struct {
+	repo     Repository
+	storage  storage.Service
+	verifier Verifier
+}
+
+func NewService(repo Repository, storage storage.Service, verifier Verifier) Service {
+	return &service{
+		repo:     repo,
+		storage:  storage,
+		verifier: verifier,
+	}
+}
+
+func (s *service) GetProfile(ctx context.Context, userID int) (*User, error) {
+	return s.repo.GetByID(ctx, userID)
+}
+
+func (s *service) UpdateProfile(ctx context.Context, userID int, req UpdateProfileRequest) (*User, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, ErrNameRequired
+		}
+		user.Name = name
+	}
+
+	emailChanged := false
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" {
+			return nil, ErrEmailRequired
+		}
+		if !strings.Contains(email, "@") {
+			return nil, ErrInvalidEmail
+		}
+		if email != user.Email {
+			if err := s.checkAvailable(ctx, s.repo.GetByEmail, email, userID, ErrEmailTaken); err != nil {
+				return nil, err
+			}
+			user.Email = email
+			user.EmailVerifiedAt = nil
+			emailChanged = true
+		}
+	}
+
+	phoneChanged := false
+	if req.Phone != nil {
+		phone := strings.TrimSpace(*req.Phone)
+		if phone != user.Phone {
+			if phone != "" {
+				if err := s.checkAvailable(ctx, s.repo.GetByPhone, phone, userID, ErrPhoneTaken); err != nil {
+					return nil, err
+				}
+			}
+			user.Phone = phone
+			user.PhoneVerifiedAt = nil
+			phoneChanged = phone != ""
+		}
+	}
+
+	// The unique keys still catch a concurrent change between check and update
+	if err := s.repo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// Best effort, the user can ask for a new code later
+	if emailChanged {
+		_ = s.verifier.SendEmailVerification(ctx, user.Email)
+	}
+	if phoneChanged {
+		_ = s.verifier.SendPhoneVerification(ctx, user.Phone)
+	}
+	return user, nil
+}
+
+// checkAvailable makes sure no other account uses an email or phone.
+func (s *service) checkAvailable(ctx context.Context, lookup func(context.Context, string) (*User, error), value string, userID int, taken error) error {
+	other, err := lookup(ctx, value)
+	switch {
+	case err == ErrNotFound:
+		return nil
+	case err != nil:
+		return err
+	case other.ID != userID:
+		return taken
+	}
+	return nil
+}
+
+func (s *service) UploadAvatar(ctx context.Context, userID int, file io.Reader, filename string) (*User, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avatar: %w", err)
+	}
+	// The client's content type and file name can't be trusted, so the
+	// stored extension comes from the bytes and can't be anything but an image
+	ext, ok := avatarTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, ErrUnsupportedAvatar
+	}
+	filename = strings.TrimSuffix(path.Base(filename), path.Ext(filename)) + ext
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.storage.Upload(ctx, bytes.NewReader(data), avatarPath(user.UUID, filename))
+	if err != nil {
+		return nil, fmt.Errorf("storage upload failed: %w", err)
+	}
+
+	if err := s.repo.UpdateAvatar(ctx, user.ID, url); err != nil {
+		_ = s.storage.Delete(ctx, url)
+		return nil, err
+	}
+
+	// The old image is no longer referenced, a failed delete only leaves a stray file
+	if user.AvatarURL != "" {
+		_ = s.storage.Delete(ctx, user.AvatarURL)
+	}
+	user.AvatarURL = url
+	return user, nil
+}
+
+func (s *service) RelocateAvatar(ctx context.Context, user *User) (int, error) {
+	if user.AvatarURL == "" || strings.Contains(user.AvatarURL, "/"+user.UUID+"/avatar/") {
+		return 0, nil
+	}
+
+	url, err := s.storage.Move(ctx, user.AvatarURL, avatarPath(user.UUID, user.AvatarURL))
+	if err != nil {
+		return 0, fmt.Errorf("failed to move avatar: %w", err)
+	}
+	if err := s.repo.UpdateAvatar(ctx, user.ID, url); err != nil {
+		return 0, err
+	}
+	user.AvatarURL = url
+	return 1, nil
+}
+
+// avatarPath is where a user's avatar is stored: <uuid>/avatar/<filename>
+func avatarPath(userUUID, filename string) string {
+	return fmt.Sprintf("%s/avatar/%s", userUUID, path.Base(filename))
+}
@@ -0,0 +1,391 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepo
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Create(ctx context.Context, user *User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetByID(ctx context.Context, id int) (*User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	// Copy, the service modifies the user it gets
+	user := *args.Get(0).(*User)
+	return &user, args.Error(1)
+}
+
+func (m *MockRepo) GetByUUID(ctx context.Context, uuid string) (*User, error) {
+	args := m.Called(ctx, uuid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockRepo) GetByEmail(ctx context.Context, email string) (*User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockRepo) GetByPhone(ctx context.Context, phone string) (*User, error) {
+	args := m.Called(ctx, phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockRepo) MarkEmailVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) MarkPhoneVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateProfile(ctx context.Context, user *User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateAvatar(ctx context.Context, id int, avatarURL string) error {
+	args := m.Called(ctx, id, avatarURL)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateRole(ctx context.Context, id int, role string) error {
+	args := m.Called(ctx, id, role)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateUUID(ctx context.Context, id int, uuid string) error {
+	args := m.Called(ctx, id, uuid)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ListAfter(ctx context.Context, afterID, limit int) ([]*User, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*User), args.Error(1)
+}
+
+// MockStorage
+type MockStorage struct {
+	mock.Mock
+}
+
+func (m *MockStorage) Upload(ctx context.Context, file io.Reader, filename string) (string, error) {
+	args := m.Called(ctx, file, filename)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) Delete(ctx context.Context, url string) error {
+	args := m.Called(ctx, url)
+	return args.Error(0)
+}
+
+func (m *MockStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorage) Move(ctx context.Context, url, filename string) (string, error) {
+	args := m.Called(ctx, url, filename)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) Open(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadSeekCloser), args.Error(1)
+}
+
+func (m *MockStorage) GetDownloadURL(ctx context.Context, url string) (string, error) {
+	args := m.Called(ctx, url)
+	return args.String(0), args.Error(1)
+}
+
+// MockVerifier
+type MockVerifier struct {
+	mock.Mock
+}
+
+func (m *MockVerifier) SendEmailVerification(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockVerifier) SendPhoneVerification(ctx context.Context, phone string) error {
+	args := m.Called(ctx, phone)
+	return args.Error(0)
+}
+
+func ptr(s string) *string { return &s }
+
+func verifiedUser() *User {
+	verified := time.Now().Add(-time.Hour)
+	return &User{
+		ID:              1,
+		UUID:            "0190f6e4-7b1c-7cc3-9a5e-2f4c8d1e6b3a",
+		Name:            "Asha",
+		Email:           "asha@example.com",
+		Phone:           "+15550001",
+		EmailVerifiedAt: &verified,
+		PhoneVerifiedAt: &verified,
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Name Only Keeps Verification", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockVerifier := new(MockVerifier)
+		service := NewService(mockRepo, new(MockStorage), mockVerifier)
+
+		mockRepo.On("GetByID", ctx, 1).Return(verifiedUser(), nil)
+		mockRepo.On("UpdateProfile", ctx, mock.MatchedBy(func(u *User) bool {
+			return u.Name == "Asha K" && u.EmailVerifiedAt != nil && u.PhoneVerifiedAt != nil
+		})).Return(nil)
+
+		user, err := service.UpdateProfile(ctx, 1, UpdateProfileRequest{Name: ptr("  Asha K ")})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Asha K", user.Name)
+		mockVerifier.AssertNotCalled(t, "SendEmailVerification", mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Changed Email Needs Verification", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockVerifier := new(MockVerifier)
+		service := NewService(mockRepo, new(MockStorage), mockVerifier)
+
+		mockRepo.On("GetByID", ctx, 1).Return(verifiedUser(), nil)
+		mockRepo.On("GetByEmail", ctx, "new@example.com").Return(nil, ErrNotFound)
+		mockRepo.On("UpdateProfile", ctx, mock.MatchedBy(func(u *User) bool {
+			return u.Email == "new@example.com" && u.EmailVerifiedAt == nil && u.PhoneVerifiedAt != nil
+		})).Return(nil)
+		mockVerifier.On("SendEmailVerification", ctx, "new@example.com").Return(nil)
+
+		user, err := service.UpdateProfile(ctx, 1, UpdateProfileRequest{Email: ptr("new@example.com")})
+
+		assert.NoError(t, err)
+		assert.Nil(t, user.EmailVerifiedAt)
+		mockVerifier.AssertExpectations(t)
+	})
+
+	t.Run("Changed Phone Needs Verification", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockVerifier := new(MockVerifier)
+		service := NewService(mockRepo, new(MockStorage), mockVerifier)
+
+		mockRepo.On("GetByID", ctx, 1).Return(verifiedUser(), nil)
+		mockRepo.On("GetByPhone", ctx, "+15550002").Return(nil, ErrNotFound)
+		mockRepo.On("UpdateProfile", ctx, mock.MatchedBy(func(u *User) bool {
+			return u.Phone == "+15550002" && u.PhoneVerifiedAt == nil && u.EmailVerifiedAt != nil
+		})).Return(nil)
+		mockVerifier.On("SendPhoneVerification", ctx, "+15550002").Return(nil)
+
+		_, err := service.UpdateProfile(ctx, 1, UpdateProfileRequest{Phone: ptr("+15550002")})
+
+		assert.NoError(t, err)
+		mockVerifier.AssertExpectations(t)
+	})
+
+	t.Run("Removed Phone", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockVerifier := new(MockVerifier)
+		service := NewService(mockRepo, new(MockStorage), mockVerifier)
+
+		mockRepo.On("GetByID", ctx, 1).Return(verifiedUser(), nil)
+		mockRepo.On("UpdateProfile", ctx, mock.MatchedBy(func(u *User) bool {
+			return u.Phone == "" && u.PhoneVerifiedAt == nil
+		})).Return(nil)
+
+		_, err := service.UpdateProfile(ctx, 1, UpdateProfileRequest{Phone: ptr("")})
+
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "GetByPhone", mock.Anything, mock.Anything)
+		mockVerifier.AssertNotCalled(t, "SendPhoneVerification", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Email Taken", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, new(MockStorage), new(MockVerifier))
+
+		mockRepo.On("GetByID", ctx, 1).Return(verifiedUser(), nil)
+		mockRepo.On("GetByEmail", ctx, "taken@example.com").Return(&User{ID: 2}, nil)
+
+		_, err := service.UpdateProfile(ctx, 1, UpdateProfileRequest{Email: ptr("taken@example.com")})
+
+		assert.Equal(t, ErrEmailTaken, err)
+		mockRepo.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Phone Taken Concurrently", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, new(MockStorage), new(MockVerifier))
+
+		mockRepo.On("GetByID", ctx, 1).Return(verifiedUser(), nil)
+		mockRepo.On("GetByPhone", ctx, "+15550003").Return(nil, ErrNotFound)
+		mockRepo.On("UpdateProfile", ctx, mock.Anything).Return(ErrPhoneTaken)
+
+		_, err := service.UpdateProfile(ctx, 1, UpdateProfileRequest{Phone: ptr("+15550003")})
+
+		assert.Equal(t, ErrPhoneTaken, err)
+	})
+
+	t.Run("Empty Name", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, new(MockStorage), new(MockVerifier))
+
+		mockRepo.On("GetByID", ctx, 1).Return(verifiedUser(), nil)
+
+		_, err := service.UpdateProfile(ctx, 1, UpdateProfileRequest{Name: ptr(" ")})
+
+		assert.Equal(t, ErrNameRequired, err)
+	})
+}
+
+func TestUploadAvatar(t *testing.T) {
+	ctx := context.Background()
+	jpeg := "\xff\xd8\xff\xe0\x00\x10JFIF\x00"
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+	t.Run("Stored Under UUID And Old One Deleted", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, mockStorage, new(MockVerifier))
+
+		user := verifiedUser()
+		user.AvatarURL = "http://localhost/uploads/" + user.UUID + "/avatar/1_old.png"
+
+		mockRepo.On("GetByID", ctx, 1).Return(user, nil)
+		mockStorage.On("Upload", ctx, mock.Anything, user.UUID+"/avatar/me.jpg").Return("http://localhost/uploads/"+user.UUID+"/avatar/2_me.jpg", nil)
+		mockRepo.On("UpdateAvatar", ctx, 1, "http://localhost/uploads/"+user.UUID+"/avatar/2_me.jpg").Return(nil)
+		mockStorage.On("Delete", ctx, user.AvatarURL).Return(nil)
+
+		updated, err := service.UploadAvatar(ctx, 1, strings.NewReader(jpeg), "../../me.jpg")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost/uploads/"+user.UUID+"/avatar/2_me.jpg", updated.AvatarURL)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Extension From Contents", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, mockStorage, new(MockVerifier))
+
+		user := verifiedUser()
+		mockRepo.On("GetByID", ctx, 1).Return(user, nil)
+		// A PNG named like a page is still stored as a PNG
+		mockStorage.On("Upload", ctx, mock.Anything, user.UUID+"/avatar/me.png").Return("http://localhost/uploads/"+user.UUID+"/avatar/2_me.png", nil)
+		mockRepo.On("UpdateAvatar", ctx, 1, "http://localhost/uploads/"+user.UUID+"/avatar/2_me.png").Return(nil)
+
+		_, err := service.UploadAvatar(ctx, 1, strings.NewReader(png), "me.html")
+
+		assert.NoError(t, err)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Unsupported Type", func(t *testing.T) {
+		mockStorage := new(MockStorage)
+		service := NewService(new(MockRepo), mockStorage, new(MockVerifier))
+
+		_, err := service.UploadAvatar(ctx, 1, strings.NewReader("%PDF-1.7"), "me.pdf")
+
+		assert.Equal(t, ErrUnsupportedAvatar, err)
+		mockStorage.AssertNotCalled(t, "Upload", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("HTML Named As Image", func(t *testing.T) {
+		mockStorage := new(MockStorage)
+		service := NewService(new(MockRepo), mockStorage, new(MockVerifier))
+
+		_, err := service.UploadAvatar(ctx, 1, strings.NewReader("<html><script>alert(1)</script></html>"), "me.jpg")
+
+		assert.Equal(t, ErrUnsupportedAvatar, err)
+		mockStorage.AssertNotCalled(t, "Upload", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("DB Failure Removes Upload", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, mockStorage, new(MockVerifier))
+
+		user := verifiedUser()
+		mockRepo.On("GetByID", ctx, 1).Return(user, nil)
+		mockStorage.On("Upload", ctx, mock.Anything, user.UUID+"/avatar/me.png").Return("http://localhost/uploads/new.png", nil)
+		mockRepo.On("UpdateAvatar", ctx, 1, "http://localhost/uploads/new.png").Return(errors.New("db error"))
+		mockStorage.On("Delete", ctx, "http://localhost/uploads/new.png").Return(nil)
+
+		_, err := service.UploadAvatar(ctx, 1, strings.NewReader(png), "me.png")
+
+		assert.Error(t, err)
+		mockStorage.AssertExpectations(t)
+	})
+}
+
+func TestRelocateAvatar(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Moves Under New UUID", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		mockStorage := new(MockStorage)
+		service := NewService(mockRepo, mockStorage, new(MockVerifier))
+
+		user := &User{ID: 1, UUID: "new-uuid", AvatarURL: "http://localhost/uploads/old-uuid/avatar/1_me.png"}
+		mockStorage.On("Move", ctx, user.AvatarURL, "new-uuid/avatar/1_me.png").Return("http://localhost/uploads/new-uuid/avatar/1_me.png", nil)
+		mockRepo.On("UpdateAvatar", ctx, 1, "http://localhost/uploads/new-uuid/avatar/1_me.png").Return(nil)
+
+		moved, err := service.RelocateAvatar(ctx, user)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, moved)
+		assert.Equal(t, "http://localhost/uploads/new-uuid/avatar/1_me.png", user.AvatarURL)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Already In Place", func(t *testing.T) {
+		mockStorage := new(MockStorage)
+		service := NewService(new(MockRepo), mockStorage, new(MockVerifier))
+
+		moved, err := service.RelocateAvatar(ctx, &User{ID: 1, UUID: "new-uuid", AvatarURL: "http://localhost/uploads/new-uuid/avatar/1_me.png"})
+
+		assert.NoError(t, err)
+		assert.Zero(t, moved)
+		mockStorage.AssertNotCalled(t, "Move", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,90 @@
+package warranty
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	StatusActive   = "active"
+	StatusExpiring = "expiring" // Ends within the expiring window
+	StatusExpired  = "expired"
+	StatusUnknown  = "unknown" // No end date and none could be inferred
+)
+
+// DefaultExpiringWithin is the expiring window in days used in product
+// responses.
+const DefaultExpiringWithin = 30
+
+// Record types
+const (
+	TypeManufacturer = "manufacturer"
+	TypeExtended     = "extended"
+	TypeAMC          = "amc" // Annual maintenance contract
+	TypeInsurance    = "insurance"
+)
+
+// Dates are what a warranty is worked out from.
+type Dates struct {
+	Records       []*Record // The product's warranty records
+	EndDate       *time.Time
+	PurchaseDate  *time.Time
+	DefaultMonths *int // The category's default warranty period
+}
+
+// Warranty is a product's effective warranty. It ends with the latest of
+// its records that has started; without one, on the product's own end date
+// or the one inferred from its category.
+type Warranty struct {
+	Status        string     `json:"status"`
+	EndDate       *time.Time `json:"end_date,omitempty"`
+	DaysRemaining *int       `json:"days_remaining,omitempty"` // Negative once expired
+	RecordID      *int       `json:"record_id,omitempty"`      // The record it comes from
+	// Inferred is set when EndDate is the purchase date plus the
+	// category's default period.
+	Inferred bool `json:"inferred,omitempty"`
+}
+
+// Record is one warranty or coverage of a product, e.g. the manufacturer
+// warranty or an extended one.
+type Record struct {
+	ID           int        `json:"id"`
+	ProductID    int        `json:"product_id"`
+	UserID       int        `json:"-"` // Owner of the product, populated via JOIN
+	Provider     string     `json:"provider"`
+	Type         string     `json:"type"`
+	StartDate    *time.Time `json:"start_date,omitempty"` // Unset: covered since purchase
+	EndDate      time.Time  `json:"end_date"`
+	Coverage     string     `json:"coverage,omitempty"` // e.g. "Panel only"
+	ClaimContact string     `json:"claim_contact,omitempty"`
+	DocumentID   *int       `json:"document_id,omitempty"` // A bill of the same product
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// OwnerID implements authz.Owned.
+func (r *Record) OwnerID() int { return r.UserID }
+
+// RecordRequest creates or replaces a record.
+type RecordRequest struct {
+	Provider     string     `json:"provider"`
+	Type         string     `json:"type"`
+	StartDate    *time.Time `json:"start_date,omitempty"`
+	EndDate      *time.Time `json:"end_date"`
+	Coverage     string     `json:"coverage,omitempty"`
+	ClaimContact string     `json:"claim_contact,omitempty"`
+	DocumentID   *int       `json:"document_id,omitempty"`
+}
+
+type Repository interface {
+	Create(ctx context.Context, record *Record) error
+	GetByID(ctx context.Context, id int) (*Record, error)
+	// ListByProductIDs returns the records of the products, latest end first.
+	ListByProductIDs(ctx context.Context, productIDs []int) ([]*Record, error)
+	Update(ctx context.Context, record *Record) error
+	Delete(ctx context.Context, id int) error
+	// ProductOwner returns the ID of the user owning the product.
+	ProductOwner(ctx context.Context, productID int) (int, error)
+	// DocumentProduct returns the ID of the product a bill belongs to.
+	DocumentProduct(ctx context.Context, billID int) (int, error)
+}
@@ -0,0 +1,160 @@
+package warranty
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRecordNotFound   = errors.New("warranty record not found")
+	ErrProductNotFound  = errors.New("product not found")
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLRepository(db *sql.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+const recordColumns = `w.id, w.product_id, p.user_id, w.provider, w.type, w.start_date, w.end_date,
+	COALESCE(w.coverage, ''), COALESCE(w.claim_contact, ''), w.document_id, w.created_at, w.updated_at`
+
+func scanRecord(row interface{ Scan(...any) error }) (*Record, error) {
+	var r Record
+	err := row.Scan(
+		&r.ID, &r.ProductID, &r.UserID, &r.Provider, &r.Type, &r.StartDate, &r.EndDate,
+		&r.Coverage, &r.ClaimContact, &r.DocumentID, &r.CreatedAt, &r.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (r *MySQLRepository) Create(ctx context.Context, record *Record) error {
+	query := `
+		INSERT INTO keepsy_warranties (product_id, provider, type, start_date, end_date, coverage, claim_contact, document_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = record.CreatedAt
+
+	result, err := r.db.ExecContext(ctx, query,
+		record.ProductID, record.Provider, record.Type, record.StartDate, record.EndDate,
+		record.Coverage, record.ClaimContact, record.DocumentID, record.CreatedAt, record.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create warranty record: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	record.ID = int(id)
+	return nil
+}
+
+func (r *MySQLRepository) GetByID(ctx context.Context, id int) (*Record, error) {
+	query := `SELECT ` + recordColumns + `
+		FROM keepsy_warranties w
+		JOIN keepsy_products p ON p.id = w.product_id
+		WHERE w.id = ?`
+
+	record, err := scanRecord(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to get warranty record: %w", err)
+	}
+	return record, nil
+}
+
+func (r *MySQLRepository) ListByProductIDs(ctx context.Context, productIDs []int) ([]*Record, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+	query := `SELECT ` + recordColumns + `
+		FROM keepsy_warranties w
+		JOIN keepsy_products p ON p.id = w.product_id
+		WHERE w.product_id IN (?` + strings.Repeat(", ?", len(productIDs)-1) + `)
+		ORDER BY w.end_date DESC, w.id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list warranty records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*Record
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan warranty record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (r *MySQLRepository) Update(ctx context.Context, record *Record) error {
+	query := `
+		UPDATE keepsy_warranties
+		SET provider = ?, type = ?, start_date = ?, end_date = ?, coverage = ?, claim_contact = ?, document_id = ?, updated_at = ?
+		WHERE id = ?
+	`
+	record.UpdatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		record.Provider, record.Type, record.StartDate, record.EndDate, record.Coverage,
+		record.ClaimContact, record.DocumentID, record.UpdatedAt, record.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update warranty record: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) Delete(ctx context.Context, id int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM keepsy_warranties WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete warranty record: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLRepository) ProductOwner(ctx context.Context, productID int) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, `SELECT user_id FROM keepsy_products WHERE id = ?`, productID).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrProductNotFound
+		}
+		return 0, fmt.Errorf("failed to get product owner: %w", err)
+	}
+	return userID, nil
+}
+
+func (r *MySQLRepository) DocumentProduct(ctx context.Context, billID int) (int, error) {
+	var productID int
+	err := r.db.QueryRowContext(ctx, `SELECT product_id FROM keepsy_bills WHERE id = ?`, billID).Scan(&productID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrDocumentNotFound
+		}
+		return 0, fmt.Errorf("failed to get document: %w", err)
+	}
+	return productID, nil
+}
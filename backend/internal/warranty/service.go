@@ -0,0 +1,234 @@
+package warranty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepsy-backend/internal/services/authz"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRecord = errors.New("invalid warranty record")
+
+type Service interface {
+	// Evaluate works out the status and days remaining of a warranty. It is
+	// expiring when it ends within the given number of days; the end day
+	// itself is still covered.
+	Evaluate(dates Dates, within int) *Warranty
+	// Today is the date (in UTC) warranties are evaluated on.
+	Today() time.Time
+
+	// RecordsByProduct loads the records of the given products so their
+	// warranties can be evaluated. Ownership is up to the caller.
+	RecordsByProduct(ctx context.Context, productIDs []int) (map[int][]*Record, error)
+
+	ListRecords(ctx context.Context, productID, userID int) ([]*Record, error)
+	CreateRecord(ctx context.Context, productID, userID int, req RecordRequest) (*Record, error)
+	// UpdateRecord replaces all fields of a record.
+	UpdateRecord(ctx context.Context, productID, id, userID int, req RecordRequest) (*Record, error)
+	DeleteRecord(ctx context.Context, productID, id, userID int) error
+}
+
+type service struct {
+	repo Repository
+	now  func() time.Time
+}
+
+// NewService returns a warranty service evaluating against now; nil uses
+// the current time.
+func NewService(repo Repository, now func() time.Time) Service {
+	if now == nil {
+		now = time.Now
+	}
+	return &service{
+		repo: repo,
+		now:  now,
+	}
+}
+
+func (s *service) Today() time.Time {
+	return dateOf(s.now())
+}
+
+func (s *service) Evaluate(dates Dates, within int) *Warranty {
+	w := &Warranty{Status: StatusUnknown}
+
+	today := s.Today()
+	var latest *Record
+	for _, record := range dates.Records {
+		started := record.StartDate == nil || !dateOf(*record.StartDate).After(today)
+		if started && (latest == nil || record.EndDate.After(latest.EndDate)) {
+			latest = record
+		}
+	}
+
+	switch {
+	case latest != nil:
+		end := dateOf(latest.EndDate)
+		w.EndDate = &end
+		w.RecordID = &latest.ID
+	case dates.EndDate != nil:
+		end := dateOf(*dates.EndDate)
+		w.EndDate = &end
+	case dates.PurchaseDate != nil && dates.DefaultMonths != nil && *dates.DefaultMonths > 0:
+		end := EndDate(dateOf(*dates.PurchaseDate), *dates.DefaultMonths)
+		w.EndDate = &end
+		w.Inferred = true
+	default:
+		return w
+	}
+
+	days := int(w.EndDate.Sub(today).Hours() / 24)
+	w.DaysRemaining = &days
+	switch {
+	case days < 0:
+		w.Status = StatusExpired
+	case days <= within:
+		w.Status = StatusExpiring
+	default:
+		w.Status = StatusActive
+	}
+	return w
+}
+
+func (s *service) RecordsByProduct(ctx context.Context, productIDs []int) (map[int][]*Record, error) {
+	records, err := s.repo.ListByProductIDs(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[int][]*Record)
+	for _, record := range records {
+		byProduct[record.ProductID] = append(byProduct[record.ProductID], record)
+	}
+	return byProduct, nil
+}
+
+func (s *service) ListRecords(ctx context.Context, productID, userID int) ([]*Record, error) {
+	if err := s.authorizeProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+	records, err := s.repo.ListByProductIDs(ctx, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []*Record{}
+	}
+	return records, nil
+}
+
+func (s *service) CreateRecord(ctx context.Context, productID, userID int, req RecordRequest) (*Record, error) {
+	if err := s.authorizeProduct(ctx, productID, userID); err != nil {
+		return nil, err
+	}
+
+	record := &Record{ProductID: productID, UserID: userID}
+	if err := s.apply(ctx, record, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *service) UpdateRecord(ctx context.Context, productID, id, userID int, req RecordRequest) (*Record, error) {
+	record, err := s.ownedRecord(ctx, productID, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apply(ctx, record, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *service) DeleteRecord(ctx context.Context, productID, id, userID int) error {
+	if _, err := s.ownedRecord(ctx, productID, id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *service) authorizeProduct(ctx context.Context, productID, userID int) error {
+	ownerID, err := s.repo.ProductOwner(ctx, productID)
+	if err != nil {
+		return err
+	}
+	return authz.Authorize(userID, authz.OwnedBy(ownerID))
+}
+
+// ownedRecord loads a record of the product and authorizes userID to
+// access it.
+func (s *service) ownedRecord(ctx context.Context, productID, id, userID int) (*Record, error) {
+	record, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Authorize(userID, record); err != nil {
+		return nil, err
+	}
+	if record.ProductID != productID {
+		return nil, ErrRecordNotFound
+	}
+	return record, nil
+}
+
+// apply validates req and copies it onto record.
+func (s *service) apply(ctx context.Context, record *Record, req RecordRequest) error {
+	provider := strings.TrimSpace(req.Provider)
+	if provider == "" {
+		return fmt.Errorf("%w: provider is required", ErrInvalidRecord)
+	}
+	switch req.Type {
+	case TypeManufacturer, TypeExtended, TypeAMC, TypeInsurance:
+	default:
+		return fmt.Errorf("%w: type must be manufacturer, extended, amc or insurance", ErrInvalidRecord)
+	}
+	if req.EndDate == nil {
+		return fmt.Errorf("%w: end_date is required", ErrInvalidRecord)
+	}
+	if req.StartDate != nil && req.StartDate.After(*req.EndDate) {
+		return fmt.Errorf("%w: start_date is after end_date", ErrInvalidRecord)
+	}
+	if req.DocumentID != nil {
+		// Only a bill of the same product can be attached
+		productID, err := s.repo.DocumentProduct(ctx, *req.DocumentID)
+		if err != nil {
+			return err
+		}
+		if productID != record.ProductID {
+			return ErrDocumentNotFound
+		}
+	}
+
+	record.Provider = provider
+	record.Type = req.Type
+	record.StartDate = req.StartDate
+	record.EndDate = *req.EndDate
+	record.Coverage = strings.TrimSpace(req.Coverage)
+	record.ClaimContact = strings.TrimSpace(req.ClaimContact)
+	record.DocumentID = req.DocumentID
+	return nil
+}
+
+// EndDate adds a warranty period of months to the purchase date. Like
+// MySQL's DATE_ADD, a day past the end of the month is clamped to its last
+// day (Jan 31 + 1 month is Feb 28), so both agree on inferred end dates.
+func EndDate(purchased time.Time, months int) time.Time {
+	y, m, d := purchased.Date()
+	firstOfMonth := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), min(d, lastDay), 0, 0, 0, 0, time.UTC)
+}
+
+// dateOf drops the time of day. DATE columns are read as midnight UTC.
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
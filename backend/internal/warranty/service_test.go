@@ -0,0 +1,215 @@
+package warranty
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"keepsy-backend/internal/services/authz"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Create(ctx context.Context, record *Record) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetByID(ctx context.Context, id int) (*Record, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Record), args.Error(1)
+}
+
+func (m *MockRepo) ListByProductIDs(ctx context.Context, productIDs []int) ([]*Record, error) {
+	args := m.Called(ctx, productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Record), args.Error(1)
+}
+
+func (m *MockRepo) Update(ctx context.Context, record *Record) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ProductOwner(ctx context.Context, productID int) (int, error) {
+	args := m.Called(ctx, productID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepo) DocumentProduct(ctx context.Context, billID int) (int, error) {
+	args := m.Called(ctx, billID)
+	return args.Int(0), args.Error(1)
+}
+
+func date(y int, m time.Month, d int) *time.Time {
+	t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestEvaluate(t *testing.T) {
+	// Late in the day, so days are counted on dates, not hours
+	service := NewService(nil, func() time.Time { return time.Date(2026, 10, 17, 23, 30, 0, 0, time.UTC) })
+
+	tests := []struct {
+		name     string
+		dates    Dates
+		status   string
+		days     *int
+		inferred bool
+	}{
+		{"Active", Dates{EndDate: date(2027, 1, 1)}, StatusActive, intPtr(76), false},
+		{"Expiring", Dates{EndDate: date(2026, 11, 16)}, StatusExpiring, intPtr(30), false},
+		{"Ends Today", Dates{EndDate: date(2026, 10, 17)}, StatusExpiring, intPtr(0), false},
+		{"Expired", Dates{EndDate: date(2026, 10, 1)}, StatusExpired, intPtr(-16), false},
+		{"End Date Wins", Dates{EndDate: date(2026, 10, 1), PurchaseDate: date(2026, 1, 1), DefaultMonths: intPtr(24)}, StatusExpired, intPtr(-16), false},
+		{"Inferred", Dates{PurchaseDate: date(2025, 11, 1), DefaultMonths: intPtr(12)}, StatusExpiring, intPtr(15), true},
+		{"No Default", Dates{PurchaseDate: date(2025, 11, 1)}, StatusUnknown, nil, false},
+		{"Nothing", Dates{}, StatusUnknown, nil, false},
+		{"Latest Started Record", Dates{EndDate: date(2026, 11, 1), Records: []*Record{
+			{ID: 1, EndDate: *date(2027, 1, 1)},
+			{ID: 2, StartDate: date(2026, 1, 1), EndDate: *date(2026, 12, 31)},
+			{ID: 3, StartDate: date(2027, 1, 2), EndDate: *date(2030, 1, 1)}, // Not started yet
+		}}, StatusActive, intPtr(76), false},
+		{"Expired Records", Dates{EndDate: date(2027, 1, 1), Records: []*Record{
+			{ID: 1, EndDate: *date(2026, 10, 1)},
+		}}, StatusExpired, intPtr(-16), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := service.Evaluate(tt.dates, DefaultExpiringWithin)
+
+			assert.Equal(t, tt.status, w.Status)
+			assert.Equal(t, tt.days, w.DaysRemaining)
+			assert.Equal(t, tt.inferred, w.Inferred)
+		})
+	}
+}
+
+func TestEndDate(t *testing.T) {
+	assert.Equal(t, *date(2027, 3, 15), EndDate(*date(2026, 3, 15), 12))
+	// Clamped to the end of the month like MySQL's DATE_ADD
+	assert.Equal(t, *date(2026, 2, 28), EndDate(*date(2026, 1, 31), 1))
+	assert.Equal(t, *date(2029, 2, 28), EndDate(*date(2028, 2, 29), 12))
+	assert.Equal(t, *date(2027, 4, 30), EndDate(*date(2026, 10, 31), 6))
+}
+
+func TestWarrantyRecords(t *testing.T) {
+	ctx := context.Background()
+	end := date(2029, 10, 17)
+	req := RecordRequest{Provider: " Croma ", Type: TypeExtended, EndDate: end, DocumentID: intPtr(12)}
+
+	t.Run("Create", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil)
+
+		mockRepo.On("ProductOwner", ctx, 4).Return(1, nil)
+		mockRepo.On("DocumentProduct", ctx, 12).Return(4, nil)
+		mockRepo.On("Create", ctx, mock.MatchedBy(func(r *Record) bool {
+			return r.ProductID == 4 && r.Provider == "Croma" && r.EndDate.Equal(*end) && *r.DocumentID == 12
+		})).Return(nil)
+
+		_, err := service.CreateRecord(ctx, 4, 1, req)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Other Users Product", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil)
+
+		mockRepo.On("ProductOwner", ctx, 4).Return(2, nil)
+
+		_, err := service.CreateRecord(ctx, 4, 1, req)
+
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Document Of Another Product", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil)
+
+		mockRepo.On("ProductOwner", ctx, 4).Return(1, nil)
+		mockRepo.On("DocumentProduct", ctx, 12).Return(5, nil)
+
+		_, err := service.CreateRecord(ctx, 4, 1, req)
+
+		assert.Equal(t, ErrDocumentNotFound, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil)
+
+		mockRepo.On("ProductOwner", ctx, 4).Return(1, nil)
+
+		requests := []RecordRequest{
+			{Type: TypeExtended, EndDate: end},
+			{Provider: "Croma", Type: "lifetime", EndDate: end},
+			{Provider: "Croma", Type: TypeAMC},
+			{Provider: "Croma", Type: TypeAMC, StartDate: date(2030, 1, 1), EndDate: end},
+		}
+		for _, r := range requests {
+			_, err := service.CreateRecord(ctx, 4, 1, r)
+			assert.ErrorIs(t, err, ErrInvalidRecord)
+		}
+	})
+
+	t.Run("Update Replaces Fields", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil)
+
+		mockRepo.On("GetByID", ctx, 9).Return(&Record{ID: 9, ProductID: 4, UserID: 1, Coverage: "Panel only", DocumentID: intPtr(3)}, nil)
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(r *Record) bool {
+			return r.Type == TypeAMC && r.Coverage == "" && r.DocumentID == nil
+		})).Return(nil)
+
+		_, err := service.UpdateRecord(ctx, 4, 9, 1, RecordRequest{Provider: "Croma", Type: TypeAMC, EndDate: end})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Record Of Another Product", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil)
+
+		mockRepo.On("GetByID", ctx, 9).Return(&Record{ID: 9, ProductID: 5, UserID: 1}, nil)
+
+		err := service.DeleteRecord(ctx, 4, 9, 1)
+
+		assert.Equal(t, ErrRecordNotFound, err)
+		mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Delete Other Users Record", func(t *testing.T) {
+		mockRepo := new(MockRepo)
+		service := NewService(mockRepo, nil)
+
+		mockRepo.On("GetByID", ctx, 9).Return(&Record{ID: 9, ProductID: 4, UserID: 2}, nil)
+
+		err := service.DeleteRecord(ctx, 4, 9, 1)
+
+		assert.ErrorIs(t, err, authz.ErrForbidden)
+		mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func intPtr(i int) *int { return &i }
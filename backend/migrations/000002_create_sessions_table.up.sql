@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS keepsy_sessions (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id INT NOT NULL,
+    device_name VARCHAR(255),
+    ip VARCHAR(45),
+    user_agent VARCHAR(512),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    INDEX idx_keepsy_sessions_user (user_id, revoked_at),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
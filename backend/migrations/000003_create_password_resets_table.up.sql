@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS keepsy_password_resets (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- sha256 hex, the raw token is only ever emailed
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+ALTER TABLE keepsy_users
+    ADD COLUMN email_verified_at DATETIME NULL AFTER phone,
+    ADD COLUMN phone_verified_at DATETIME NULL AFTER email_verified_at;
+
+-- Accounts created before verification existed keep working: treat their
+-- current identifiers as verified rather than locking everyone out.
+UPDATE keepsy_users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+UPDATE keepsy_users SET phone_verified_at = created_at WHERE phone IS NOT NULL AND phone <> '' AND phone_verified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS keepsy_otp_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    purpose VARCHAR(32) NOT NULL, -- verify_email, verify_phone
+    target VARCHAR(255) NOT NULL, -- The email/phone the code was sent to
+    code_hash VARCHAR(255) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    consumed_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_keepsy_otp_codes_user_purpose (user_id, purpose, created_at),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
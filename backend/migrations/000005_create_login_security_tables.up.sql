@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS keepsy_login_throttles (
+    scope VARCHAR(16) NOT NULL, -- identifier, ip
+    throttle_key VARCHAR(255) NOT NULL, -- Normalized email/phone, or client IP
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME NULL,
+    PRIMARY KEY (scope, throttle_key)
+);
+
+CREATE TABLE IF NOT EXISTS keepsy_security_events (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NULL,
+    event_type VARCHAR(50) NOT NULL, -- login_succeeded, account_locked, account_unlocked, ...
+    identifier VARCHAR(255),
+    ip VARCHAR(45),
+    user_agent VARCHAR(512),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_keepsy_security_events_user (user_id, created_at),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE SET NULL
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS keepsy_user_totp (
+    user_id INT PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL, -- Base32, as shown to the user
+    confirmed_at DATETIME NULL, -- NULL until the first code is confirmed
+    last_used_step BIGINT NOT NULL DEFAULT 0, -- Last accepted 30s time step, codes can't be replayed
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS keepsy_recovery_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL, -- sha256 hex of the normalized code
+    used_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_keepsy_recovery_codes (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS keepsy_user_identities (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    provider VARCHAR(50) NOT NULL, -- google, apple, ... as configured in OIDC_PROVIDERS
+    subject VARCHAR(255) NOT NULL, -- The provider's "sub" claim
+    email VARCHAR(255), -- Email the provider reported when linking
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_keepsy_user_identities_subject (provider, subject),
+    INDEX idx_keepsy_user_identities_user (user_id),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS keepsy_oidc_states (
+    state_hash CHAR(64) PRIMARY KEY, -- sha256 hex of the state parameter
+    provider VARCHAR(50) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    consumed_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,21 @@
+-- Deleting a user has to take everything they own with it. These foreign keys
+-- were created without ON DELETE, so the user row couldn't be removed (and
+-- products/reminders would have been orphaned if it could).
+ALTER TABLE keepsy_reminders
+    DROP FOREIGN KEY keepsy_reminders_ibfk_1,
+    DROP FOREIGN KEY keepsy_reminders_ibfk_2;
+ALTER TABLE keepsy_reminders
+    ADD CONSTRAINT fk_keepsy_reminders_user FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_keepsy_reminders_product FOREIGN KEY (product_id) REFERENCES keepsy_products(id) ON DELETE CASCADE;
+
+ALTER TABLE keepsy_products DROP FOREIGN KEY keepsy_products_ibfk_1;
+ALTER TABLE keepsy_products
+    ADD CONSTRAINT fk_keepsy_products_user FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS keepsy_account_deletions (
+    user_id INT PRIMARY KEY,
+    requested_at DATETIME NOT NULL,
+    purge_after DATETIME NOT NULL, -- End of the grace period, cancellable until then
+    INDEX idx_keepsy_account_deletions_purge (purge_after),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+ALTER TABLE keepsy_users ADD COLUMN avatar_url VARCHAR(2048) NULL AFTER phone;
+
+-- Phones become unique like emails. Accounts without a phone used to store an
+-- empty string, which would collide; they store NULL from now on.
+UPDATE keepsy_users SET phone = NULL, phone_verified_at = NULL WHERE phone = '';
+
+-- If a number was registered more than once, the oldest account keeps it and
+-- the others have to add (and verify) it again.
+UPDATE keepsy_users u
+JOIN (
+    SELECT phone, MIN(id) AS keep_id FROM keepsy_users
+    WHERE phone IS NOT NULL GROUP BY phone HAVING COUNT(*) > 1
+) d ON u.phone = d.phone AND u.id <> d.keep_id
+SET u.phone = NULL, u.phone_verified_at = NULL;
+
+ALTER TABLE keepsy_users ADD UNIQUE KEY uq_keepsy_users_phone (phone);
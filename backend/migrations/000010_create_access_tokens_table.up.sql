@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS keepsy_access_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_prefix VARCHAR(16) NOT NULL, -- Shown in listings, e.g. kpat_AbC123
+    token_hash CHAR(64) NOT NULL UNIQUE, -- sha256 hex of the token
+    scopes VARCHAR(512) NOT NULL, -- Space separated, e.g. "products:read bills:write reminders:*"
+    expires_at DATETIME NULL, -- NULL: never expires
+    last_used_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_keepsy_access_tokens_user (user_id, revoked_at),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- "user" or "admin". Admins manage shared data such as categories;
+-- grant the role with cmd/set-role.
+ALTER TABLE keepsy_users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user' AFTER phone;
@@ -0,0 +1,12 @@
+-- Indexes for GET /products/list. Each sort key gets (user_id, <key>, id) so
+-- keyset pages are read in index order in both directions.
+CREATE INDEX idx_keepsy_products_user_created ON keepsy_products (user_id, created_at, id);
+CREATE INDEX idx_keepsy_products_user_name ON keepsy_products (user_id, name, id);
+CREATE INDEX idx_keepsy_products_user_price ON keepsy_products (user_id, price, id);
+CREATE INDEX idx_keepsy_products_user_purchase ON keepsy_products (user_id, purchase_date, id);
+CREATE INDEX idx_keepsy_products_user_warranty ON keepsy_products (user_id, warranty_end_date, id);
+
+-- Equality filters
+CREATE INDEX idx_keepsy_products_user_category ON keepsy_products (user_id, category_id);
+CREATE INDEX idx_keepsy_products_user_location ON keepsy_products (user_id, location);
+CREATE INDEX idx_keepsy_products_user_brand ON keepsy_products (user_id, brand);
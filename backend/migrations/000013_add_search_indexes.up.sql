@@ -0,0 +1,10 @@
+-- Full-text search (GET /search). Searched columns:
+--   products: name, brand, model, location
+--   purchase details: shop_name, order_id
+--   bills: extracted_text, the text read from the bill file (NULL until
+--   extracted)
+ALTER TABLE keepsy_bills ADD COLUMN extracted_text MEDIUMTEXT NULL AFTER file_type;
+
+ALTER TABLE keepsy_products ADD FULLTEXT INDEX ft_keepsy_products (name, brand, model, location);
+ALTER TABLE keepsy_product_purchase_details ADD FULLTEXT INDEX ft_keepsy_product_purchase_details (shop_name, order_id);
+ALTER TABLE keepsy_bills ADD FULLTEXT INDEX ft_keepsy_bills_text (extracted_text);
@@ -0,0 +1,3 @@
+-- Default warranty period of a category. Products without a warranty end
+-- date get purchase_date + this many months.
+ALTER TABLE keepsy_categories ADD COLUMN default_warranty_months INT NULL AFTER is_active;
@@ -0,0 +1,19 @@
+-- Warranty and coverage records; a product can have several (manufacturer,
+-- extended, AMC, insurance). Its effective warranty ends with the latest
+-- record that has started.
+CREATE TABLE IF NOT EXISTS keepsy_warranties (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    provider VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL, -- manufacturer, extended, amc, insurance
+    start_date DATE NULL, -- NULL: covered since purchase
+    end_date DATE NOT NULL,
+    coverage TEXT NULL, -- e.g. "Panel only"
+    claim_contact VARCHAR(255) NULL,
+    document_id INT NULL, -- A bill of the same product, e.g. the warranty card
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_keepsy_warranties_product (product_id, end_date),
+    FOREIGN KEY (product_id) REFERENCES keepsy_products(id) ON DELETE CASCADE,
+    FOREIGN KEY (document_id) REFERENCES keepsy_bills(id) ON DELETE SET NULL
+);
@@ -0,0 +1,24 @@
+-- Maintenance and service history of a product: repairs, servicing, part
+-- replacements and technician visits, with their invoices.
+CREATE TABLE IF NOT EXISTS keepsy_service_records (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL, -- repair, servicing, part_replacement, technician_visit
+    service_date DATE NOT NULL,
+    vendor VARCHAR(255) NULL,
+    cost DECIMAL(10, 2) NULL,
+    notes TEXT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_keepsy_service_records_product (product_id, service_date),
+    FOREIGN KEY (product_id) REFERENCES keepsy_products(id) ON DELETE CASCADE
+);
+
+-- Invoices are bills of the same product
+CREATE TABLE IF NOT EXISTS keepsy_service_record_invoices (
+    service_record_id INT NOT NULL,
+    bill_id INT NOT NULL,
+    PRIMARY KEY (service_record_id, bill_id),
+    FOREIGN KEY (service_record_id) REFERENCES keepsy_service_records(id) ON DELETE CASCADE,
+    FOREIGN KEY (bill_id) REFERENCES keepsy_bills(id) ON DELETE CASCADE
+);
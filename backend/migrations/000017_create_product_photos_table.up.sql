@@ -0,0 +1,19 @@
+-- Product photo gallery. Files live under <uuid>/products/<id>/, with JPEG
+-- thumbnails made on upload. The first photo becomes the primary one, whose
+-- small thumbnail is shown in product lists.
+CREATE TABLE IF NOT EXISTS keepsy_product_photos (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    file_url VARCHAR(2048) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    thumbnail_small_url VARCHAR(2048) NOT NULL, -- 200px
+    thumbnail_medium_url VARCHAR(2048) NOT NULL, -- 600px
+    thumbnail_large_url VARCHAR(2048) NOT NULL, -- 1200px
+    position INT NOT NULL DEFAULT 0,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_keepsy_product_photos_product (product_id, position),
+    FOREIGN KEY (product_id) REFERENCES keepsy_products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,35 @@
+-- Per-user locations: property > floor > room > container (containers nest).
+-- Names are unique among siblings, ignoring case.
+CREATE TABLE IF NOT EXISTS keepsy_locations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    parent_id INT NULL, -- NULL: top level
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(20) NOT NULL, -- property, floor, room, container
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_keepsy_locations_user (user_id, parent_id, name),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE,
+    -- Only empty locations can be deleted through the API; this lets a purged
+    -- account's locations go with it
+    FOREIGN KEY (parent_id) REFERENCES keepsy_locations(id) ON DELETE CASCADE
+);
+
+-- products.location stays as a copy of the location's name, for search
+ALTER TABLE keepsy_products
+    ADD COLUMN location_id INT NULL AFTER model,
+    ADD FOREIGN KEY (location_id) REFERENCES keepsy_locations(id) ON DELETE SET NULL;
+
+-- Turn the free-text locations into top-level rooms, one per distinct
+-- trimmed name per user ("kitchen" and "Kitchen " are the same room)
+INSERT INTO keepsy_locations (user_id, name, kind)
+SELECT user_id, MIN(TRIM(location)), 'room'
+FROM keepsy_products
+WHERE TRIM(COALESCE(location, '')) <> ''
+GROUP BY user_id, LOWER(TRIM(location));
+
+UPDATE keepsy_products p
+JOIN keepsy_locations l ON l.user_id = p.user_id AND l.parent_id IS NULL AND LOWER(l.name) = LOWER(TRIM(p.location))
+SET p.location_id = l.id, p.location = l.name;
+
+UPDATE keepsy_products SET location = '' WHERE location_id IS NULL;
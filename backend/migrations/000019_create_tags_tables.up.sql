@@ -0,0 +1,19 @@
+-- Per-user tags ("gift", "office", "needs repair"), many-to-many with
+-- products. Names are unique per user, ignoring case.
+CREATE TABLE IF NOT EXISTS keepsy_tags (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_keepsy_tags_user_name (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES keepsy_users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS keepsy_product_tags (
+    product_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY (product_id, tag_id),
+    INDEX idx_keepsy_product_tags_tag (tag_id, product_id),
+    FOREIGN KEY (product_id) REFERENCES keepsy_products(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES keepsy_tags(id) ON DELETE CASCADE
+);
@@ -0,0 +1,26 @@
+-- Custom attributes a category defines for its products (IMEI for phones,
+-- VIN for cars). Keys are unique within a category.
+CREATE TABLE IF NOT EXISTS keepsy_category_attributes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    category_id INT NOT NULL,
+    attribute_key VARCHAR(50) NOT NULL, -- "imei", "ram_gb"
+    label VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL, -- string, number, date, enum
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    options JSON NULL, -- Allowed values of an enum
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_keepsy_category_attributes_key (category_id, attribute_key),
+    FOREIGN KEY (category_id) REFERENCES keepsy_categories(id) ON DELETE CASCADE
+);
+
+-- Values are stored as text: numbers in their shortest form, dates as
+-- YYYY-MM-DD
+CREATE TABLE IF NOT EXISTS keepsy_product_attributes (
+    product_id INT NOT NULL,
+    attribute_id INT NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    PRIMARY KEY (product_id, attribute_id),
+    INDEX idx_keepsy_product_attributes_value (attribute_id, value),
+    FOREIGN KEY (product_id) REFERENCES keepsy_products(id) ON DELETE CASCADE,
+    FOREIGN KEY (attribute_id) REFERENCES keepsy_category_attributes(id) ON DELETE CASCADE
+);
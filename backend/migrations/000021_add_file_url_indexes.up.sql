@@ -0,0 +1,8 @@
+-- Indexes for GET /uploads/..., which finds the bill or photo referencing a
+-- file by its URL before serving it. The URLs are too long to index in full;
+-- the prefix narrows it down to a row or two.
+CREATE INDEX idx_keepsy_bills_file_url ON keepsy_bills (file_url(255));
+CREATE INDEX idx_keepsy_product_photos_file_url ON keepsy_product_photos (file_url(255));
+CREATE INDEX idx_keepsy_product_photos_small_url ON keepsy_product_photos (thumbnail_small_url(255));
+CREATE INDEX idx_keepsy_product_photos_medium_url ON keepsy_product_photos (thumbnail_medium_url(255));
+CREATE INDEX idx_keepsy_product_photos_large_url ON keepsy_product_photos (thumbnail_large_url(255));